@@ -19,12 +19,14 @@ import (
 	"agentic/internal/config"
 	"agentic/internal/db"
 	"agentic/internal/executil"
+	"agentic/internal/gateway"
 	"agentic/internal/ir"
 	"agentic/internal/router"
 	"agentic/internal/scheduler"
 	"agentic/internal/store"
 	"agentic/internal/telegram"
 	"agentic/internal/tools"
+	"agentic/iron"
 )
 
 func main() {
@@ -63,7 +65,29 @@ func main() {
 	}
 	defer database.Close()
 
+	var ironCache iron.Cache
+	if cfg.Iron.PersistCache {
+		var ttl time.Duration
+		if cfg.Iron.CacheTTL != "" {
+			if ttl, err = time.ParseDuration(cfg.Iron.CacheTTL); err != nil {
+				log.Fatalf("iron cache ttl: %v", err)
+			}
+		}
+		persisted := db.NewIronCache(database, ttl)
+		persisted.StartVacuum(ctx, time.Hour)
+		ironCache = persisted
+	}
+
+	compressor, err := gateway.New(cfg.Iron, ironCache)
+	if err != nil {
+		log.Fatalf("iron: %v", err)
+	}
+	if compressor != nil && cfg.Iron.Dictionary {
+		compressor.Sessions = sessionStore
+	}
+
 	sched := scheduler.New(codexClient, adapterRegistry, toolRegistry, database)
+	sched.SetCompressor(compressor)
 	if err := sched.RegisterTasks(cfg.Tasks); err != nil {
 		log.Fatalf("scheduler: %v", err)
 	}
@@ -111,9 +135,38 @@ func main() {
 	if err := addonMgr.Load(ctx, cfg.Addons, toolRegistry, adapterRegistry); err != nil {
 		log.Fatalf("addons: %v", err)
 	}
+	defer addonMgr.Close()
+	if err := compressor.Register(addonMgr.Modules()...); err != nil {
+		log.Fatalf("iron modules: %v", err)
+	}
 
 	toolServer := &tools.Server{Registry: toolRegistry}
-	httpSrv := &http.Server{Addr: cfg.ToolsAddr, Handler: toolServer.Routes()}
+	var handler http.Handler = toolServer.Routes()
+	var ironSrv *http.Server
+	if cfg.Iron.HTTP {
+		if ironCache == nil {
+			ironCache = iron.NewLRUCache(cfg.Iron.CacheEntries, 0)
+		}
+		engine := iron.NewDefault(iron.WithCache(ironCache), iron.WithSegmentation())
+		if compressor != nil {
+			engine = compressor.Engine
+		}
+		ironServer := &iron.Server{Engine: engine}
+		if cfg.Iron.HTTPAddr == "" {
+			mux := http.NewServeMux()
+			mux.Handle("/tools/", handler)
+			mux.Handle("/v1/", ironServer.Routes())
+			handler = mux
+		} else {
+			ironSrv = &http.Server{Addr: cfg.Iron.HTTPAddr, Handler: ironServer.Routes()}
+			go func() {
+				if err := ironSrv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+					log.Printf("iron server error: %v", err)
+				}
+			}()
+		}
+	}
+	httpSrv := &http.Server{Addr: cfg.ToolsAddr, Handler: handler}
 	go func() {
 		if err := httpSrv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 			log.Printf("tools server error: %v", err)
@@ -128,7 +181,7 @@ func main() {
 		return
 	}
 	if err := adapter.Start(ctx, func(msg adapters.Message) {
-		go handleMessage(ctx, msg, adapter, codexClient, toolRegistry, sessionStore, sched)
+		go handleMessage(ctx, msg, adapter, codexClient, compressor, toolRegistry, sessionStore, sched)
 	}); err != nil {
 		log.Fatalf("adapter start: %v", err)
 	}
@@ -137,10 +190,13 @@ func main() {
 	signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM)
 	<-sig
 	_ = httpSrv.Shutdown(context.Background())
+	if ironSrv != nil {
+		_ = ironSrv.Shutdown(context.Background())
+	}
 	_ = sched.Stop(context.Background())
 }
 
-func handleMessage(ctx context.Context, msg adapters.Message, adapter adapters.Adapter, codexClient *codex.Client, toolRegistry *tools.Registry, sessions *store.SessionStore, sched *scheduler.Scheduler) {
+func handleMessage(ctx context.Context, msg adapters.Message, adapter adapters.Adapter, codexClient *codex.Client, compressor *gateway.Compressor, toolRegistry *tools.Registry, sessions *store.SessionStore, sched *scheduler.Scheduler) {
 	text := strings.TrimSpace(msg.Text)
 	if text == "" {
 		return
@@ -150,6 +206,7 @@ func handleMessage(ctx context.Context, msg adapters.Message, adapter adapters.A
 
 	// Quick commands
 	if text == "/new" {
+		compressor.Forget(sessionKey)
 		_ = sessionReset(ctx, sessions, sessionKey, adapter, msg.SenderID)
 		return
 	}
@@ -195,16 +252,18 @@ func handleMessage(ctx context.Context, msg adapters.Message, adapter adapters.A
 
 	// 2. LLM: Gateway
 	useLast := state.UseLast
-	promptContext := ""
+	var parts []gateway.Part
 	if !useLast {
 		// Load system prompt + metadata
 		if content, err := os.ReadFile("prompt.txt"); err == nil {
-			meta := fmt.Sprintf("Current Time: %s\nUser Chat ID: %s\n\n", time.Now().Format(time.RFC3339), msg.SenderID)
-			promptContext = string(content) + "\n\n" + meta
+			meta := fmt.Sprintf("Current Time: %s\nUser Chat ID: %s", time.Now().Format(time.RFC3339), msg.SenderID)
+			parts = append(parts, gateway.Part{Label: "system", Text: string(content)}, compressor.ResponseFormat(), gateway.Part{Label: "meta", Text: meta})
 		}
 	}
+	parts = append(parts, gateway.Part{Label: "user", Text: text})
 
-	fullPrompt := promptContext + text
+	label := sessionKey
+	fullPrompt := compressor.SessionPrompt(ctx, label, !useLast, parts...)
 	stopTyping := startTyping(ctx, adapter, msg.SenderID)
 	resp, err := codexClient.Exec(ctx, state.ID, state.Dir, fullPrompt, useLast)
 	stopTyping()
@@ -212,6 +271,7 @@ func handleMessage(ctx context.Context, msg adapters.Message, adapter adapters.A
 		_ = adapter.Send(ctx, msg.SenderID, "LLM Error: "+err.Error())
 		return
 	}
+	compressor.CommitSession(label)
 
 	// Update session state
 	if resp.SessionID != "" && resp.SessionID != state.ID {
@@ -225,13 +285,13 @@ func handleMessage(ctx context.Context, msg adapters.Message, adapter adapters.A
 	_ = sessions.SetUseLast(sessionKey, true)
 
 	// 3. PARSE & REPAIR
-	agentResp, ok := parseResponse(ctx, codexClient, adapter, msg.SenderID, text, resp.Text, state.ID, state.Dir)
+	agentResp, ok := parseResponse(ctx, codexClient, compressor, label, adapter, msg.SenderID, text, compressor.DecodeReply(ctx, label, resp.Text), state.ID, state.Dir)
 	if !ok {
 		return
 	}
 
 	// 4. EXECUTION
-	needProcess := processResponse(ctx, &agentResp, codexClient, adapter, msg.SenderID, toolRegistry, sched, state.ID, state.Dir)
+	needProcess := processResponse(ctx, &agentResp, codexClient, compressor, label, adapter, msg.SenderID, toolRegistry, sched, state.ID, state.Dir)
 	if !needProcess {
 		return
 	}
@@ -254,18 +314,18 @@ func handleMessage(ctx context.Context, msg adapters.Message, adapter adapters.A
 			state.Dir = nextResp.NewDir
 		}
 
-		agentResp, ok = parseResponse(ctx, codexClient, adapter, msg.SenderID, "continue", nextResp.Text, state.ID, state.Dir)
+		agentResp, ok = parseResponse(ctx, codexClient, compressor, label, adapter, msg.SenderID, "continue", compressor.DecodeReply(ctx, label, nextResp.Text), state.ID, state.Dir)
 		if !ok {
 			return
 		}
 
-		if !processResponse(ctx, &agentResp, codexClient, adapter, msg.SenderID, toolRegistry, sched, state.ID, state.Dir) {
+		if !processResponse(ctx, &agentResp, codexClient, compressor, label, adapter, msg.SenderID, toolRegistry, sched, state.ID, state.Dir) {
 			return
 		}
 	}
 }
 
-func parseResponse(ctx context.Context, codexClient *codex.Client, adapter adapters.Adapter, senderID, prompt, raw, sessionID, dir string) (ir.Response, bool) {
+func parseResponse(ctx context.Context, codexClient *codex.Client, compressor *gateway.Compressor, label string, adapter adapters.Adapter, senderID, prompt, raw, sessionID, dir string) (ir.Response, bool) {
 	var agentResp ir.Response
 	if err := json.Unmarshal([]byte(raw), &agentResp); err != nil {
 		log.Printf("json parse error: %v. attempting repair...", err)
@@ -279,7 +339,7 @@ Return JSON only.`, prompt, raw, err)
 		repairResp, rErr := codexClient.Exec(ctx, sessionID, dir, repairPrompt, false)
 		stopTyping()
 		if rErr == nil {
-			if err2 := json.Unmarshal([]byte(repairResp.Text), &agentResp); err2 == nil {
+			if err2 := json.Unmarshal([]byte(compressor.DecodeReply(ctx, label, repairResp.Text)), &agentResp); err2 == nil {
 				log.Println("repair successful")
 			} else {
 				log.Printf("repair failed: %v", err2)
@@ -294,7 +354,7 @@ Return JSON only.`, prompt, raw, err)
 	return agentResp, true
 }
 
-func processResponse(ctx context.Context, agentResp *ir.Response, codexClient *codex.Client, adapter adapters.Adapter, senderID string, toolRegistry *tools.Registry, sched *scheduler.Scheduler, sessionID, dir string) bool {
+func processResponse(ctx context.Context, agentResp *ir.Response, codexClient *codex.Client, compressor *gateway.Compressor, label string, adapter adapters.Adapter, senderID string, toolRegistry *tools.Registry, sched *scheduler.Scheduler, sessionID, dir string) bool {
 	if agentResp.Reply != "" {
 		_ = adapter.Send(ctx, senderID, agentResp.Reply)
 	}
@@ -316,7 +376,7 @@ Return JSON only.`, err)
 			log.Printf("semantic repair exec failed: %v", rErr)
 			return false
 		}
-		if err2 := json.Unmarshal([]byte(repairResp.Text), agentResp); err2 != nil {
+		if err2 := json.Unmarshal([]byte(compressor.DecodeReply(ctx, label, repairResp.Text)), agentResp); err2 != nil {
 			log.Printf("semantic repair json parse failed: %v", err2)
 			return false
 		}
@@ -427,6 +487,7 @@ func executePacket(ctx context.Context, packet *ir.Packet, registry *tools.Regis
 func sessionReset(ctx context.Context, s *store.SessionStore, key string, adapter adapters.Adapter, sender string) error {
 	_ = s.SetUseLast(key, false)
 	_ = s.SetDir(key, "")
+	_ = s.SetDictionary(key, nil)
 	return adapter.Send(ctx, sender, "Session reset.")
 }
 
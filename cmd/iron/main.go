@@ -0,0 +1,495 @@
+// Command iron encodes, decodes, and inspects text with the iron engine.
+//
+//	iron encode [flags] [file...]   print the IR of each file or stdin
+//	iron decode [flags] [file...]   restore IR to text
+//	iron detect [flags] [file]      show which modules detect the input
+//	iron explain [flags] [file]     show how the input was encoded
+//	iron bench [flags] dir          benchmark every file under dir
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"agentic/iron"
+)
+
+const usage = `usage: iron <command> [flags] [args]
+
+commands:
+  encode   print the IR of each file, or of stdin
+  decode   restore IR files, or stdin, to text
+  detect   show which modules detect the input and which is chosen
+  explain  show tokens, losses, rejections and segments for the input
+  bench    encode every file under a directory and print a table
+
+Run "iron <command> -h" for flags.`
+
+func main() {
+	if err := run(os.Args[1:], os.Stdin, os.Stdout); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(2)
+		}
+		fmt.Fprintln(os.Stderr, "iron:", err)
+		os.Exit(1)
+	}
+}
+
+// options are the flags shared by every command.
+type options struct {
+	level      string
+	module     string
+	budget     int
+	bestEffort bool
+	segment    bool
+	redact     bool
+	json       bool
+}
+
+func (o *options) register(fs *flag.FlagSet) {
+	fs.StringVar(&o.level, "level", "", "lossiness: lossless, balanced or aggressive")
+	fs.StringVar(&o.module, "module", "", "force a module by name, such as IR-LOG")
+	fs.IntVar(&o.budget, "budget", 0, "token budget; escalates to lossier levels to fit")
+	fs.BoolVar(&o.bestEffort, "best-effort", false, "return the smallest IR when the budget cannot be met")
+	fs.BoolVar(&o.segment, "segment", false, "split mixed input and encode each region separately")
+	fs.BoolVar(&o.redact, "redact", false, "replace secrets and personal data with placeholders")
+	fs.BoolVar(&o.json, "json", false, "print JSON")
+}
+
+func (o options) engine() *iron.Engine {
+	var engineOptions []iron.Option
+	if o.segment {
+		engineOptions = append(engineOptions, iron.WithSegmentation())
+	}
+	if o.redact {
+		engineOptions = append(engineOptions, iron.WithRedactor(iron.NewRedactor()))
+	}
+	return iron.NewDefault(engineOptions...)
+}
+
+func (o options) callOptions() ([]iron.CallOption, error) {
+	var call []iron.CallOption
+	if o.level != "" {
+		level, ok := iron.ParseLevel(o.level)
+		if !ok {
+			return nil, fmt.Errorf("unknown level %q", o.level)
+		}
+		call = append(call, iron.AtLevel(level))
+	}
+	if o.module != "" {
+		call = append(call, iron.ForceModule(o.module))
+	}
+	if o.budget > 0 {
+		call = append(call, iron.TokenBudget(o.budget))
+	}
+	if o.bestEffort {
+		call = append(call, iron.BestEffort())
+	}
+	return call, nil
+}
+
+func run(args []string, stdin io.Reader, stdout io.Writer) error {
+	if len(args) == 0 {
+		fmt.Fprintln(stdout, usage)
+		return flag.ErrHelp
+	}
+	commands := map[string]func([]string, io.Reader, io.Writer) error{
+		"encode":  runEncode,
+		"decode":  runDecode,
+		"detect":  runDetect,
+		"explain": runExplain,
+		"bench":   runBench,
+	}
+	command, ok := commands[args[0]]
+	if !ok {
+		fmt.Fprintln(stdout, usage)
+		return fmt.Errorf("unknown command %q", args[0])
+	}
+	return command(args[1:], stdin, stdout)
+}
+
+// parse parses the shared flags of a command.
+func parse(name string, args []string) (options, []string, error) {
+	var opts options
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	opts.register(fs)
+	if err := fs.Parse(args); err != nil {
+		return opts, nil, err
+	}
+	return opts, fs.Args(), nil
+}
+
+// input is one named text to process.
+type input struct {
+	name string
+	text string
+}
+
+// readInputs reads the named files, or stdin when there are none or the
+// name is "-".
+func readInputs(names []string, stdin io.Reader) ([]input, error) {
+	if len(names) == 0 {
+		names = []string{"-"}
+	}
+	var inputs []input
+	for _, name := range names {
+		var (
+			data []byte
+			err  error
+		)
+		if name == "-" {
+			data, err = io.ReadAll(stdin)
+		} else {
+			data, err = os.ReadFile(name)
+		}
+		if err != nil {
+			return nil, err
+		}
+		inputs = append(inputs, input{name: name, text: string(data)})
+	}
+	return inputs, nil
+}
+
+func runEncode(args []string, stdin io.Reader, stdout io.Writer) error {
+	opts, names, err := parse("encode", args)
+	if err != nil {
+		return err
+	}
+	call, err := opts.callOptions()
+	if err != nil {
+		return err
+	}
+	inputs, err := readInputs(names, stdin)
+	if err != nil {
+		return err
+	}
+	engine := opts.engine()
+	var reports []report
+	for _, in := range inputs {
+		result, err := engine.ProcessContext(context.Background(), in.text, call...)
+		if err != nil {
+			return fmt.Errorf("%s: %w", in.name, err)
+		}
+		if opts.json {
+			rep := newReport(in.name, result)
+			rep.IR = result.IR
+			reports = append(reports, rep)
+			continue
+		}
+		fmt.Fprintln(stdout, result.IR)
+	}
+	if opts.json {
+		return writeJSON(stdout, reports)
+	}
+	return nil
+}
+
+func runDecode(args []string, stdin io.Reader, stdout io.Writer) error {
+	opts, names, err := parse("decode", args)
+	if err != nil {
+		return err
+	}
+	inputs, err := readInputs(names, stdin)
+	if err != nil {
+		return err
+	}
+	engine := opts.engine()
+	var reports []report
+	for _, in := range inputs {
+		result, err := engine.Decode(context.Background(), strings.TrimSpace(in.text))
+		if err != nil {
+			return fmt.Errorf("%s: %w", in.name, err)
+		}
+		if opts.json {
+			rep := newReport(in.name, result)
+			rep.Output = result.Output
+			reports = append(reports, rep)
+			continue
+		}
+		fmt.Fprintln(stdout, result.Output)
+	}
+	if opts.json {
+		return writeJSON(stdout, reports)
+	}
+	return nil
+}
+
+// detection is one module's view of an input.
+type detection struct {
+	Module     string  `json:"module"`
+	Detect     bool    `json:"detect"`
+	Confidence float64 `json:"confidence"`
+	Score      float64 `json:"score"`
+	Rank       int     `json:"rank,omitempty"`
+	Chosen     bool    `json:"chosen"`
+	Why        string  `json:"why"`
+}
+
+func runDetect(args []string, stdin io.Reader, stdout io.Writer) error {
+	opts, names, err := parse("detect", args)
+	if err != nil {
+		return err
+	}
+	call, err := opts.callOptions()
+	if err != nil {
+		return err
+	}
+	inputs, err := readInputs(names, stdin)
+	if err != nil {
+		return err
+	}
+	if len(inputs) != 1 {
+		return errors.New("detect takes one input")
+	}
+	engine := opts.engine()
+	text := strings.TrimSpace(inputs[0].text)
+	result, err := engine.ProcessContext(context.Background(), text, append(call, iron.SkipCache())...)
+	if err != nil {
+		return err
+	}
+	detections := detect(engine, text, result)
+	if opts.json {
+		return writeJSON(stdout, detections)
+	}
+	w := tabwriter.NewWriter(stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "MODULE\tDETECT\tCONF\tSCORE\tRANK\tWHY")
+	for _, d := range detections {
+		rank := "-"
+		if d.Rank > 0 {
+			rank = fmt.Sprint(d.Rank)
+		}
+		mark := ""
+		if d.Chosen {
+			mark = "*"
+		}
+		fmt.Fprintf(w, "%s%s\t%t\t%.2f\t%.2f\t%s\t%s\n", d.Module, mark, d.Detect, d.Confidence, d.Score, rank, d.Why)
+	}
+	return w.Flush()
+}
+
+// detect asks every module whether it detects text and explains the choice
+// the engine made in result.
+func detect(engine *iron.Engine, text string, result iron.Result) []detection {
+	rejected := map[string]string{}
+	for _, rejection := range result.Rejected {
+		rejected[rejection.Module] = fmt.Sprintf("rejected by %s: %s", rejection.Validator, rejection.Reason)
+	}
+	ranks := map[string]int{}
+	confidences := map[string]float64{}
+	for i, candidate := range engine.Rank(text) {
+		ranks[candidate.Module] = i + 1
+		confidences[candidate.Module] = candidate.Confidence
+	}
+	var detections []detection
+	for _, module := range engine.Modules() {
+		d := detection{
+			Module:     module.Name(),
+			Detect:     module.Detect(text),
+			Confidence: confidences[module.Name()],
+			Score:      module.Score(),
+			Rank:       ranks[module.Name()],
+		}
+		switch {
+		case d.Module == result.Module:
+			d.Chosen = true
+			d.Why = "chosen: highest-ranked module whose output passed validation"
+			if len(result.Ties) > 0 {
+				d.Why += "; tied with " + strings.Join(result.Ties, ", ")
+			}
+		case rejected[d.Module] != "":
+			d.Why = rejected[d.Module]
+		case !d.Detect:
+			d.Why = "input not recognized"
+		default:
+			d.Why = "outranked"
+		}
+		detections = append(detections, d)
+	}
+	if result.Module == "" {
+		detections = append(detections, detection{Module: "(none)", Chosen: true, Why: "every module was rejected; input kept as is"})
+	}
+	return detections
+}
+
+func runExplain(args []string, stdin io.Reader, stdout io.Writer) error {
+	opts, names, err := parse("explain", args)
+	if err != nil {
+		return err
+	}
+	call, err := opts.callOptions()
+	if err != nil {
+		return err
+	}
+	inputs, err := readInputs(names, stdin)
+	if err != nil {
+		return err
+	}
+	engine := opts.engine()
+	var reports []report
+	for _, in := range inputs {
+		result, err := engine.ProcessContext(context.Background(), in.text, call...)
+		if err != nil {
+			return fmt.Errorf("%s: %w", in.name, err)
+		}
+		rep := newReport(in.name, result)
+		if opts.json {
+			rep.IR = result.IR
+			reports = append(reports, rep)
+			continue
+		}
+		explain(stdout, rep, result)
+	}
+	if opts.json {
+		return writeJSON(stdout, reports)
+	}
+	return nil
+}
+
+func explain(w io.Writer, rep report, result iron.Result) {
+	module := rep.Module
+	if module == "" {
+		module = "(none)"
+	}
+	fmt.Fprintf(w, "%s: %s, %d -> %d tokens (%.0f%% saved), encode %s, decode %s\n",
+		rep.Name, module, rep.InputTokens, rep.IRTokens, rep.Savings*100, rep.Encode, rep.Decode)
+	for _, loss := range result.Losses {
+		fmt.Fprintf(w, "  loss: %s x%d (%s)\n", loss.Kind, loss.Count, loss.Detail)
+	}
+	for _, rejection := range result.Rejected {
+		fmt.Fprintf(w, "  rejected: %s by %s: %s\n", rejection.Module, rejection.Validator, rejection.Reason)
+	}
+	for _, step := range result.Escalations {
+		fmt.Fprintf(w, "  escalated: %s at %s -> %d tokens\n", step.Module, step.Level, step.IRTokens)
+	}
+	if result.OverBudget {
+		fmt.Fprintln(w, "  over budget")
+	}
+	for _, segment := range result.Segments {
+		segmentModule := segment.Module
+		if segmentModule == "" {
+			segmentModule = "plain"
+		}
+		fmt.Fprintf(w, "  segment: %s via %s, %d -> %d tokens\n", segment.Kind, segmentModule, segment.InputTokens, segment.IRTokens)
+	}
+	for _, redaction := range result.Redactions {
+		fmt.Fprintf(w, "  redacted: %s as %s\n", redaction.Kind, redaction.Placeholder)
+	}
+	fmt.Fprintln(w, result.IR)
+}
+
+func runBench(args []string, stdin io.Reader, stdout io.Writer) error {
+	opts, dirs, err := parse("bench", args)
+	if err != nil {
+		return err
+	}
+	call, err := opts.callOptions()
+	if err != nil {
+		return err
+	}
+	if len(dirs) != 1 {
+		return errors.New("bench takes one corpus directory")
+	}
+	engine := opts.engine()
+	call = append(call, iron.SkipCache())
+	var reports []report
+	err = filepath.WalkDir(dirs[0], func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		result, err := engine.ProcessContext(context.Background(), string(data), call...)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		name, _ := filepath.Rel(dirs[0], path)
+		reports = append(reports, newReport(name, result))
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if opts.json {
+		return writeJSON(stdout, reports)
+	}
+	w := tabwriter.NewWriter(stdout, 0, 4, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(w, "FILE\tMODULE\tINPUT\tIR\tSAVED\tENCODE\tDECODE\t")
+	var total report
+	for _, rep := range reports {
+		writeBenchRow(w, rep)
+		total.InputTokens += rep.InputTokens
+		total.IRTokens += rep.IRTokens
+		total.Encode += rep.Encode
+		total.Decode += rep.Decode
+	}
+	total.Name = fmt.Sprintf("total (%d)", len(reports))
+	if total.InputTokens > 0 {
+		total.Savings = 1 - float64(total.IRTokens)/float64(total.InputTokens)
+	}
+	writeBenchRow(w, total)
+	return w.Flush()
+}
+
+func writeBenchRow(w io.Writer, rep report) {
+	fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%.1f%%\t%s\t%s\t\n",
+		rep.Name, rep.Module, rep.InputTokens, rep.IRTokens, rep.Savings*100,
+		rep.Encode.Round(time.Microsecond), rep.Decode.Round(time.Microsecond))
+}
+
+// report is the JSON form of a result.
+type report struct {
+	Name        string               `json:"name"`
+	Module      string               `json:"module"`
+	InputTokens int                  `json:"input_tokens"`
+	IRTokens    int                  `json:"ir_tokens"`
+	Savings     float64              `json:"savings"`
+	Encode      time.Duration        `json:"encode_ns"`
+	Decode      time.Duration        `json:"decode_ns"`
+	Losses      []iron.Loss          `json:"losses,omitempty"`
+	Rejected    []iron.Rejection     `json:"rejected,omitempty"`
+	Escalations []iron.Escalation    `json:"escalations,omitempty"`
+	OverBudget  bool                 `json:"over_budget,omitempty"`
+	Segments    []iron.SegmentResult `json:"segments,omitempty"`
+	Redacted    []string             `json:"redacted,omitempty"`
+	IR          string               `json:"ir,omitempty"`
+	Output      string               `json:"output,omitempty"`
+}
+
+func newReport(name string, result iron.Result) report {
+	rep := report{
+		Name:        name,
+		Module:      result.Module,
+		InputTokens: result.InputTokens,
+		IRTokens:    result.IRTokens,
+		Savings:     result.Savings(),
+		Encode:      result.EncodeDuration,
+		Decode:      result.DecodeDuration,
+		Losses:      result.Losses,
+		Rejected:    result.Rejected,
+		Escalations: result.Escalations,
+		OverBudget:  result.OverBudget,
+		Segments:    result.Segments,
+	}
+	for _, redaction := range result.Redactions {
+		rep.Redacted = append(rep.Redacted, redaction.Kind+" "+redaction.Placeholder)
+	}
+	return rep
+}
+
+func writeJSON(w io.Writer, value any) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(value)
+}
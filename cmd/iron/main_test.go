@@ -0,0 +1,69 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const sampleJSON = `{"users":[{"id":1,"name":"a","role":"admin"},{"id":2,"name":"b","role":"user"},{"id":3,"name":"c","role":"user"}]}`
+
+func TestRun_EncodeDecode(t *testing.T) {
+	var encoded bytes.Buffer
+	if err := run([]string{"encode"}, strings.NewReader(sampleJSON), &encoded); err != nil {
+		t.Fatalf("encode error = %v", err)
+	}
+	if !strings.HasPrefix(encoded.String(), "@DATA") {
+		t.Fatalf("encode = %q", encoded.String())
+	}
+	var decoded bytes.Buffer
+	if err := run([]string{"decode"}, &encoded, &decoded); err != nil {
+		t.Fatalf("decode error = %v", err)
+	}
+	if strings.TrimSpace(decoded.String()) != sampleJSON {
+		t.Fatalf("decode = %q", decoded.String())
+	}
+}
+
+func TestRun_DetectJSON(t *testing.T) {
+	var out bytes.Buffer
+	if err := run([]string{"detect", "-json"}, strings.NewReader(sampleJSON), &out); err != nil {
+		t.Fatalf("detect error = %v", err)
+	}
+	var detections []detection
+	if err := json.Unmarshal(out.Bytes(), &detections); err != nil {
+		t.Fatalf("detect output is not JSON: %v\n%s", err, out.String())
+	}
+	for _, d := range detections {
+		if d.Chosen && d.Module != "IR-DATA" {
+			t.Fatalf("detect chose %s, want IR-DATA", d.Module)
+		}
+	}
+}
+
+func TestRun_Bench(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "users.json"), []byte(sampleJSON), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	if err := run([]string{"bench", "-json", dir}, nil, &out); err != nil {
+		t.Fatalf("bench error = %v", err)
+	}
+	var reports []report
+	if err := json.Unmarshal(out.Bytes(), &reports); err != nil {
+		t.Fatalf("bench output is not JSON: %v", err)
+	}
+	if len(reports) != 1 || reports[0].Name != "users.json" || reports[0].IRTokens >= reports[0].InputTokens {
+		t.Fatalf("bench = %+v", reports)
+	}
+}
+
+func TestRun_UnknownCommand(t *testing.T) {
+	if err := run([]string{"nope"}, nil, &bytes.Buffer{}); err == nil {
+		t.Fatal("run() error = nil for unknown command")
+	}
+}
@@ -8,4 +8,4 @@ require (
 	github.com/robfig/cron/v3 v3.0.1
 )
 
-require github.com/mattn/go-sqlite3 v1.14.33 // indirect
+require github.com/mattn/go-sqlite3 v1.14.33
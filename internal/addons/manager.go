@@ -11,10 +11,13 @@ import (
 	"agentic/internal/adapters"
 	"agentic/internal/config"
 	"agentic/internal/tools"
+	"agentic/iron"
 )
 
 type Manager struct {
 	RootDir string
+
+	modules []*iron.ExternalModule
 }
 
 func New(root string) *Manager {
@@ -60,7 +63,29 @@ func (m *Manager) Load(ctx context.Context, addons []config.AddonConfig, toolReg
 				id = addon.Name
 			}
 			adapterReg.Register(&adapters.ExternalAdapter{AdapterID: id, Command: []string{bin}, Timeout: 2 * time.Minute})
+		case "module":
+			name := addon.ModuleName
+			if name == "" {
+				name = addon.Name
+			}
+			m.modules = append(m.modules, &iron.ExternalModule{ModuleName: name, Command: []string{bin}, Timeout: 10 * time.Second, Stderr: os.Stderr})
 		}
 	}
 	return nil
 }
+
+// Modules returns the iron modules loaded from "module" addons.
+func (m *Manager) Modules() []iron.IRModule {
+	modules := make([]iron.IRModule, len(m.modules))
+	for i, module := range m.modules {
+		modules[i] = module
+	}
+	return modules
+}
+
+// Close stops the processes of loaded module addons.
+func (m *Manager) Close() {
+	for _, module := range m.modules {
+		_ = module.Close()
+	}
+}
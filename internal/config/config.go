@@ -20,13 +20,28 @@ type TaskConfig struct {
 }
 
 type AddonConfig struct {
-	Name      string   `json:"name"`
-	Type      string   `json:"type"` // tool | adapter
-	Repo      string   `json:"repo"`
-	Build     []string `json:"build"`
-	Binary    string   `json:"binary"`
-	ToolName  string   `json:"tool_name"`
-	AdapterID string   `json:"adapter_id"`
+	Name       string   `json:"name"`
+	Type       string   `json:"type"` // tool | adapter | module
+	Repo       string   `json:"repo"`
+	Build      []string `json:"build"`
+	Binary     string   `json:"binary"`
+	ToolName   string   `json:"tool_name"`
+	AdapterID  string   `json:"adapter_id"`
+	ModuleName string   `json:"module_name"`
+}
+
+type IronConfig struct {
+	Enabled        bool     `json:"enabled"`
+	Level          string   `json:"level"` // lossless | balanced | aggressive
+	CacheEntries   int      `json:"cache_entries"`
+	Redact         bool     `json:"redact"`
+	RedactKinds    []string `json:"redact_kinds"` // jwt | api_key | email | iban | cpf | ip | phone; empty means all
+	Dictionary     bool     `json:"dictionary"`
+	HTTP           bool     `json:"http"`            // serve /v1 endpoints
+	HTTPAddr       string   `json:"http_addr"`       // empty mounts them on tools_addr
+	CompactReplies bool     `json:"compact_replies"` // ask models for IR-META lines instead of JSON
+	PersistCache   bool     `json:"persist_cache"`   // keep encoded prompts in agent.db across restarts
+	CacheTTL       string   `json:"cache_ttl"`       // Go duration, e.g. "168h"; empty keeps persisted entries
 }
 
 type Config struct {
@@ -39,6 +54,7 @@ type Config struct {
 	Tasks           []TaskConfig  `json:"tasks"`
 	Addons          []AddonConfig `json:"addons"`
 	MaxResponseSize int           `json:"max_response_size"`
+	Iron            IronConfig    `json:"iron"`
 }
 
 func DefaultConfig() Config {
@@ -50,6 +66,11 @@ func DefaultConfig() Config {
 		DataDir:         "data",
 		ToolsAddr:       ":8089",
 		MaxResponseSize: 3500,
+		Iron: IronConfig{
+			Level:        "lossless",
+			CacheEntries: 512,
+			CacheTTL:     "168h",
+		},
 	}
 }
 
@@ -91,6 +112,33 @@ func applyEnvOverrides(cfg *Config) {
 	if v := os.Getenv("TOOLS_ADDR"); v != "" {
 		cfg.ToolsAddr = v
 	}
+	if v := os.Getenv("IRON_ENABLED"); v != "" {
+		cfg.Iron.Enabled = v == "1" || strings.EqualFold(v, "true")
+	}
+	if v := os.Getenv("IRON_LEVEL"); v != "" {
+		cfg.Iron.Level = v
+	}
+	if v := os.Getenv("IRON_REDACT"); v != "" {
+		cfg.Iron.Redact = v == "1" || strings.EqualFold(v, "true")
+	}
+	if v := os.Getenv("IRON_DICTIONARY"); v != "" {
+		cfg.Iron.Dictionary = v == "1" || strings.EqualFold(v, "true")
+	}
+	if v := os.Getenv("IRON_HTTP"); v != "" {
+		cfg.Iron.HTTP = v == "1" || strings.EqualFold(v, "true")
+	}
+	if v := os.Getenv("IRON_HTTP_ADDR"); v != "" {
+		cfg.Iron.HTTPAddr = v
+	}
+	if v := os.Getenv("IRON_COMPACT_REPLIES"); v != "" {
+		cfg.Iron.CompactReplies = v == "1" || strings.EqualFold(v, "true")
+	}
+	if v := os.Getenv("IRON_PERSIST_CACHE"); v != "" {
+		cfg.Iron.PersistCache = v == "1" || strings.EqualFold(v, "true")
+	}
+	if v := os.Getenv("IRON_CACHE_TTL"); v != "" {
+		cfg.Iron.CacheTTL = v
+	}
 	if v := os.Getenv("MAX_RESPONSE_SIZE"); v != "" {
 		if n, err := parseInt(v); err == nil {
 			cfg.MaxResponseSize = n
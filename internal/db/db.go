@@ -12,7 +12,7 @@ type DB struct {
 }
 
 func New(path string) (*DB, error) {
-	dsn := fmt.Sprintf("file:%s?cache=shared&mode=rwc&_journal_mode=WAL", path)
+	dsn := fmt.Sprintf("file:%s?cache=shared&mode=rwc&_journal_mode=WAL&_busy_timeout=5000", path)
 	db, err := sql.Open("sqlite3", dsn)
 	if err != nil {
 		return nil, err
@@ -66,6 +66,14 @@ func (d *DB) migrate() error {
 			token TEXT NOT NULL,
 			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
 		);`,
+		`CREATE TABLE IF NOT EXISTS iron_cache (
+			key TEXT PRIMARY KEY, -- sha256 of the engine cache key
+			value BLOB NOT NULL, -- gzip-compressed JSON iron.Result
+			size INTEGER NOT NULL, -- compressed bytes
+			expires_at INTEGER, -- unix seconds, NULL never expires
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		);`,
+		`CREATE INDEX IF NOT EXISTS iron_cache_expires_at ON iron_cache (expires_at);`,
 	}
 
 	for _, schema := range schemas {
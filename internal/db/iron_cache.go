@@ -0,0 +1,173 @@
+package db
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"sync/atomic"
+	"time"
+
+	"agentic/iron"
+)
+
+// IronCache is an iron.Cache persisted in the iron_cache table. Keys are
+// stored as SHA-256 hashes and results as gzip-compressed JSON, so several
+// processes opening the same WAL-mode database share one cache.
+type IronCache struct {
+	db     *DB
+	ttl    time.Duration
+	now    func() time.Time
+	hits   atomic.Uint64
+	misses atomic.Uint64
+}
+
+// NewIronCache creates a cache whose entries expire after ttl; zero keeps
+// them until deleted.
+func NewIronCache(d *DB, ttl time.Duration) *IronCache {
+	return &IronCache{db: d, ttl: ttl, now: time.Now}
+}
+
+func hashCacheKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// Get returns a live entry. Expired entries are deleted on read.
+func (c *IronCache) Get(key string) (iron.Result, bool) {
+	hashed := hashCacheKey(key)
+	var (
+		value   []byte
+		expires sql.NullInt64
+	)
+	err := c.db.QueryRow(`SELECT value, expires_at FROM iron_cache WHERE key = ?`, hashed).Scan(&value, &expires)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			log.Printf("iron cache get: %v", err)
+		}
+		c.misses.Add(1)
+		return iron.Result{}, false
+	}
+	if expires.Valid && c.now().Unix() >= expires.Int64 {
+		c.Delete(key)
+		c.misses.Add(1)
+		return iron.Result{}, false
+	}
+	result, err := decodeIronResult(value)
+	if err != nil {
+		log.Printf("iron cache decode: %v", err)
+		c.Delete(key)
+		c.misses.Add(1)
+		return iron.Result{}, false
+	}
+	c.hits.Add(1)
+	return result, true
+}
+
+// Set stores or replaces an entry.
+func (c *IronCache) Set(key string, result iron.Result) {
+	value, err := encodeIronResult(result)
+	if err != nil {
+		log.Printf("iron cache encode: %v", err)
+		return
+	}
+	var expires sql.NullInt64
+	if c.ttl > 0 {
+		expires = sql.NullInt64{Int64: c.now().Add(c.ttl).Unix(), Valid: true}
+	}
+	_, err = c.db.Exec(`INSERT OR REPLACE INTO iron_cache (key, value, size, expires_at) VALUES (?, ?, ?, ?)`,
+		hashCacheKey(key), value, len(value), expires)
+	if err != nil {
+		log.Printf("iron cache set: %v", err)
+	}
+}
+
+// Delete removes an entry.
+func (c *IronCache) Delete(key string) {
+	if _, err := c.db.Exec(`DELETE FROM iron_cache WHERE key = ?`, hashCacheKey(key)); err != nil {
+		log.Printf("iron cache delete: %v", err)
+	}
+}
+
+// Purge removes every entry.
+func (c *IronCache) Purge() {
+	if _, err := c.db.Exec(`DELETE FROM iron_cache`); err != nil {
+		log.Printf("iron cache purge: %v", err)
+	}
+}
+
+// Stats reports this process's hit and miss counters and the shared table size.
+func (c *IronCache) Stats() iron.CacheStats {
+	stats := iron.CacheStats{Hits: c.hits.Load(), Misses: c.misses.Load()}
+	var size sql.NullInt64
+	if err := c.db.QueryRow(`SELECT COUNT(*), SUM(size) FROM iron_cache`).Scan(&stats.Entries, &size); err != nil {
+		log.Printf("iron cache stats: %v", err)
+	}
+	stats.Bytes = size.Int64
+	return stats
+}
+
+// Vacuum deletes expired entries and returns how many were removed.
+func (c *IronCache) Vacuum() (int64, error) {
+	res, err := c.db.Exec(`DELETE FROM iron_cache WHERE expires_at IS NOT NULL AND expires_at <= ?`, c.now().Unix())
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// StartVacuum runs Vacuum every interval until ctx is done.
+func (c *IronCache) StartVacuum(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if _, err := c.Vacuum(); err != nil {
+					log.Printf("iron cache vacuum: %v", err)
+				}
+			}
+		}
+	}()
+}
+
+func encodeIronResult(result iron.Result) ([]byte, error) {
+	var buf bytes.Buffer
+	zw, err := gzip.NewWriterLevel(&buf, gzip.BestSpeed)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.NewEncoder(zw).Encode(result); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func decodeIronResult(value []byte) (iron.Result, error) {
+	zr, err := gzip.NewReader(bytes.NewReader(value))
+	if err != nil {
+		return iron.Result{}, err
+	}
+	defer zr.Close()
+	data, err := io.ReadAll(zr)
+	if err != nil {
+		return iron.Result{}, err
+	}
+	var result iron.Result
+	if err := json.Unmarshal(data, &result); err != nil {
+		return iron.Result{}, err
+	}
+	return result, nil
+}
@@ -0,0 +1,81 @@
+package db
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"agentic/iron"
+)
+
+func openTestDB(t *testing.T, path string) *DB {
+	t.Helper()
+	database, err := New(path)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	t.Cleanup(func() { database.Close() })
+	return database
+}
+
+func TestIronCache_SharedAcrossConnections(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "agent.db")
+	writer := NewIronCache(openTestDB(t, path), 0)
+	reader := NewIronCache(openTestDB(t, path), 0)
+
+	want := iron.Result{
+		Module: "IR-LOG",
+		Input:  strings.Repeat("GET /api/users 200\n", 50),
+		IR:     "@LOG[lossless]",
+		Output: "out",
+		Losses: []iron.Loss{{Kind: "values", Count: 2}},
+	}
+	writer.Set("key", want)
+
+	got, ok := reader.Get("key")
+	if !ok {
+		t.Fatalf("Get() ok = false, want true")
+	}
+	if got.Module != want.Module || got.Input != want.Input || len(got.Losses) != 1 {
+		t.Fatalf("Get() = %+v, want %+v", got, want)
+	}
+	stats := reader.Stats()
+	if stats.Entries != 1 || stats.Hits != 1 || stats.Bytes >= int64(len(want.Input)) {
+		t.Fatalf("Stats() = %+v, want one compressed entry and one hit", stats)
+	}
+
+	reader.Delete("key")
+	if _, ok := writer.Get("key"); ok {
+		t.Fatalf("Get() ok = true after Delete")
+	}
+}
+
+func TestIronCache_TTLAndVacuum(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	cache := NewIronCache(openTestDB(t, filepath.Join(t.TempDir(), "agent.db")), time.Minute)
+	cache.now = func() time.Time { return now }
+	cache.Set("a", iron.Result{Output: "A"})
+	cache.Set("b", iron.Result{Output: "B"})
+
+	if _, ok := cache.Get("a"); !ok {
+		t.Fatalf("Get(a) ok = false before TTL")
+	}
+	now = now.Add(2 * time.Minute)
+	if _, ok := cache.Get("a"); ok {
+		t.Fatalf("Get(a) ok = true after TTL")
+	}
+	removed, err := cache.Vacuum()
+	if err != nil {
+		t.Fatalf("Vacuum() error = %v", err)
+	}
+	if removed != 1 {
+		t.Fatalf("Vacuum() = %d, want 1", removed)
+	}
+
+	cache.Set("c", iron.Result{Output: "C"})
+	cache.Purge()
+	if stats := cache.Stats(); stats.Entries != 0 {
+		t.Fatalf("Stats() after Purge = %+v, want empty", stats)
+	}
+}
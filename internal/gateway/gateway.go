@@ -0,0 +1,345 @@
+package gateway
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+
+	"agentic/internal/config"
+	"agentic/iron"
+)
+
+// Part is a labelled section of an outgoing prompt. Header, if set, is
+// emitted verbatim above the possibly encoded Text.
+type Part struct {
+	Label  string
+	Header string
+	Text   string
+}
+
+// DictionaryStore persists the iron dictionary of each session.
+type DictionaryStore interface {
+	Dictionary(key string) *iron.Dictionary
+	SetDictionary(key string, dict *iron.Dictionary) error
+}
+
+// Compressor encodes outgoing prompt parts with an iron.Engine and decodes
+// IR replies. A nil Compressor passes text through unchanged.
+type Compressor struct {
+	Engine *iron.Engine
+	Level  iron.Level
+	// Redactor, if set, replaces secrets in prompts with placeholders that
+	// DecodeReply restores for the same label.
+	Redactor *iron.Redactor
+	// Sessions, if set, keeps a symbol dictionary per session for
+	// SessionPrompt.
+	Sessions DictionaryStore
+	// CompactReplies asks models for IR-META control lines instead of
+	// ir.Response JSON; see ResponseFormat.
+	CompactReplies bool
+
+	mu         sync.Mutex
+	redactions map[string][]iron.Redaction
+	// pending holds the dictionary of each session's last SessionPrompt
+	// until CommitSession saves it.
+	pending map[string]*iron.Dictionary
+}
+
+// New builds a Compressor from config, or returns nil when it is disabled.
+// Results are kept in cache, such as a persistent db.IronCache; nil uses an
+// in-memory LRU cache of cfg.CacheEntries. Lossless compressors also
+// validate key-term coverage so that no module silently drops content from
+// a prompt.
+func New(cfg config.IronConfig, cache iron.Cache) (*Compressor, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+	level := iron.LevelLossless
+	if cfg.Level != "" {
+		parsed, ok := iron.ParseLevel(cfg.Level)
+		if !ok {
+			return nil, fmt.Errorf("unknown iron level %q", cfg.Level)
+		}
+		level = parsed
+	}
+	if cache == nil {
+		cache = iron.NewLRUCache(cfg.CacheEntries, 0)
+	}
+	options := []iron.Option{iron.WithCache(cache), iron.WithSegmentation()}
+	if level == iron.LevelLossless {
+		options = append(options, iron.WithValidator(iron.KeyTermValidator{}))
+	}
+	c := &Compressor{Engine: iron.NewDefault(options...), Level: level, CompactReplies: cfg.CompactReplies}
+	if cfg.Redact {
+		rules, err := iron.RedactionRulesFor(cfg.RedactKinds...)
+		if err != nil {
+			return nil, err
+		}
+		c.Redactor = iron.NewRedactor(rules...)
+	}
+	return c, nil
+}
+
+// Register adds modules, such as addon modules, to the engine.
+func (c *Compressor) Register(modules ...iron.IRModule) error {
+	if c == nil {
+		return nil
+	}
+	for _, module := range modules {
+		if err := c.Engine.RegisterModule(module); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Prompt joins the non-empty parts with blank lines, replacing each with its
+// IR when that is smaller, and logs the token savings under label. The raw
+// text is kept when the IR legend would outweigh the savings.
+func (c *Compressor) Prompt(ctx context.Context, label string, parts ...Part) string {
+	return c.prompt(ctx, label, false, false, parts)
+}
+
+// SessionPrompt is Prompt for a conversation the model remembers: recurring
+// phrases are replaced by aliases from the session's dictionary, which are
+// defined once in a @DEF preamble. fresh reports that the model starts the
+// conversation anew, so the aliases it uses are defined again. The
+// dictionary is saved by CommitSession once the model has the prompt.
+func (c *Compressor) SessionPrompt(ctx context.Context, key string, fresh bool, parts ...Part) string {
+	return c.prompt(ctx, key, true, fresh, parts)
+}
+
+// CommitSession saves the dictionary of key's last SessionPrompt, marking
+// its definitions as sent. Call it only once the model has received the
+// prompt; until then later prompts define the same aliases again.
+func (c *Compressor) CommitSession(key string) {
+	if c == nil || c.Sessions == nil {
+		return
+	}
+	c.mu.Lock()
+	dict, ok := c.pending[key]
+	delete(c.pending, key)
+	c.mu.Unlock()
+	if !ok {
+		return
+	}
+	if err := c.Sessions.SetDictionary(key, dict); err != nil {
+		log.Printf("iron dictionary %s: %v", key, err)
+	}
+}
+
+func (c *Compressor) prompt(ctx context.Context, label string, session, fresh bool, parts []Part) string {
+	var (
+		out        []string
+		raw        []string
+		encoded    []string
+		used       = map[string]bool{}
+		redactions []iron.Redaction
+		before     int
+		after      int
+		tokenizer  iron.Tokenizer
+	)
+	if c != nil {
+		// Count with the engine's tokenizer so that the fallback agrees
+		// with the token counts of each Result.
+		tokenizer = c.Engine.Tokenizer()
+		// Continue the label's mapping so placeholders stay stable across
+		// the turns of a conversation.
+		redactions = c.lookup(label)
+	}
+	for _, part := range parts {
+		text := strings.TrimSpace(part.Text)
+		if text == "" && part.Header == "" {
+			continue
+		}
+		if c != nil && c.Redactor != nil {
+			text, redactions = c.Redactor.RedactMore(text, redactions)
+		}
+		plain := text
+		if c != nil && text != "" {
+			before += tokenizer.Count(text)
+			if result, ok := c.encode(ctx, text); ok {
+				encoded = append(encoded, part.Label+"="+result.Module)
+				used[result.Module] = true
+				for _, segment := range result.Segments {
+					used[segment.Module] = true
+				}
+				text = result.IR
+			}
+			after += tokenizer.Count(text)
+		}
+		if part.Header != "" {
+			text = strings.TrimSuffix(part.Header+"\n"+text, "\n")
+			plain = strings.TrimSuffix(part.Header+"\n"+plain, "\n")
+		}
+		out = append(out, text)
+		raw = append(raw, plain)
+	}
+	if c == nil {
+		return strings.Join(out, "\n\n")
+	}
+	c.remember(label, redactions)
+	if len(encoded) > 0 {
+		text := legendFor(used)
+		out = append([]string{text}, out...)
+		after += tokenizer.Count(text)
+	}
+	if after >= before {
+		// The legend costs more than the encoding saved.
+		out, after, encoded = raw, before, nil
+	}
+	prompt := strings.Join(out, "\n\n")
+	aliases := 0
+	if session && c.Sessions != nil {
+		var defined bool
+		prompt, aliases, defined = c.applyDictionary(label, prompt, fresh)
+		if defined {
+			prompt = legendDictionary + "\n" + prompt
+		}
+		after = tokenizer.Count(prompt)
+	}
+	saved := 0.0
+	if before > 0 {
+		saved = 100 * float64(before-after) / float64(before)
+	}
+	log.Printf("iron %s: %d -> %d tokens (%.0f%% saved) encoded=[%s] redacted=[%s] aliases=%d",
+		label, before, after, saved, strings.Join(encoded, " "), redactionSummary(redactions), aliases)
+	return prompt
+}
+
+// applyDictionary learns from the prompt and substitutes the session's
+// aliases, keeping the dictionary for CommitSession. It returns the prompt,
+// the alias count, and whether the prompt defines aliases.
+func (c *Compressor) applyDictionary(key, prompt string, fresh bool) (string, int, bool) {
+	dict := c.Sessions.Dictionary(key)
+	if dict == nil {
+		dict = iron.NewDictionary()
+	}
+	if fresh {
+		dict.Resend()
+	}
+	dict.Learn(prompt)
+	unsent := unsentEntries(dict)
+	prompt = dict.Apply(prompt)
+	defined := unsentEntries(dict) < unsent
+	c.mu.Lock()
+	if c.pending == nil {
+		c.pending = map[string]*iron.Dictionary{}
+	}
+	c.pending[key] = dict
+	c.mu.Unlock()
+	return prompt, len(dict.Entries), defined
+}
+
+// unsentEntries counts the aliases whose definition was not yet emitted.
+func unsentEntries(dict *iron.Dictionary) int {
+	n := 0
+	for _, entry := range dict.Entries {
+		if !entry.Sent {
+			n++
+		}
+	}
+	return n
+}
+
+// encode returns the result for text when a module other than the
+// passthrough makes it smaller.
+func (c *Compressor) encode(ctx context.Context, text string) (iron.Result, bool) {
+	result, err := c.Engine.ProcessContext(ctx, text, iron.AtLevel(c.Level))
+	if err != nil {
+		log.Printf("iron encode: %v", err)
+		return iron.Result{}, false
+	}
+	if result.Module == "" || result.Module == (iron.PassthroughModule{}).Name() || result.IRTokens >= result.InputTokens {
+		return iron.Result{}, false
+	}
+	return result, true
+}
+
+// ResponseSchema asks the model to answer in ir.Response JSON.
+const ResponseSchema = `Return ONLY a single JSON object with:
+- "reply": short Telegram message (max 2 lines, no markdown)
+- "ir": machine action with fields:
+  action: act_now|schedule|ask|defer
+  intent: string
+  risk: none|low|medium|high
+  when: cron (5-field) optional
+  tools: [{name, args}] optional
+  confidence: 0..1`
+
+// ResponseFormat returns the prompt part that sets the reply format:
+// IR-META control lines when compact replies are enabled, which DecodeReply
+// turns back into validated ir.Response JSON, and ResponseSchema otherwise.
+func (c *Compressor) ResponseFormat() Part {
+	if c == nil || !c.CompactReplies {
+		return Part{Label: "format", Header: ResponseSchema}
+	}
+	return Part{Label: "format", Header: iron.MetaInstructions}
+}
+
+// DecodeReply expands session aliases and restores a model reply written in
+// IR and the values redacted from prompts sent under label; other replies
+// are returned unchanged.
+func (c *Compressor) DecodeReply(ctx context.Context, label, reply string) string {
+	if c == nil {
+		return reply
+	}
+	if c.Sessions != nil {
+		if dict := c.Sessions.Dictionary(label); dict != nil {
+			reply = dict.Expand(reply)
+		}
+	}
+	if strings.HasPrefix(strings.TrimSpace(reply), "@") {
+		if result, err := c.Engine.Decode(ctx, strings.TrimSpace(reply)); err == nil {
+			log.Printf("iron reply decoded by %s", result.Module)
+			reply = result.Output
+		}
+	}
+	return iron.Restore(reply, c.lookup(label))
+}
+
+// Forget drops the placeholder mapping of label, such as when a chat
+// session is reset.
+func (c *Compressor) Forget(label string) {
+	if c == nil {
+		return
+	}
+	c.remember(label, nil)
+}
+
+func (c *Compressor) lookup(label string) []iron.Redaction {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.redactions[label]
+}
+
+// remember keeps the redactions of the prompts sent under label.
+func (c *Compressor) remember(label string, redactions []iron.Redaction) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(redactions) == 0 {
+		delete(c.redactions, label)
+		return
+	}
+	if c.redactions == nil {
+		c.redactions = map[string][]iron.Redaction{}
+	}
+	c.redactions[label] = redactions
+}
+
+// redactionSummary counts redactions per kind without revealing values.
+func redactionSummary(redactions []iron.Redaction) string {
+	counts := map[string]int{}
+	for _, redaction := range redactions {
+		counts[redaction.Kind]++
+	}
+	kinds := make([]string, 0, len(counts))
+	for kind, count := range counts {
+		kinds = append(kinds, fmt.Sprintf("%s:%d", kind, count))
+	}
+	sort.Strings(kinds)
+	return strings.Join(kinds, " ")
+}
@@ -0,0 +1,265 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"agentic/internal/config"
+	"agentic/internal/ir"
+	"agentic/iron"
+)
+
+func TestCompressor_Nil_PassesThrough(t *testing.T) {
+	var c *Compressor
+	got := c.Prompt(context.Background(), "test", Part{Label: "system", Text: "be brief\n"}, Part{Label: "empty"}, Part{Label: "user", Text: "hi"})
+	if got != "be brief\n\nhi" {
+		t.Fatalf("Prompt() = %q", got)
+	}
+	if reply := c.DecodeReply(context.Background(), "test", "@TASK{a->b}"); reply != "@TASK{a->b}" {
+		t.Fatalf("DecodeReply() = %q", reply)
+	}
+}
+
+func TestNew_Disabled(t *testing.T) {
+	c, err := New(config.IronConfig{}, nil)
+	if err != nil || c != nil {
+		t.Fatalf("New() = %v, %v; want nil, nil", c, err)
+	}
+	if _, err := New(config.IronConfig{Enabled: true, Level: "extreme"}, nil); err == nil {
+		t.Fatal("New() error = nil for unknown level")
+	}
+}
+
+func TestNew_UsesGivenCache(t *testing.T) {
+	cache := iron.NewLRUCache(8, 0)
+	c, err := New(config.IronConfig{Enabled: true}, cache)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	c.Prompt(context.Background(), "test", Part{Label: "df", Text: `[{"name":"disk","usage":"42%"},{"name":"swap","usage":"3%"}]`})
+	if stats := cache.Stats(); stats.Entries == 0 {
+		t.Fatalf("cache stats = %+v, want the prompt's entries", stats)
+	}
+}
+
+func TestCompressor_Prompt_EncodesToolOutput(t *testing.T) {
+	c, err := New(config.IronConfig{Enabled: true, Level: "lossless", CacheEntries: 8}, nil)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	var rows []string
+	for i := 0; i < 20; i++ {
+		rows = append(rows, `{"id":`+strings.Repeat("1", i+1)+`,"name":"disk","status":"ok","usage":"42%"}`)
+	}
+	output := "[" + strings.Join(rows, ",") + "]"
+
+	got := c.Prompt(context.Background(), "test",
+		Part{Label: "prompt", Text: "Summarize the disk report."},
+		Part{Label: "df", Header: "Tool 'df' Output:", Text: output},
+	)
+	if !strings.HasPrefix(got, legend) {
+		t.Fatalf("Prompt() missing legend:\n%s", got)
+	}
+	if strings.Contains(got, "@DEF") {
+		t.Fatalf("Prompt() explains aliases it cannot define:\n%s", got)
+	}
+	if !strings.Contains(got, "Summarize the disk report.") || !strings.Contains(got, "Tool 'df' Output:\n@DATA") {
+		t.Fatalf("Prompt() = %q", got)
+	}
+	if len(got) >= len(output) {
+		t.Fatalf("Prompt() len = %d, want < %d", len(got), len(output))
+	}
+}
+
+func TestCompressor_Prompt_KeepsRawWhenLegendCostsMore(t *testing.T) {
+	c, err := New(config.IronConfig{Enabled: true, CacheEntries: 8}, nil)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	got := c.Prompt(context.Background(), "test", Part{Label: "user", Text: "what time is it?"})
+	if got != "what time is it?" {
+		t.Fatalf("Prompt() = %q", got)
+	}
+}
+
+// countingTokenizer records the texts it counts.
+type countingTokenizer struct{ texts *[]string }
+
+func (countingTokenizer) Name() string { return "counting" }
+
+func (c countingTokenizer) Count(text string) int {
+	*c.texts = append(*c.texts, text)
+	return len(strings.Fields(text))
+}
+
+func TestCompressor_Prompt_CountsWithEngineTokenizer(t *testing.T) {
+	var texts []string
+	c := &Compressor{Engine: iron.NewDefault(iron.WithTokenizer(countingTokenizer{&texts}))}
+	c.Prompt(context.Background(), "test", Part{Label: "user", Text: "what time is it?"})
+	if !containsString(texts, "what time is it?") {
+		t.Fatalf("tokenizer counted %q, want the prompt text", texts)
+	}
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func TestCompressor_DecodeReply(t *testing.T) {
+	c, err := New(config.IronConfig{Enabled: true, CacheEntries: 8}, nil)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	plain := `{"reply":"ok"}`
+	if got := c.DecodeReply(context.Background(), "test", plain); got != plain {
+		t.Fatalf("DecodeReply() = %q", got)
+	}
+	if got := c.DecodeReply(context.Background(), "test", "@UNKNOWN junk"); got != "@UNKNOWN junk" {
+		t.Fatalf("DecodeReply() = %q", got)
+	}
+
+	data := `[{"id":1,"name":"disk","status":"ok"},{"id":2,"name":"swap","status":"ok"},{"id":3,"name":"cpu","status":"warn"}]`
+	result, err := c.Engine.ProcessDetailed(data)
+	if err != nil {
+		t.Fatalf("ProcessDetailed() error = %v", err)
+	}
+	if result.Module != "IR-DATA" || result.IR == data {
+		t.Fatalf("ProcessDetailed() module = %q, want the sample encoded by IR-DATA", result.Module)
+	}
+	if got := c.DecodeReply(context.Background(), "test", result.IR); got != result.Output {
+		t.Fatalf("DecodeReply() = %q, want %q", got, result.Output)
+	}
+}
+
+func TestCompressor_ResponseFormat_DecodesCompactReplies(t *testing.T) {
+	c, err := New(config.IronConfig{Enabled: true, CacheEntries: 8}, nil)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	if format := c.ResponseFormat(); format.Header != ResponseSchema {
+		t.Fatalf("ResponseFormat() = %+v with compact replies disabled", format)
+	}
+	if format := (*Compressor)(nil).ResponseFormat(); format.Header != ResponseSchema {
+		t.Fatalf("nil ResponseFormat() = %+v", format)
+	}
+	c.CompactReplies = true
+	format := c.ResponseFormat()
+	if format.Header != iron.MetaInstructions || strings.Contains(format.Header, "JSON object") {
+		t.Fatalf("ResponseFormat() = %+v", format)
+	}
+	if got := c.Prompt(context.Background(), "test", Part{Label: "user", Text: "hi"}, format); !strings.HasSuffix(got, iron.MetaInstructions) {
+		t.Fatalf("Prompt() = %q", got)
+	}
+
+	got := c.DecodeReply(context.Background(), "test", "@META A:act_now R:low C:.9 T:list_add(list=groceries, item=milk)\nI:add milk\nM:Added.")
+	var resp ir.Response
+	if err := json.Unmarshal([]byte(got), &resp); err != nil {
+		t.Fatalf("DecodeReply() = %q: %v", got, err)
+	}
+	if resp.Reply != "Added." || resp.IR == nil || resp.IR.Validate() != nil || resp.IR.Tools[0].Name != "list_add" {
+		t.Fatalf("DecodeReply() = %q", got)
+	}
+	if invalid := "@META A:launch"; c.DecodeReply(context.Background(), "test", invalid) != invalid {
+		t.Fatal("DecodeReply() accepted an invalid action")
+	}
+}
+
+func TestCompressor_Redaction(t *testing.T) {
+	c, err := New(config.IronConfig{Enabled: true, CacheEntries: 8, Redact: true, RedactKinds: []string{"email"}}, nil)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	prompt := c.Prompt(context.Background(), "chat 1", Part{Label: "user", Text: "email ana@example.com the report"})
+	if strings.Contains(prompt, "ana@example.com") || !strings.Contains(prompt, "<EMAIL_1>") {
+		t.Fatalf("Prompt() = %q", prompt)
+	}
+	next := c.Prompt(context.Background(), "chat 1", Part{Label: "user", Text: "also bob@example.com"})
+	if !strings.Contains(next, "<EMAIL_2>") {
+		t.Fatalf("Prompt() = %q, want the mapping to continue", next)
+	}
+	reply := c.DecodeReply(context.Background(), "chat 1", `{"reply":"sent to <EMAIL_1> and <EMAIL_2>"}`)
+	if reply != `{"reply":"sent to ana@example.com and bob@example.com"}` {
+		t.Fatalf("DecodeReply() = %q", reply)
+	}
+	c.Forget("chat 1")
+	if got := c.DecodeReply(context.Background(), "chat 1", "<EMAIL_1>"); got != "<EMAIL_1>" {
+		t.Fatalf("DecodeReply() after Forget = %q", got)
+	}
+	if _, err := New(config.IronConfig{Enabled: true, Redact: true, RedactKinds: []string{"ssn"}}, nil); err == nil {
+		t.Fatal("New() error = nil for unknown redaction kind")
+	}
+}
+
+type memoryDictionaries map[string]*iron.Dictionary
+
+func (m memoryDictionaries) Dictionary(key string) *iron.Dictionary {
+	if dict := m[key]; dict != nil {
+		return dict.Clone()
+	}
+	return nil
+}
+
+func (m memoryDictionaries) SetDictionary(key string, dict *iron.Dictionary) error {
+	m[key] = dict
+	return nil
+}
+
+func TestCompressor_SessionPrompt_Dictionary(t *testing.T) {
+	c, err := New(config.IronConfig{Enabled: true, CacheEntries: 8}, nil)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	dicts := memoryDictionaries{}
+	c.Sessions = dicts
+
+	first := c.SessionPrompt(context.Background(), "telegram:1", true, Part{Label: "user", Text: "open cmd/agent/main.go please"})
+	if first != "open cmd/agent/main.go please" {
+		t.Fatalf("SessionPrompt() = %q", first)
+	}
+	c.CommitSession("telegram:1")
+	second := c.SessionPrompt(context.Background(), "telegram:1", false, Part{Label: "user", Text: "now edit cmd/agent/main.go"})
+	if second != legendDictionary+"\n@DEF ^1=cmd/agent/main.go\nnow edit ^1" {
+		t.Fatalf("SessionPrompt() = %q", second)
+	}
+	c.CommitSession("telegram:1")
+	if got := c.Prompt(context.Background(), "telegram:1", Part{Label: "user", Text: "cmd/agent/main.go"}); got != "cmd/agent/main.go" {
+		t.Fatalf("Prompt() = %q, want no aliases outside sessions", got)
+	}
+	if got := c.DecodeReply(context.Background(), "telegram:1", `{"reply":"edited ^1"}`); got != `{"reply":"edited cmd/agent/main.go"}` {
+		t.Fatalf("DecodeReply() = %q", got)
+	}
+}
+
+func TestCompressor_SessionPrompt_DefinesUntilCommitted(t *testing.T) {
+	c, err := New(config.IronConfig{Enabled: true, CacheEntries: 8}, nil)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	c.Sessions = memoryDictionaries{}
+	key := "telegram:1"
+	c.SessionPrompt(context.Background(), key, true, Part{Label: "user", Text: "open cmd/agent/main.go please"})
+	c.CommitSession(key)
+
+	const defined = legendDictionary + "\n@DEF ^1=cmd/agent/main.go\nnow edit ^1"
+	// The model never received the first definition, so it is sent again.
+	if got := c.SessionPrompt(context.Background(), key, false, Part{Label: "user", Text: "now edit cmd/agent/main.go"}); got != defined {
+		t.Fatalf("SessionPrompt() = %q", got)
+	}
+	if got := c.SessionPrompt(context.Background(), key, false, Part{Label: "user", Text: "now edit cmd/agent/main.go"}); got != defined {
+		t.Fatalf("SessionPrompt() before commit = %q, want %q", got, defined)
+	}
+	c.CommitSession(key)
+	if got := c.SessionPrompt(context.Background(), key, false, Part{Label: "user", Text: "now edit cmd/agent/main.go"}); got != "now edit ^1" {
+		t.Fatalf("SessionPrompt() after commit = %q", got)
+	}
+	if got := c.SessionPrompt(context.Background(), key, true, Part{Label: "user", Text: "now edit cmd/agent/main.go"}); got != defined {
+		t.Fatalf("SessionPrompt() in a fresh conversation = %q, want %q", got, defined)
+	}
+}
@@ -0,0 +1,46 @@
+package gateway
+
+import "strings"
+
+// legend tells the model how to read IR sections in a compressed prompt;
+// legendFor completes it with the notation of the modules used.
+const legend = `Sections starting with "@" are compact IR`
+
+// legendModules describes the notation of each built-in module.
+var legendModules = []struct{ module, text string }{
+	{"IR-TASK", `@TASK[formats] steps as "OP object|OP object"`},
+	{"IR-DATA", `@DATA JSON as "#n:keys" schemas and "#n(values)" rows`},
+	{"IR-LOG", `@LOG "@Tn" templates with "@n v1|v2" values`},
+	{"IR-CODE", `@CODE source without comments or indentation`},
+	{"IR-WEB", `@WEB pages as "Hn"/"P"/"L"/"R" lines with "[text][n]" links defined by "@n url"`},
+	{"IR-KNOW", `@KNOW{query} context chunks ranked by relevance, each tagged "[Sn source]" (cite them as [Sn])`},
+	{"IR-PIPE", `@PIPE workflows as "tool(arg=value) |> llm(prompt) |> send(target=id)"`},
+	{"IR-META", `@META agent replies as "A:action R:risk C:confidence T:tool(arg=value)" with "I:intent", "W:when", and "M:reply" lines`},
+}
+
+const (
+	// segmentModule is the module of segmented results.
+	segmentModule  = "IR-SEG"
+	legendSegments = `"@SEG[kind,module,n]" marks the next n lines as one region encoded by module ("-" for plain text).`
+	// legendDictionary precedes the @DEF lines of a session prompt.
+	legendDictionary = `"@DEF ^n=phrase" defines the alias ^n for this and later messages.`
+)
+
+// legendFor returns the legend for a prompt encoded by the given modules.
+func legendFor(used map[string]bool) string {
+	var notations []string
+	for _, entry := range legendModules {
+		if used[entry.module] {
+			notations = append(notations, entry.text)
+		}
+	}
+	text := legend
+	if len(notations) > 0 {
+		text += ": " + strings.Join(notations, ", ")
+	}
+	text += "."
+	if used[segmentModule] {
+		text += " " + legendSegments
+	}
+	return text
+}
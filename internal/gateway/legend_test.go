@@ -0,0 +1,21 @@
+package gateway
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLegendFor_DescribesModulesUsed(t *testing.T) {
+	got := legendFor(map[string]bool{"IR-DATA": true})
+	if !strings.HasPrefix(got, legend+": @DATA") || strings.Contains(got, "@WEB") || strings.Contains(got, "@SEG") {
+		t.Fatalf("legendFor(IR-DATA) = %q, want only the @DATA notation", got)
+	}
+	if strings.Contains(got, "@DEF") {
+		t.Fatalf("legendFor(IR-DATA) = %q, want no @DEF notation", got)
+	}
+
+	got = legendFor(map[string]bool{segmentModule: true, "IR-LOG": true, "IR-PIPE": true})
+	if !strings.Contains(got, "@LOG") || !strings.Contains(got, "@PIPE") || !strings.Contains(got, legendSegments) || strings.Contains(got, "@DATA") {
+		t.Fatalf("legendFor(IR-SEG) = %q, want the segment and region notations", got)
+	}
+}
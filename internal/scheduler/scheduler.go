@@ -13,8 +13,10 @@ import (
 	"agentic/internal/codex"
 	"agentic/internal/config"
 	"agentic/internal/db"
+	"agentic/internal/gateway"
 	"agentic/internal/ir"
 	"agentic/internal/tools"
+	"agentic/iron"
 
 	"github.com/robfig/cron/v3"
 )
@@ -66,6 +68,8 @@ type Scheduler struct {
 	tools    *tools.Registry
 	store    JobStore
 
+	compressor *gateway.Compressor
+
 	mu         sync.Mutex
 	memCron    map[cron.EntryID]string
 	memOneShot map[string]string
@@ -91,6 +95,11 @@ func New(codexClient *codex.Client, adaptersReg *adapters.Registry, toolsReg *to
 	return s
 }
 
+// SetCompressor enables IR compression of LLM job prompts; nil disables it.
+func (s *Scheduler) SetCompressor(c *gateway.Compressor) {
+	s.compressor = c
+}
+
 func (s *Scheduler) Start() {
 	s.cron.Start()
 }
@@ -115,7 +124,7 @@ func (s *Scheduler) RegisterTasks(tasks []config.TaskConfig) error {
 }
 
 func (s *Scheduler) runTask(task config.TaskConfig) error {
-	var toolOutputs strings.Builder
+	var toolOutputs []iron.Chunk
 	hasTools := len(task.Tools) > 0
 	hasPrompt := task.Prompt != ""
 
@@ -131,7 +140,7 @@ func (s *Scheduler) runTask(task config.TaskConfig) error {
 			tool := s.tools.Get(req.Name)
 			if tool == nil {
 				log.Printf("task %s: tool not found: %s", task.ID, req.Name)
-				toolOutputs.WriteString(fmt.Sprintf("[Error] Tool %s not found\n", req.Name))
+				toolOutputs = append(toolOutputs, iron.Chunk{Source: req.Name, Text: fmt.Sprintf("[Error] Tool %s not found", req.Name)})
 				results = append(results, toolResult{name: req.Name, err: fmt.Errorf("tool not found")})
 				continue
 			}
@@ -143,7 +152,7 @@ func (s *Scheduler) runTask(task config.TaskConfig) error {
 			results = append(results, toolResult{name: req.Name, err: err})
 
 			// Capture output
-			toolOutputs.WriteString(fmt.Sprintf("Tool '%s' Output:\n%s\n\n", req.Name, output))
+			toolOutputs = append(toolOutputs, iron.Chunk{Source: req.Name, Text: output})
 
 			// Mode 1: Tools ONLY (No Prompt) -> Send outputs immediately as they come (or batched? immediate is fine)
 			if !hasPrompt {
@@ -176,16 +185,26 @@ func (s *Scheduler) runTask(task config.TaskConfig) error {
 
 	// Mode 2 & 3: LLM (with or without tool context)
 	if hasPrompt {
-		fullPrompt := task.Prompt
-		if toolOutputs.Len() > 0 {
-			fullPrompt += "\n\n=== Context from scheduled tools ===\n" + toolOutputs.String()
+		parts := []gateway.Part{{Label: "prompt", Text: task.Prompt}}
+		if len(toolOutputs) > 0 {
+			// Each tool output is a chunk under its tool's name, ranked by
+			// the prompt so that IR-KNOW can drop what repeats. The prompt
+			// is the chunks' query, so it is not sent again on its own.
+			parts = []gateway.Part{{
+				Label:  "tools",
+				Header: "=== Task, with context from scheduled tools ===",
+				Text:   iron.FormatChunks(task.Prompt, toolOutputs),
+			}}
 		}
+		label := "task " + task.ID
+		fullPrompt := s.compressor.Prompt(context.Background(), label, parts...)
 
 		s.sendStatus(task, "Status: analisando...")
 		resp, err := s.codex.Exec(context.Background(), "", "", fullPrompt, true)
 		if err != nil {
 			return err
 		}
+		reply := s.compressor.DecodeReply(context.Background(), label, resp.Text)
 		s.sendStatus(task, "Status: pronto.")
 
 		adapter := s.adapters.Get(task.Adapter)
@@ -193,7 +212,7 @@ func (s *Scheduler) runTask(task config.TaskConfig) error {
 			return nil
 		}
 		for _, target := range task.Targets {
-			if err := adapter.Send(context.Background(), target, resp.Text); err != nil {
+			if err := adapter.Send(context.Background(), target, reply); err != nil {
 				log.Printf("task %s send error: %v", task.ID, err)
 			}
 		}
@@ -5,6 +5,8 @@ import (
 	"os"
 	"path/filepath"
 	"sync"
+
+	"agentic/iron"
 )
 
 type SessionStore struct {
@@ -14,9 +16,10 @@ type SessionStore struct {
 }
 
 type SessionState struct {
-	ID      string `json:"id"`
-	Dir     string `json:"dir,omitempty"`
-	UseLast bool   `json:"use_last,omitempty"`
+	ID         string           `json:"id"`
+	Dir        string           `json:"dir,omitempty"`
+	UseLast    bool             `json:"use_last,omitempty"`
+	Dictionary *iron.Dictionary `json:"dictionary,omitempty"`
 }
 
 func NewSessionStore(dataDir string) (*SessionStore, error) {
@@ -74,6 +77,26 @@ func (s *SessionStore) SetUseLast(key string, value bool) error {
 	return s.save()
 }
 
+// Dictionary returns a copy of the session's iron dictionary, or nil.
+func (s *SessionStore) Dictionary(key string) *iron.Dictionary {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if dict := s.sessions[key].Dictionary; dict != nil {
+		return dict.Clone()
+	}
+	return nil
+}
+
+// SetDictionary stores the session's iron dictionary; nil clears it.
+func (s *SessionStore) SetDictionary(key string, dict *iron.Dictionary) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	state := s.sessions[key]
+	state.Dictionary = dict
+	s.sessions[key] = state
+	return s.save()
+}
+
 func (s *SessionStore) load() error {
 	data, err := os.ReadFile(s.path)
 	if err != nil {
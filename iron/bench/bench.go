@@ -0,0 +1,291 @@
+// Package bench measures the iron modules on a versioned corpus of
+// real-world inputs: token savings, key-term recall, and structural diff.
+// A Report can be compared with a stored baseline to catch regressions.
+package bench
+
+import (
+	"context"
+	"embed"
+	"fmt"
+	"io/fs"
+	"math"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"agentic/iron"
+)
+
+// CorpusVersion names the corpus directory that Corpus reads. Changing the
+// corpus means adding a new version and regenerating the baseline.
+const CorpusVersion = "v1"
+
+// noModule is the Module of cases that every module rejected.
+const noModule = "(none)"
+
+//go:embed corpus
+var corpusFS embed.FS
+
+// Case is one corpus input. Domain is the directory it was read from, such
+// as "log" or "web".
+type Case struct {
+	Name   string
+	Domain string
+	Input  string
+}
+
+// Corpus returns the cases of CorpusVersion in name order. Files named
+// "x.go.txt" and the like hold source code; the ".txt" keeps the go tool
+// from compiling them.
+func Corpus() ([]Case, error) {
+	root := path.Join("corpus", CorpusVersion)
+	var cases []Case
+	err := fs.WalkDir(corpusFS, root, func(name string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		data, err := corpusFS.ReadFile(name)
+		if err != nil {
+			return err
+		}
+		rel := strings.TrimPrefix(name, root+"/")
+		cases = append(cases, Case{Name: rel, Domain: path.Dir(rel), Input: string(data)})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(cases, func(i, j int) bool { return cases[i].Name < cases[j].Name })
+	return cases, nil
+}
+
+// Report is the outcome of running an engine over a corpus.
+type Report struct {
+	Version string         `json:"version"`
+	Modules []ModuleReport `json:"modules"`
+	Cases   []CaseReport   `json:"cases"`
+}
+
+// CaseReport is the outcome of one case.
+type CaseReport struct {
+	Name           string  `json:"name"`
+	Module         string  `json:"module"`
+	InputTokens    int     `json:"input_tokens"`
+	IRTokens       int     `json:"ir_tokens"`
+	KeyTermRecall  float64 `json:"key_term_recall"`
+	StructuralDiff float64 `json:"structural_diff"`
+	SemanticLoss   float64 `json:"semantic_loss"`
+	// Lossy is set when the module reported what it dropped.
+	Lossy bool `json:"lossy,omitempty"`
+	// Durations depend on the machine, so they are not kept in baselines.
+	Encode time.Duration `json:"-"`
+	Decode time.Duration `json:"-"`
+}
+
+// ModuleReport aggregates the cases a module handled. Metrics other than
+// Savings are means over the cases.
+type ModuleReport struct {
+	Module         string        `json:"module"`
+	Cases          int           `json:"cases"`
+	InputTokens    int           `json:"input_tokens"`
+	IRTokens       int           `json:"ir_tokens"`
+	Savings        float64       `json:"savings"`
+	KeyTermRecall  float64       `json:"key_term_recall"`
+	StructuralDiff float64       `json:"structural_diff"`
+	SemanticLoss   float64       `json:"semantic_loss"`
+	Encode         time.Duration `json:"-"`
+	Decode         time.Duration `json:"-"`
+}
+
+// Measure processes every case with engine, bypassing its cache, and
+// groups the outcomes by the module that handled them.
+func Measure(ctx context.Context, engine *iron.Engine, cases []Case) (Report, error) {
+	report := Report{Version: CorpusVersion}
+	modules := map[string]*ModuleReport{}
+	for _, c := range cases {
+		result, err := engine.ProcessContext(ctx, c.Input, iron.SkipCache())
+		if err != nil {
+			return Report{}, fmt.Errorf("%s: %w", c.Name, err)
+		}
+		module := result.Module
+		if module == "" {
+			module = noModule
+		}
+		input := strings.TrimSpace(c.Input)
+		cr := CaseReport{
+			Name:           c.Name,
+			Module:         module,
+			InputTokens:    result.InputTokens,
+			IRTokens:       result.IRTokens,
+			KeyTermRecall:  round(KeyTermRecall(input, result.Output)),
+			StructuralDiff: round(StructuralDiff(input, result.Output)),
+			SemanticLoss:   round(SemanticLoss(input, result.Output)),
+			Lossy:          len(result.Losses) > 0,
+			Encode:         result.EncodeDuration,
+			Decode:         result.DecodeDuration,
+		}
+		report.Cases = append(report.Cases, cr)
+
+		mr := modules[cr.Module]
+		if mr == nil {
+			mr = &ModuleReport{Module: cr.Module}
+			modules[cr.Module] = mr
+		}
+		mr.Cases++
+		mr.InputTokens += cr.InputTokens
+		mr.IRTokens += cr.IRTokens
+		mr.KeyTermRecall += cr.KeyTermRecall
+		mr.StructuralDiff += cr.StructuralDiff
+		mr.SemanticLoss += cr.SemanticLoss
+		mr.Encode += cr.Encode
+		mr.Decode += cr.Decode
+	}
+	for _, mr := range modules {
+		n := float64(mr.Cases)
+		if mr.InputTokens > 0 {
+			mr.Savings = round(1 - float64(mr.IRTokens)/float64(mr.InputTokens))
+		}
+		mr.KeyTermRecall = round(mr.KeyTermRecall / n)
+		mr.StructuralDiff = round(mr.StructuralDiff / n)
+		mr.SemanticLoss = round(mr.SemanticLoss / n)
+		mr.Encode /= time.Duration(mr.Cases)
+		mr.Decode /= time.Duration(mr.Cases)
+		report.Modules = append(report.Modules, *mr)
+	}
+	sort.Slice(report.Modules, func(i, j int) bool { return report.Modules[i].Module < report.Modules[j].Module })
+	return report, nil
+}
+
+// Thresholds are the largest changes from a baseline that do not count as
+// regressions, and the semantic-loss floor that holds whatever the
+// baseline says.
+type Thresholds struct {
+	Savings        float64
+	KeyTermRecall  float64
+	StructuralDiff float64
+	// SemanticLoss bounds each case whose module reported no losses.
+	SemanticLoss float64
+}
+
+// DefaultThresholds tolerate rounding noise and little else, and hold
+// modules that claim fidelity to the README target of under 1% semantic
+// loss.
+var DefaultThresholds = Thresholds{Savings: 0.02, KeyTermRecall: 0.01, StructuralDiff: 0.02, SemanticLoss: 0.01}
+
+// Regression is a metric that got worse than its baseline or floor allows.
+// Case is set for the metrics of a single case; a case now handled by
+// another module is reported under the metric "module", with that module in
+// Moved.
+type Regression struct {
+	Module   string
+	Case     string
+	Metric   string
+	Baseline float64
+	Current  float64
+	Moved    string
+}
+
+func (r Regression) String() string {
+	name := r.Module
+	if r.Case != "" {
+		name += " " + r.Case
+	}
+	if r.Metric == "module" {
+		return fmt.Sprintf("%s: moved to %s", name, r.Moved)
+	}
+	return fmt.Sprintf("%s %s: %.3f -> %.3f", name, r.Metric, r.Baseline, r.Current)
+}
+
+// Compare returns the regressions of current against baseline, per module
+// and per case. A module that no longer handles any case is reported under
+// the metric "cases", and a case must keep its module, its IR size within
+// the savings threshold, and its key-term recall. Whatever the baseline, every module other than the passthrough must save
+// tokens, no case may grow, and cases whose module reported no losses must
+// stay under the semantic-loss floor.
+func Compare(baseline, current Report, thresholds Thresholds) []Regression {
+	byModule := map[string]ModuleReport{}
+	for _, mr := range current.Modules {
+		byModule[mr.Module] = mr
+	}
+	var regressions []Regression
+	for _, base := range baseline.Modules {
+		cur, ok := byModule[base.Module]
+		if !ok {
+			regressions = append(regressions, Regression{Module: base.Module, Metric: "cases", Baseline: float64(base.Cases)})
+			continue
+		}
+		if cur.Savings < base.Savings-thresholds.Savings {
+			regressions = append(regressions, Regression{Module: base.Module, Metric: "savings", Baseline: base.Savings, Current: cur.Savings})
+		}
+		if cur.KeyTermRecall < base.KeyTermRecall-thresholds.KeyTermRecall {
+			regressions = append(regressions, Regression{Module: base.Module, Metric: "key_term_recall", Baseline: base.KeyTermRecall, Current: cur.KeyTermRecall})
+		}
+		if cur.StructuralDiff > base.StructuralDiff+thresholds.StructuralDiff {
+			regressions = append(regressions, Regression{Module: base.Module, Metric: "structural_diff", Baseline: base.StructuralDiff, Current: cur.StructuralDiff})
+		}
+	}
+	regressions = append(regressions, compareCases(baseline, current, thresholds)...)
+	return append(regressions, floors(current, thresholds)...)
+}
+
+// compareCases returns the regressions of the cases of current against
+// those of baseline with the same name. Cases missing from either report
+// are left to the corpus version.
+func compareCases(baseline, current Report, thresholds Thresholds) []Regression {
+	byName := map[string]CaseReport{}
+	for _, cr := range current.Cases {
+		byName[cr.Name] = cr
+	}
+	var regressions []Regression
+	for _, base := range baseline.Cases {
+		cur, ok := byName[base.Name]
+		if !ok {
+			continue
+		}
+		if cur.Module != base.Module {
+			regressions = append(regressions, Regression{Module: base.Module, Case: base.Name, Metric: "module", Moved: cur.Module})
+			continue
+		}
+		if base.InputTokens > 0 && float64(cur.IRTokens-base.IRTokens)/float64(base.InputTokens) > thresholds.Savings {
+			regressions = append(regressions, Regression{Module: base.Module, Case: base.Name, Metric: "ir_tokens", Baseline: float64(base.IRTokens), Current: float64(cur.IRTokens)})
+		}
+		if cur.KeyTermRecall < base.KeyTermRecall-thresholds.KeyTermRecall {
+			regressions = append(regressions, Regression{Module: base.Module, Case: base.Name, Metric: "key_term_recall", Baseline: base.KeyTermRecall, Current: cur.KeyTermRecall})
+		}
+	}
+	return regressions
+}
+
+// floors returns the regressions of current against the absolute floors.
+func floors(current Report, thresholds Thresholds) []Regression {
+	var regressions []Regression
+	for _, mr := range current.Modules {
+		if encodes(mr.Module) && mr.Savings <= 0 {
+			regressions = append(regressions, Regression{Module: mr.Module, Metric: "savings", Current: mr.Savings})
+		}
+	}
+	for _, cr := range current.Cases {
+		if !encodes(cr.Module) {
+			continue
+		}
+		if cr.IRTokens > cr.InputTokens {
+			regressions = append(regressions, Regression{Module: cr.Module, Case: cr.Name, Metric: "ir_tokens", Baseline: float64(cr.InputTokens), Current: float64(cr.IRTokens)})
+		}
+		if !cr.Lossy && cr.SemanticLoss >= thresholds.SemanticLoss {
+			regressions = append(regressions, Regression{Module: cr.Module, Case: cr.Name, Metric: "semantic_loss", Baseline: thresholds.SemanticLoss, Current: cr.SemanticLoss})
+		}
+	}
+	return regressions
+}
+
+// encodes reports whether module names a module that rewrites its input,
+// rather than the passthrough or no module at all.
+func encodes(module string) bool {
+	return module != (iron.PassthroughModule{}).Name() && module != noModule
+}
+
+// round keeps four decimals so that baselines diff cleanly.
+func round(x float64) float64 {
+	return math.Round(x*10000) / 10000
+}
@@ -0,0 +1,175 @@
+package bench
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"agentic/iron"
+)
+
+var updateBaseline = flag.Bool("update", false, "rewrite testdata/baseline.json")
+
+const baselinePath = "testdata/baseline.json"
+
+func TestMeasure_MatchesBaseline(t *testing.T) {
+	cases, err := Corpus()
+	if err != nil {
+		t.Fatalf("Corpus() error = %v", err)
+	}
+	report, err := Measure(context.Background(), iron.NewDefault(), cases)
+	if err != nil {
+		t.Fatalf("Measure() error = %v", err)
+	}
+	if *updateBaseline {
+		data, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			t.Fatalf("MarshalIndent() error = %v", err)
+		}
+		if err := os.MkdirAll(filepath.Dir(baselinePath), 0o755); err != nil {
+			t.Fatalf("MkdirAll() error = %v", err)
+		}
+		if err := os.WriteFile(baselinePath, append(data, '\n'), 0o644); err != nil {
+			t.Fatalf("WriteFile() error = %v", err)
+		}
+		return
+	}
+	data, err := os.ReadFile(baselinePath)
+	if err != nil {
+		t.Fatalf("ReadFile() error = %v (run with -update to create it)", err)
+	}
+	var baseline Report
+	if err := json.Unmarshal(data, &baseline); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if baseline.Version != report.Version {
+		t.Fatalf("baseline is for corpus %s, corpus is %s; run with -update", baseline.Version, report.Version)
+	}
+	for _, regression := range Compare(baseline, report, DefaultThresholds) {
+		t.Errorf("regression: %s", regression)
+	}
+}
+
+func TestCompare_FlagsRegressions(t *testing.T) {
+	baseline := Report{Modules: []ModuleReport{
+		{Module: "IR-DATA", Cases: 2, Savings: 0.5, KeyTermRecall: 1},
+		{Module: "IR-LOG", Cases: 1, Savings: 0.4, KeyTermRecall: 0.9, StructuralDiff: 0.1},
+	}}
+	current := Report{Modules: []ModuleReport{
+		{Module: "IR-DATA", Cases: 2, Savings: 0.49, KeyTermRecall: 0.95, StructuralDiff: 0.05},
+	}}
+	got := Compare(baseline, current, DefaultThresholds)
+	want := []string{"IR-DATA key_term_recall", "IR-DATA structural_diff", "IR-LOG cases"}
+	if len(got) != len(want) {
+		t.Fatalf("Compare() = %v, want %v", got, want)
+	}
+	for i, regression := range got {
+		if regression.Module+" "+regression.Metric != want[i] {
+			t.Fatalf("Compare()[%d] = %s, want %s", i, regression, want[i])
+		}
+	}
+}
+
+func TestCompare_ComparesCases(t *testing.T) {
+	modules := []ModuleReport{{Module: "IR-DATA", Cases: 2, Savings: 0.5, KeyTermRecall: 1}}
+	baseline := Report{Modules: modules, Cases: []CaseReport{
+		{Name: "a.json", Module: "IR-DATA", InputTokens: 100, IRTokens: 40, KeyTermRecall: 1},
+		{Name: "b.json", Module: "IR-DATA", InputTokens: 100, IRTokens: 60, KeyTermRecall: 1},
+		{Name: "c.json", Module: "IR-DATA", InputTokens: 100, IRTokens: 50, KeyTermRecall: 1},
+	}}
+	current := Report{Modules: modules, Cases: []CaseReport{
+		{Name: "a.json", Module: "IR-DATA", InputTokens: 100, IRTokens: 50, KeyTermRecall: 0.9},
+		{Name: "b.json", Module: "IR-PASS", InputTokens: 100, IRTokens: 100, KeyTermRecall: 1},
+		{Name: "c.json", Module: "IR-DATA", InputTokens: 100, IRTokens: 51, KeyTermRecall: 1},
+	}}
+	got := Compare(baseline, current, DefaultThresholds)
+	want := []string{"IR-DATA a.json ir_tokens", "IR-DATA a.json key_term_recall", "IR-DATA b.json module"}
+	if len(got) != len(want) {
+		t.Fatalf("Compare() = %v, want %v", got, want)
+	}
+	for i, regression := range got {
+		if name := regression.Module + " " + regression.Case + " " + regression.Metric; name != want[i] {
+			t.Fatalf("Compare()[%d] = %s, want %s", i, regression, want[i])
+		}
+	}
+	if moved := got[2].String(); moved != "IR-DATA b.json: moved to IR-PASS" {
+		t.Fatalf("Regression.String() = %q", moved)
+	}
+}
+
+func TestCompare_EnforcesFloors(t *testing.T) {
+	// A baseline that accepted these numbers does not excuse them.
+	report := Report{
+		Modules: []ModuleReport{
+			{Module: "IR-PASS", Cases: 1},
+			{Module: "IR-TASK", Cases: 2, InputTokens: 20, IRTokens: 24, Savings: -0.2},
+		},
+		Cases: []CaseReport{
+			{Name: "docs.md", Module: "IR-PASS", InputTokens: 5, IRTokens: 5},
+			{Name: "a.txt", Module: "IR-TASK", InputTokens: 10, IRTokens: 14, SemanticLoss: 0.2, Lossy: true},
+			{Name: "b.txt", Module: "IR-TASK", InputTokens: 10, IRTokens: 10, SemanticLoss: 0.05},
+		},
+	}
+	got := Compare(report, report, DefaultThresholds)
+	want := []string{"IR-TASK savings", "IR-TASK a.txt ir_tokens", "IR-TASK b.txt semantic_loss"}
+	if len(got) != len(want) {
+		t.Fatalf("Compare() = %v, want %v", got, want)
+	}
+	for i, regression := range got {
+		if name := strings.Join(strings.Fields(regression.Module+" "+regression.Case+" "+regression.Metric), " "); name != want[i] {
+			t.Fatalf("Compare()[%d] = %s, want %s", i, regression, want[i])
+		}
+	}
+}
+
+func TestStructuralDiff_JSONLinesAndHTML(t *testing.T) {
+	if got := StructuralDiff(`{"a": 1, "b": [1, 2]}`, `{"b":[3,4],"a":2}`); got != 0 {
+		t.Fatalf("StructuralDiff(same shape) = %v, want 0", got)
+	}
+	if got := StructuralDiff(`{"a": 1, "b": "x"}`, `{"a": 1}`); got != 0.5 {
+		t.Fatalf("StructuralDiff(missing key) = %v, want 0.5", got)
+	}
+	if got := StructuralDiff("f(x) {\n  return x;\n}", "f(y) {\nreturn y;\n}"); got != 0 {
+		t.Fatalf("StructuralDiff(reindented) = %v, want 0", got)
+	}
+	page := "<html><head><title>Pools</title></head><body><h1>Pools</h1><p>Size: small, then grow.</p><ul><li>Set a <a href=\"/ttl\">lifetime</a>.</li></ul></body></html>"
+	if got := StructuralDiff(page, "# Pools\nSize: small, then grow.\n- Set a [lifetime][1].\n[1]: /ttl"); got != 0 {
+		t.Fatalf("StructuralDiff(html as markdown) = %v, want 0", got)
+	}
+	if got := StructuralDiff(page, "# Pools\n- Set a lifetime."); got == 0 {
+		t.Fatalf("StructuralDiff(html missing a paragraph) = 0, want > 0")
+	}
+	if got := SemanticLoss("Deploy billing to production at 10:00.", "Deploy billing."); got <= 0 {
+		t.Fatalf("SemanticLoss(truncated) = %v, want > 0", got)
+	}
+}
+
+func BenchmarkModules(b *testing.B) {
+	cases, err := Corpus()
+	if err != nil {
+		b.Fatalf("Corpus() error = %v", err)
+	}
+	engine := iron.NewDefault()
+	ctx := context.Background()
+	for _, module := range engine.Modules() {
+		for _, c := range cases {
+			if _, ok := module.(iron.PassthroughModule); ok || !module.Detect(c.Input) {
+				continue
+			}
+			b.Run(module.Name()+"/"+c.Name, func(b *testing.B) {
+				var result iron.Result
+				for i := 0; i < b.N; i++ {
+					if result, err = engine.ProcessContext(ctx, c.Input, iron.ForceModule(module.Name()), iron.SkipCache()); err != nil {
+						b.Fatalf("ProcessContext() error = %v", err)
+					}
+				}
+				b.ReportMetric(result.Savings(), "savings")
+				b.ReportMetric(SemanticLoss(c.Input, result.Output), "loss")
+			})
+		}
+	}
+}
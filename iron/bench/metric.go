@@ -0,0 +1,124 @@
+package bench
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+
+	"agentic/iron"
+)
+
+// KeyTermRecall returns the share of the input's key terms, such as names
+// and numbers, that the output still mentions.
+func KeyTermRecall(input, output string) float64 {
+	recall, _ := iron.KeyTermCoverage(input, output)
+	return recall
+}
+
+// StructuralDiff returns the share of the input's structure that the output
+// does not preserve, from 0 to 1. The structure of a JSON input is its
+// paths and leaf types; that of other text is the punctuation skeleton of
+// each non-blank line. Markup is not content, so an HTML input is compared
+// through iron.StripHTML, which does not depend on the module under test,
+// with the output's Markdown syntax removed.
+func StructuralDiff(input, output string) float64 {
+	if (iron.WebModule{}).Detect(input) {
+		input, output = iron.StripHTML(input), markdownText(output)
+	}
+	want := structure(input)
+	if len(want) == 0 {
+		return 0
+	}
+	got := map[string]int{}
+	for _, key := range structure(output) {
+		got[key]++
+	}
+	matched := 0
+	for _, key := range want {
+		if got[key] > 0 {
+			got[key]--
+			matched++
+		}
+	}
+	return 1 - float64(matched)/float64(len(want))
+}
+
+// SemanticLoss combines the metrics into one figure from 0 to 1: the share
+// of key terms lost, scaled up by the share of structure lost.
+func SemanticLoss(input, output string) float64 {
+	return 1 - KeyTermRecall(input, output)*(1-StructuralDiff(input, output))
+}
+
+var (
+	markdownPrefixRe = regexp.MustCompile(`^\s*(?:#{1,6}\s+|[-*+]\s+|\d+[.)]\s+|>\s*)`)
+	markdownLinkRe   = regexp.MustCompile(`!?\[([^\]]*)\](?:\([^)]*\)|\[[^\]]*\])`)
+	markdownRefRe    = regexp.MustCompile(`^\s*\[[^\]]+\]:\s`)
+	markdownRuleRe   = regexp.MustCompile(`^\s*(?:[-*_]\s*){3,}$|^\s*\|?(?:\s*:?-+:?\s*\|)+\s*$`)
+)
+
+// markdownText drops the Markdown syntax of text, keeping one line per
+// block, so that it compares with the stripped text of an HTML document.
+func markdownText(text string) string {
+	var lines []string
+	for _, line := range strings.Split(text, "\n") {
+		if markdownRuleRe.MatchString(line) || markdownRefRe.MatchString(line) {
+			continue
+		}
+		line = markdownPrefixRe.ReplaceAllString(line, "")
+		line = markdownLinkRe.ReplaceAllString(line, "$1")
+		line = strings.NewReplacer("**", "", "__", "", "`", "", "|", " ").Replace(line)
+		if line = strings.Join(strings.Fields(line), " "); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func structure(text string) []string {
+	var value any
+	if err := json.Unmarshal([]byte(text), &value); err == nil {
+		var paths []string
+		jsonPaths(value, "$", &paths)
+		sort.Strings(paths)
+		return paths
+	}
+	var skeletons []string
+	for _, line := range strings.Split(text, "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		skeletons = append(skeletons, strings.Map(func(r rune) rune {
+			if unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.IsSpace(r) {
+				return -1
+			}
+			return r
+		}, line))
+	}
+	return skeletons
+}
+
+// jsonPaths appends the leaf paths of value with their types; array
+// elements share the path "[]".
+func jsonPaths(value any, path string, paths *[]string) {
+	switch v := value.(type) {
+	case map[string]any:
+		if len(v) == 0 {
+			*paths = append(*paths, path+":object")
+		}
+		for key, child := range v {
+			jsonPaths(child, path+"."+key, paths)
+		}
+	case []any:
+		if len(v) == 0 {
+			*paths = append(*paths, path+":array")
+		}
+		for _, child := range v {
+			jsonPaths(child, path+"[]", paths)
+		}
+	default:
+		*paths = append(*paths, fmt.Sprintf("%s:%T", path, v))
+	}
+}
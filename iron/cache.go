@@ -8,10 +8,35 @@ type Cache interface {
 	Set(key string, result Result)
 }
 
-// MemoryCache is an in-memory cache implementation.
+// PurgeableCache is implemented by caches that can drop entries.
+type PurgeableCache interface {
+	Cache
+	Delete(key string)
+	Purge()
+}
+
+// CacheStats reports cache effectiveness and size.
+type CacheStats struct {
+	Hits       uint64 `json:"hits"`
+	Misses     uint64 `json:"misses"`
+	ApproxHits uint64 `json:"approx_hits"`
+	Evictions  uint64 `json:"evictions"`
+	Expired    uint64 `json:"expired"`
+	Entries    int    `json:"entries"`
+	Bytes      int64  `json:"bytes"`
+}
+
+// StatsReporter is implemented by caches that track CacheStats.
+type StatsReporter interface {
+	Stats() CacheStats
+}
+
+// MemoryCache is an unbounded in-memory cache implementation.
 type MemoryCache struct {
-	mu   sync.RWMutex
-	data map[string]Result
+	mu     sync.RWMutex
+	data   map[string]Result
+	hits   uint64
+	misses uint64
 }
 
 // NewMemoryCache creates a new MemoryCache.
@@ -23,9 +48,14 @@ func NewMemoryCache() *MemoryCache {
 
 // Get returns a cached result for the key.
 func (c *MemoryCache) Get(key string) (Result, bool) {
-	c.mu.RLock()
-	defer c.mu.RUnlock()
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	result, ok := c.data[key]
+	if ok {
+		c.hits++
+	} else {
+		c.misses++
+	}
 	return result, ok
 }
 
@@ -35,3 +65,43 @@ func (c *MemoryCache) Set(key string, result Result) {
 	defer c.mu.Unlock()
 	c.data[key] = result
 }
+
+// Delete removes the key.
+func (c *MemoryCache) Delete(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.data, key)
+}
+
+// Purge removes every entry.
+func (c *MemoryCache) Purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.data = make(map[string]Result)
+}
+
+// Stats returns hit and miss counters and the current size.
+func (c *MemoryCache) Stats() CacheStats {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	stats := CacheStats{Hits: c.hits, Misses: c.misses, Entries: len(c.data)}
+	for key, result := range c.data {
+		stats.Bytes += resultSize(key, result)
+	}
+	return stats
+}
+
+// entryOverhead approximates the bookkeeping cost of one cache entry.
+const entryOverhead = 128
+
+// resultSize estimates the memory held by a cached entry.
+func resultSize(key string, result Result) int64 {
+	size := entryOverhead + len(key) + len(result.Module) + len(result.Input) + len(result.IR) + len(result.Output)
+	for _, loss := range result.Losses {
+		size += len(loss.Kind) + len(loss.Detail)
+	}
+	for _, rejection := range result.Rejected {
+		size += len(rejection.Module) + len(rejection.Validator) + len(rejection.Reason)
+	}
+	return int64(size)
+}
@@ -0,0 +1,164 @@
+package iron
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// CacheConfig bounds a BoundedCache. Zero fields are unlimited.
+type CacheConfig struct {
+	MaxEntries int
+	MaxBytes   int64
+	TTL        time.Duration
+	// Now overrides the clock, for tests.
+	Now func() time.Time
+}
+
+// BoundedCache is a least-recently-used cache with optional entry, byte,
+// and time-to-live limits. It is safe for concurrent use.
+type BoundedCache struct {
+	mu      sync.Mutex
+	config  CacheConfig
+	order   *list.List
+	entries map[string]*list.Element
+	stats   CacheStats
+}
+
+type boundedEntry struct {
+	key     string
+	result  Result
+	size    int64
+	expires time.Time
+}
+
+// NewBoundedCache creates a cache with the given limits.
+func NewBoundedCache(config CacheConfig) *BoundedCache {
+	if config.Now == nil {
+		config.Now = time.Now
+	}
+	return &BoundedCache{
+		config:  config,
+		order:   list.New(),
+		entries: make(map[string]*list.Element),
+	}
+}
+
+// NewLRUCache creates a cache holding at most maxEntries results and
+// maxBytes estimated bytes, evicting the least recently used first.
+func NewLRUCache(maxEntries int, maxBytes int64) *BoundedCache {
+	return NewBoundedCache(CacheConfig{MaxEntries: maxEntries, MaxBytes: maxBytes})
+}
+
+// NewTTLCache creates a cache whose entries expire ttl after being set.
+func NewTTLCache(ttl time.Duration) *BoundedCache {
+	return NewBoundedCache(CacheConfig{TTL: ttl})
+}
+
+// Get returns a live entry and marks it as recently used.
+func (c *BoundedCache) Get(key string) (Result, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	element, ok := c.entries[key]
+	if !ok {
+		c.stats.Misses++
+		return Result{}, false
+	}
+	entry := element.Value.(*boundedEntry)
+	if c.expired(entry) {
+		c.remove(element)
+		c.stats.Expired++
+		c.stats.Misses++
+		return Result{}, false
+	}
+	c.order.MoveToFront(element)
+	c.stats.Hits++
+	return entry.result, true
+}
+
+// Set stores a result, evicting old entries to stay within the limits.
+// Results larger than MaxBytes are not stored.
+func (c *BoundedCache) Set(key string, result Result) {
+	size := resultSize(key, result)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if element, ok := c.entries[key]; ok {
+		c.remove(element)
+	}
+	if c.config.MaxBytes > 0 && size > c.config.MaxBytes {
+		return
+	}
+	entry := &boundedEntry{key: key, result: result, size: size}
+	if c.config.TTL > 0 {
+		entry.expires = c.config.Now().Add(c.config.TTL)
+	}
+	c.entries[key] = c.order.PushFront(entry)
+	c.stats.Entries++
+	c.stats.Bytes += size
+
+	for c.overLimit() {
+		c.remove(c.order.Back())
+		c.stats.Evictions++
+	}
+}
+
+// Delete removes the key.
+func (c *BoundedCache) Delete(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if element, ok := c.entries[key]; ok {
+		c.remove(element)
+	}
+}
+
+// Purge removes every entry. Counters are kept.
+func (c *BoundedCache) Purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.order.Init()
+	c.entries = make(map[string]*list.Element)
+	c.stats.Entries = 0
+	c.stats.Bytes = 0
+}
+
+// PurgeExpired removes expired entries and returns how many were removed.
+func (c *BoundedCache) PurgeExpired() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var removed int
+	for element := c.order.Back(); element != nil; {
+		prev := element.Prev()
+		if c.expired(element.Value.(*boundedEntry)) {
+			c.remove(element)
+			c.stats.Expired++
+			removed++
+		}
+		element = prev
+	}
+	return removed
+}
+
+// Stats returns a snapshot of the counters and size.
+func (c *BoundedCache) Stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.stats
+}
+
+func (c *BoundedCache) expired(entry *boundedEntry) bool {
+	return !entry.expires.IsZero() && !c.config.Now().Before(entry.expires)
+}
+
+func (c *BoundedCache) overLimit() bool {
+	if c.config.MaxEntries > 0 && c.stats.Entries > c.config.MaxEntries {
+		return true
+	}
+	return c.config.MaxBytes > 0 && c.stats.Bytes > c.config.MaxBytes
+}
+
+func (c *BoundedCache) remove(element *list.Element) {
+	entry := c.order.Remove(element).(*boundedEntry)
+	delete(c.entries, entry.key)
+	c.stats.Entries--
+	c.stats.Bytes -= entry.size
+}
@@ -0,0 +1,108 @@
+package iron
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBoundedCache_EvictsLeastRecentlyUsed(t *testing.T) {
+	cache := NewLRUCache(2, 0)
+	cache.Set("a", Result{Output: "A"})
+	cache.Set("b", Result{Output: "B"})
+	if _, ok := cache.Get("a"); !ok {
+		t.Fatalf("Get(a) ok = false, want true")
+	}
+	cache.Set("c", Result{Output: "C"})
+
+	if _, ok := cache.Get("b"); ok {
+		t.Fatalf("Get(b) ok = true, want evicted")
+	}
+	for _, key := range []string{"a", "c"} {
+		if _, ok := cache.Get(key); !ok {
+			t.Fatalf("Get(%s) ok = false, want true", key)
+		}
+	}
+	stats := cache.Stats()
+	if stats.Entries != 2 || stats.Evictions != 1 || stats.Hits != 3 || stats.Misses != 1 {
+		t.Fatalf("Stats() = %+v, want 2 entries, 1 eviction, 3 hits, 1 miss", stats)
+	}
+}
+
+func TestBoundedCache_ByteLimit(t *testing.T) {
+	big := Result{Output: strings.Repeat("x", 400)}
+	limit := resultSize("k1", big) * 2
+	cache := NewLRUCache(0, limit)
+	cache.Set("k1", big)
+	cache.Set("k2", big)
+	if stats := cache.Stats(); stats.Entries != 2 || stats.Bytes != limit {
+		t.Fatalf("Stats() = %+v, want 2 entries using %d bytes", stats, limit)
+	}
+	cache.Set("k3", big)
+	if stats := cache.Stats(); stats.Entries != 2 || stats.Evictions != 1 || stats.Bytes > limit {
+		t.Fatalf("Stats() = %+v, want eviction to stay within %d bytes", stats, limit)
+	}
+
+	cache.Set("huge", Result{Output: strings.Repeat("x", int(limit))})
+	if _, ok := cache.Get("huge"); ok {
+		t.Fatalf("Get(huge) ok = true, want oversized result skipped")
+	}
+}
+
+func TestBoundedCache_TTL(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	cache := NewBoundedCache(CacheConfig{TTL: time.Minute, Now: func() time.Time { return now }})
+	cache.Set("a", Result{Output: "A"})
+	cache.Set("b", Result{Output: "B"})
+
+	now = now.Add(30 * time.Second)
+	if _, ok := cache.Get("a"); !ok {
+		t.Fatalf("Get(a) ok = false before TTL")
+	}
+	now = now.Add(31 * time.Second)
+	if _, ok := cache.Get("a"); ok {
+		t.Fatalf("Get(a) ok = true after TTL")
+	}
+	if removed := cache.PurgeExpired(); removed != 1 {
+		t.Fatalf("PurgeExpired() = %d, want 1", removed)
+	}
+	if stats := cache.Stats(); stats.Expired != 2 || stats.Entries != 0 || stats.Bytes != 0 {
+		t.Fatalf("Stats() = %+v, want 2 expired and empty cache", stats)
+	}
+}
+
+func TestBoundedCache_DeleteAndPurge(t *testing.T) {
+	cache := NewLRUCache(10, 0)
+	cache.Set("a", Result{Output: "A"})
+	cache.Set("b", Result{Output: "B"})
+	cache.Delete("a")
+	if _, ok := cache.Get("a"); ok {
+		t.Fatalf("Get(a) ok = true after Delete")
+	}
+	cache.Purge()
+	if stats := cache.Stats(); stats.Entries != 0 || stats.Bytes != 0 {
+		t.Fatalf("Stats() after Purge = %+v, want empty", stats)
+	}
+}
+
+func TestEngine_CacheKey_IncludesModuleSet(t *testing.T) {
+	cache := NewMemoryCache()
+	engine := New(WithCache(cache), WithModule(testModule{name: "first", score: 0.5, detect: true}))
+	if _, err := engine.ProcessDetailed("hello"); err != nil {
+		t.Fatalf("ProcessDetailed() error = %v", err)
+	}
+	if err := engine.RegisterModule(testModule{name: "second", score: 0.9, detect: true, encoded: "new"}); err != nil {
+		t.Fatalf("RegisterModule() error = %v", err)
+	}
+
+	result, err := engine.ProcessDetailed("hello")
+	if err != nil {
+		t.Fatalf("ProcessDetailed() error = %v", err)
+	}
+	if result.Cached || result.Module != "second" {
+		t.Fatalf("ProcessDetailed() module = %q cached = %v, want fresh result from second", result.Module, result.Cached)
+	}
+	if stats := cache.Stats(); stats.Entries != 2 || stats.Misses != 2 {
+		t.Fatalf("Stats() = %+v, want 2 entries and 2 misses", stats)
+	}
+}
@@ -0,0 +1,293 @@
+package iron
+
+import (
+	"hash/fnv"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+const (
+	// minHashSize is the number of MinHash permutations per signature.
+	minHashSize = 128
+	// minSimilarTokens is the shortest input eligible for approximate hits.
+	minSimilarTokens = 3
+	// defaultSimilarEntries bounds the fingerprint index.
+	defaultSimilarEntries = 10000
+)
+
+// negationWords flip the meaning of otherwise similar inputs.
+var negationWords = map[string]bool{
+	"not": true, "no": true, "never": true, "none": true, "nothing": true, "without": true,
+	"dont": true, "don": true, "doesn": true, "didn": true, "isn": true, "aren": true, "won": true, "cannot": true,
+	"não": true, "nao": true, "nunca": true, "nem": true, "sem": true, "nada": true, "nenhum": true, "nenhuma": true, "jamais": true,
+}
+
+var minHashSeeds = func() [minHashSize]uint64 {
+	var seeds [minHashSize]uint64
+	state := uint64(0x9e3779b97f4a7c15)
+	for i := range seeds {
+		state += 0x9e3779b97f4a7c15
+		seeds[i] = mix64(state)
+	}
+	return seeds
+}()
+
+// SimilarityCache wraps a Cache and also answers near-duplicate inputs. It
+// fingerprints each stored input with MinHash over character trigrams of its
+// words, so rephrasings like "lembre-me de pagar a conta" and "me lembra de
+// pagar a conta" share an entry. Inputs whose numbers or negations differ,
+// or whose shared words come in a different order, as in "from alice to
+// bob" and "from bob to alice", never match. Lookups scan the index
+// linearly.
+type SimilarityCache struct {
+	// Threshold is the minimum estimated Jaccard similarity for a hit.
+	Threshold float64
+	// MaxEntries bounds the fingerprint index; zero means 10000.
+	MaxEntries int
+
+	inner      Cache
+	mu         sync.Mutex
+	index      []similarEntry
+	approxHits uint64
+	// probeHits and probeMisses count the inner lookups of the approximate
+	// path, which Stats takes back out of the inner cache's counters.
+	probeHits   uint64
+	probeMisses uint64
+}
+
+type similarEntry struct {
+	key       string
+	namespace string
+	signature [minHashSize]uint64
+	guard     string
+	tokens    []string
+}
+
+// NewSimilarityCache wraps inner, returning approximate hits at or above
+// threshold.
+func NewSimilarityCache(inner Cache, threshold float64) *SimilarityCache {
+	return &SimilarityCache{Threshold: threshold, inner: inner}
+}
+
+// Get returns an exact hit from the inner cache or, failing that, the most
+// similar stored result marked Approximate.
+func (c *SimilarityCache) Get(key string) (Result, bool) {
+	if result, ok := c.inner.Get(key); ok {
+		return result, true
+	}
+	namespace, input := splitCacheKey(key)
+	tokens := similarityTokens(input)
+	if len(tokens) < minSimilarTokens {
+		return Result{}, false
+	}
+	signature, guard := minHashSignature(tokens), similarityGuard(tokens)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for {
+		best, bestScore := -1, 0.0
+		for i, entry := range c.index {
+			if entry.namespace != namespace || entry.guard != guard || !sameOrder(tokens, entry.tokens) {
+				continue
+			}
+			if score := signatureSimilarity(signature, entry.signature); score >= c.Threshold && score > bestScore {
+				best, bestScore = i, score
+			}
+		}
+		if best < 0 {
+			return Result{}, false
+		}
+		result, ok := c.inner.Get(c.index[best].key)
+		if !ok {
+			// The inner cache evicted the entry; forget its fingerprint.
+			c.probeMisses++
+			c.index = append(c.index[:best], c.index[best+1:]...)
+			continue
+		}
+		c.probeHits++
+		c.approxHits++
+		result.Approximate = true
+		result.Similarity = bestScore
+		return result, true
+	}
+}
+
+// Set stores the result and fingerprints its input.
+func (c *SimilarityCache) Set(key string, result Result) {
+	c.inner.Set(key, result)
+	namespace, input := splitCacheKey(key)
+	tokens := similarityTokens(input)
+	if len(tokens) < minSimilarTokens {
+		return
+	}
+	entry := similarEntry{key: key, namespace: namespace, signature: minHashSignature(tokens), guard: similarityGuard(tokens), tokens: tokens}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.removeLocked(key)
+	c.index = append(c.index, entry)
+	limit := c.MaxEntries
+	if limit <= 0 {
+		limit = defaultSimilarEntries
+	}
+	if over := len(c.index) - limit; over > 0 {
+		c.index = append(c.index[:0], c.index[over:]...)
+	}
+}
+
+// Delete removes the key from the index and, if it is a PurgeableCache,
+// from the inner cache.
+func (c *SimilarityCache) Delete(key string) {
+	if inner, ok := c.inner.(PurgeableCache); ok {
+		inner.Delete(key)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.removeLocked(key)
+}
+
+// Purge clears the index and, if it is a PurgeableCache, the inner cache.
+func (c *SimilarityCache) Purge() {
+	if inner, ok := c.inner.(PurgeableCache); ok {
+		inner.Purge()
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.index = nil
+}
+
+// Stats returns the inner cache's stats with ApproxHits filled in. An
+// approximate hit counts only as an ApproxHit, not as the exact miss and
+// the inner hit it took to find it.
+func (c *SimilarityCache) Stats() CacheStats {
+	var stats CacheStats
+	if reporter, ok := c.inner.(StatsReporter); ok {
+		stats = reporter.Stats()
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	stats.ApproxHits = c.approxHits
+	stats.Hits = subtractCount(stats.Hits, c.probeHits)
+	stats.Misses = subtractCount(stats.Misses, c.probeMisses+c.approxHits)
+	return stats
+}
+
+// subtractCount is a - b, floored at zero for inner caches whose counters
+// were reset.
+func subtractCount(a, b uint64) uint64 {
+	if b > a {
+		return 0
+	}
+	return a - b
+}
+
+func (c *SimilarityCache) removeLocked(key string) {
+	for i, entry := range c.index {
+		if entry.key == key {
+			c.index = append(c.index[:i], c.index[i+1:]...)
+			return
+		}
+	}
+}
+
+// splitCacheKey separates the engine's module-set namespace from the input.
+func splitCacheKey(key string) (string, string) {
+	if namespace, input, ok := strings.Cut(key, "\x00"); ok {
+		return namespace, input
+	}
+	return "", key
+}
+
+func similarityTokens(input string) []string {
+	return strings.FieldsFunc(strings.ToLower(input), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// similarityGuard summarizes the numbers and negations that must match
+// exactly for two inputs to be considered the same.
+func similarityGuard(tokens []string) string {
+	var parts []string
+	for _, token := range tokens {
+		if negationWords[token] {
+			parts = append(parts, "!")
+		} else if strings.IndexFunc(token, unicode.IsDigit) >= 0 {
+			parts = append(parts, token)
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// sameOrder reports whether the words a and b share come in the same order
+// in both. The word sets of a sentence and of its roles swapped are equal,
+// so MinHash alone cannot tell them apart.
+func sameOrder(a, b []string) bool {
+	inA, inB := map[string]bool{}, map[string]bool{}
+	for _, token := range a {
+		inA[token] = true
+	}
+	for _, token := range b {
+		inB[token] = true
+	}
+	i, j := 0, 0
+	for {
+		for i < len(a) && !inB[a[i]] {
+			i++
+		}
+		for j < len(b) && !inA[b[j]] {
+			j++
+		}
+		if i == len(a) || j == len(b) {
+			return i == len(a) && j == len(b)
+		}
+		if a[i] != b[j] {
+			return false
+		}
+		i++
+		j++
+	}
+}
+
+// minHashSignature hashes the word-boundary trigrams of every token.
+func minHashSignature(tokens []string) [minHashSize]uint64 {
+	var signature [minHashSize]uint64
+	for i := range signature {
+		signature[i] = ^uint64(0)
+	}
+	for _, token := range tokens {
+		runes := []rune("^" + token + "$")
+		for i := 0; i+3 <= len(runes); i++ {
+			hasher := fnv.New64a()
+			hasher.Write([]byte(string(runes[i : i+3])))
+			base := hasher.Sum64()
+			for j, seed := range minHashSeeds {
+				if h := mix64(base ^ seed); h < signature[j] {
+					signature[j] = h
+				}
+			}
+		}
+	}
+	return signature
+}
+
+// signatureSimilarity estimates the Jaccard similarity of two shingle sets.
+func signatureSimilarity(a, b [minHashSize]uint64) float64 {
+	var same int
+	for i := range a {
+		if a[i] == b[i] {
+			same++
+		}
+	}
+	return float64(same) / minHashSize
+}
+
+// mix64 is the SplitMix64 finalizer.
+func mix64(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
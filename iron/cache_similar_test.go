@@ -0,0 +1,106 @@
+package iron
+
+import "testing"
+
+func TestSimilarityCache_Get_Rephrased(t *testing.T) {
+	cache := NewSimilarityCache(NewMemoryCache(), 0.75)
+	cache.Set("mods\x00lembre-me de comprar leite amanhã", Result{Output: "ok"})
+
+	result, ok := cache.Get("mods\x00me lembra de comprar leite amanhã")
+	if !ok {
+		t.Fatalf("Get() ok = false, want approximate hit")
+	}
+	if !result.Approximate || result.Similarity < 0.75 || result.Output != "ok" {
+		t.Fatalf("Get() = %+v, want approximate hit with similarity >= 0.75", result)
+	}
+
+	exact, ok := cache.Get("mods\x00lembre-me de comprar leite amanhã")
+	if !ok || exact.Approximate {
+		t.Fatalf("Get() exact = %+v, %v, want exact hit", exact, ok)
+	}
+	if stats := cache.Stats(); stats.ApproxHits != 1 || stats.Hits != 1 || stats.Misses != 0 {
+		t.Fatalf("Stats() = %+v, want 1 approximate hit, 1 hit, and no misses", stats)
+	}
+}
+
+func TestSimilarityCache_Get_Guards(t *testing.T) {
+	cache := NewSimilarityCache(NewMemoryCache(), 0.5)
+	cache.Set("mods\x00remind me to call the dentist at 10 tomorrow", Result{Output: "10"})
+	cache.Set("mods\x00please delete the old backup files", Result{Output: "delete"})
+	cache.Set("mods\x00hi", Result{Output: "short"})
+	cache.Set("mods\x00transfer 100 from alice to bob", Result{Output: "alice pays"})
+
+	for _, key := range []string{
+		"mods\x00remind me to call the dentist at 11 tomorrow",
+		"mods\x00remind me to call the dentist tomorrow",
+		"mods\x00please do not delete the old backup files",
+		"mods\x00por favor não apague os arquivos antigos de backup",
+		"other\x00remind me to call the dentist at 10 tomorrow",
+		"mods\x00hi!",
+		"mods\x00summarize the quarterly sales report",
+		"mods\x00transfer 100 from bob to alice",
+	} {
+		if result, ok := cache.Get(key); ok {
+			t.Fatalf("Get(%q) = %+v, want miss", key, result)
+		}
+	}
+}
+
+func TestSimilarityCache_DeleteAndEviction(t *testing.T) {
+	inner := NewLRUCache(1, 0)
+	cache := NewSimilarityCache(inner, 0.7)
+	cache.Set("analyze this repository and summarize it", Result{Output: "first"})
+	cache.Set("check disk usage on the build server", Result{Output: "second"})
+
+	if _, ok := cache.Get("analyze the repository and summarize it"); ok {
+		t.Fatalf("Get() ok = true for an entry evicted from the inner cache")
+	}
+	cache.Delete("check disk usage on the build server")
+	if _, ok := cache.Get("check the disk usage on the build server"); ok {
+		t.Fatalf("Get() ok = true after Delete")
+	}
+}
+
+// getSetCache implements only Cache, as caches written before
+// PurgeableCache do.
+type getSetCache struct {
+	data map[string]Result
+}
+
+func (c getSetCache) Get(key string) (Result, bool) {
+	result, ok := c.data[key]
+	return result, ok
+}
+
+func (c getSetCache) Set(key string, result Result) {
+	c.data[key] = result
+}
+
+func TestSimilarityCache_WrapsGetSetCache(t *testing.T) {
+	cache := NewSimilarityCache(getSetCache{data: map[string]Result{}}, 0.75)
+	cache.Set("lembre-me de comprar leite amanhã", Result{Output: "ok"})
+	if _, ok := cache.Get("me lembra de comprar leite amanhã"); !ok {
+		t.Fatal("Get() ok = false, want approximate hit")
+	}
+	cache.Purge()
+	if _, ok := cache.Get("me lembra de comprar leite amanhã"); ok {
+		t.Fatal("Get() ok = true after Purge")
+	}
+}
+
+func TestEngine_ProcessDetailed_ApproximateCacheHit(t *testing.T) {
+	engine := New(
+		WithCache(NewSimilarityCache(NewMemoryCache(), 0.75)),
+		WithModule(testModule{name: "mod", score: 1, detect: true}),
+	)
+	if _, err := engine.ProcessDetailed("lembre-me de pagar a conta de luz"); err != nil {
+		t.Fatalf("ProcessDetailed() error = %v", err)
+	}
+	result, err := engine.ProcessDetailed("me lembra de pagar a conta de luz")
+	if err != nil {
+		t.Fatalf("ProcessDetailed() error = %v", err)
+	}
+	if !result.Cached || !result.Approximate {
+		t.Fatalf("ProcessDetailed() cached = %v approximate = %v, want both", result.Cached, result.Approximate)
+	}
+}
@@ -0,0 +1,102 @@
+package iron
+
+import (
+	"context"
+	"fmt"
+)
+
+// CallOption configures a single ProcessContext call.
+type CallOption func(*callOptions)
+
+type callOptions struct {
+	module     string
+	skipCache  bool
+	bestEffort bool
+	hints      Hints
+}
+
+// Hints are the per-call settings a ContextModule can read from its context.
+type Hints struct {
+	// Level is the requested lossiness; it applies only when HasLevel is set.
+	Level    Level
+	HasLevel bool
+	// TokenBudget is the maximum IR size in tokens; zero means unlimited.
+	TokenBudget int
+	// Domain names the kind of input, such as "log" or "code".
+	Domain string
+}
+
+type hintsKey struct{}
+
+// ContextWithHints attaches hints to ctx.
+func ContextWithHints(ctx context.Context, hints Hints) context.Context {
+	return context.WithValue(ctx, hintsKey{}, hints)
+}
+
+// HintsFromContext returns the hints attached to ctx, or zero Hints.
+func HintsFromContext(ctx context.Context) Hints {
+	hints, _ := ctx.Value(hintsKey{}).(Hints)
+	return hints
+}
+
+// LevelOr returns the hinted level, or fallback when none was set.
+func (h Hints) LevelOr(fallback Level) Level {
+	if h.HasLevel {
+		return h.Level
+	}
+	return fallback
+}
+
+// ForceModule uses the named module even if it does not detect the input.
+func ForceModule(name string) CallOption {
+	return func(o *callOptions) {
+		o.module = name
+	}
+}
+
+// SkipCache neither reads nor writes the engine cache.
+func SkipCache() CallOption {
+	return func(o *callOptions) {
+		o.skipCache = true
+	}
+}
+
+// AtLevel asks modules that support levels to encode at level.
+func AtLevel(level Level) CallOption {
+	return func(o *callOptions) {
+		o.hints.Level = level
+		o.hints.HasLevel = true
+	}
+}
+
+// TokenBudget escalates through lossier levels until the IR fits in tokens,
+// failing with ErrTokenBudget when no level does.
+func TokenBudget(tokens int) CallOption {
+	return func(o *callOptions) {
+		o.hints.TokenBudget = tokens
+	}
+}
+
+// BestEffort returns the smallest IR, marked OverBudget, instead of
+// ErrTokenBudget when the token budget cannot be met.
+func BestEffort() CallOption {
+	return func(o *callOptions) {
+		o.bestEffort = true
+	}
+}
+
+// DomainHint ranks the module named after domain (for example "log" for
+// IR-LOG) first when it detects the input.
+func DomainHint(domain string) CallOption {
+	return func(o *callOptions) {
+		o.hints.Domain = domain
+	}
+}
+
+// cacheScope distinguishes calls whose options change the result.
+func (o callOptions) cacheScope() string {
+	if o.module == "" && !o.bestEffort && o.hints == (Hints{}) {
+		return ""
+	}
+	return fmt.Sprintf("|%s|%t|%+v", o.module, o.bestEffort, o.hints)
+}
@@ -0,0 +1,185 @@
+package iron
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// slowModule blocks in EncodeContext until its context is done.
+type slowModule struct{ testModule }
+
+func (slowModule) EncodeContext(ctx context.Context, input string) (string, []Loss, error) {
+	<-ctx.Done()
+	return "", nil, ctx.Err()
+}
+
+func (m slowModule) DecodeContext(ctx context.Context, ir string) (string, error) {
+	return m.Decode(ir)
+}
+
+func TestEngine_ProcessContext_Cancels(t *testing.T) {
+	engine := New(WithModule(slowModule{testModule{name: "slow", score: 1, detect: true}}))
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := engine.ProcessContext(ctx, "input"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("ProcessContext() error = %v, want deadline exceeded", err)
+	}
+}
+
+func TestEngine_ProcessContext_ForceModule(t *testing.T) {
+	engine := New(
+		WithModule(testModule{name: "best", score: 0.9, detect: true}),
+		WithModule(testModule{name: "shy", score: 0.1, detect: false, encoded: "forced"}),
+	)
+	result, err := engine.ProcessContext(context.Background(), "input", ForceModule("shy"))
+	if err != nil {
+		t.Fatalf("ProcessContext() error = %v", err)
+	}
+	if result.Module != "shy" || result.Output != "decoded:forced" {
+		t.Fatalf("ProcessContext() = %q/%q, want shy/decoded:forced", result.Module, result.Output)
+	}
+	if _, err := engine.ProcessContext(context.Background(), "input", ForceModule("missing")); !errors.Is(err, ErrUnknownModule) {
+		t.Fatalf("ProcessContext() error = %v, want ErrUnknownModule", err)
+	}
+}
+
+func TestEngine_ProcessContext_SkipCache(t *testing.T) {
+	cache := NewMemoryCache()
+	engine := New(WithCache(cache), WithModule(testModule{name: "mod", score: 1, detect: true}))
+	for i := 0; i < 2; i++ {
+		result, err := engine.ProcessContext(context.Background(), "hello", SkipCache())
+		if err != nil {
+			t.Fatalf("ProcessContext() error = %v", err)
+		}
+		if result.Cached {
+			t.Fatalf("ProcessContext() cached = true, want false")
+		}
+	}
+	if stats := cache.Stats(); stats.Entries != 0 {
+		t.Fatalf("Stats().Entries = %d, want 0", stats.Entries)
+	}
+}
+
+func TestEngine_ProcessContext_LevelHint(t *testing.T) {
+	cache := NewMemoryCache()
+	engine := New(WithCache(cache), WithModule(LogModule{}))
+	input := readLogFixture(t)
+
+	lossless, err := engine.ProcessContext(context.Background(), input)
+	if err != nil {
+		t.Fatalf("ProcessContext() error = %v", err)
+	}
+	aggressive, err := engine.ProcessContext(context.Background(), input, AtLevel(LevelAggressive))
+	if err != nil {
+		t.Fatalf("ProcessContext() error = %v", err)
+	}
+	if aggressive.Cached || !strings.HasPrefix(aggressive.IR, "@LOG[aggressive]") || len(aggressive.Losses) == 0 {
+		t.Fatalf("ProcessContext(AtLevel) IR header = %q cached = %v, want fresh aggressive IR", strings.SplitN(aggressive.IR, "\n", 2)[0], aggressive.Cached)
+	}
+	if len(aggressive.IR) >= len(lossless.IR) {
+		t.Fatalf("aggressive IR length = %d, want < lossless %d", len(aggressive.IR), len(lossless.IR))
+	}
+}
+
+func TestEngine_ProcessContext_TokenBudget(t *testing.T) {
+	engine := New(WithModule(testModule{name: "mod", score: 1, detect: true}), WithTokenizer(CharWordTokenizer{}))
+	if _, err := engine.ProcessContext(context.Background(), "one two three four", TokenBudget(2)); !errors.Is(err, ErrTokenBudget) {
+		t.Fatalf("ProcessContext() error = %v, want ErrTokenBudget", err)
+	}
+	if _, err := engine.ProcessContext(context.Background(), "one two three four", TokenBudget(100)); err != nil {
+		t.Fatalf("ProcessContext() error = %v", err)
+	}
+}
+
+// levelModule keeps fewer words at lossier levels.
+type levelModule struct{ testModule }
+
+func (m levelModule) EncodeContext(ctx context.Context, input string) (string, []Loss, error) {
+	words := strings.Fields(input)
+	keep := len(words) >> HintsFromContext(ctx).LevelOr(LevelLossless)
+	if keep < len(words) {
+		return strings.Join(words[:keep], " "), []Loss{{Kind: "words", Count: len(words) - keep}}, nil
+	}
+	return input, nil, nil
+}
+
+func (m levelModule) DecodeContext(ctx context.Context, ir string) (string, error) {
+	return ir, nil
+}
+
+func TestEngine_ProcessContext_TokenBudgetEscalates(t *testing.T) {
+	engine := New(
+		WithModule(levelModule{testModule{name: "lvl", score: 1, detect: true}}),
+		WithTokenizer(CharWordTokenizer{}),
+		WithValidator(ExactValidator{}),
+	)
+	input := "one two three four five six seven eight"
+	result, err := engine.ProcessContext(context.Background(), input, TokenBudget(5))
+	if err != nil {
+		t.Fatalf("ProcessContext() error = %v", err)
+	}
+	if result.IR != "one two three four" || result.OverBudget {
+		t.Fatalf("ProcessContext() = %q, over budget %v", result.IR, result.OverBudget)
+	}
+	want := []Escalation{{Module: "lvl", Level: LevelBalanced, IRTokens: 5}}
+	if len(result.Escalations) != 1 || result.Escalations[0] != want[0] {
+		t.Fatalf("ProcessContext() escalations = %+v, want %+v", result.Escalations, want)
+	}
+	if len(result.Losses) != 1 || result.Losses[0].Count != 4 {
+		t.Fatalf("ProcessContext() losses = %+v", result.Losses)
+	}
+}
+
+func TestEngine_ProcessContext_TokenBudgetBestEffort(t *testing.T) {
+	engine := New(WithModule(levelModule{testModule{name: "lvl", score: 1, detect: true}}), WithTokenizer(CharWordTokenizer{}))
+	input := "one two three four five six seven eight"
+	if _, err := engine.ProcessContext(context.Background(), input, TokenBudget(1)); !errors.Is(err, ErrTokenBudget) {
+		t.Fatalf("ProcessContext() error = %v, want ErrTokenBudget", err)
+	}
+	result, err := engine.ProcessContext(context.Background(), input, TokenBudget(1), BestEffort())
+	if err != nil {
+		t.Fatalf("ProcessContext() error = %v", err)
+	}
+	if !result.OverBudget || result.IR != "one two" || len(result.Escalations) != 2 {
+		t.Fatalf("ProcessContext() = %q, over budget %v, escalations %+v", result.IR, result.OverBudget, result.Escalations)
+	}
+}
+
+func TestEngine_ProcessContext_TokenBudgetLogs(t *testing.T) {
+	var lines []string
+	for i := 0; i < 40; i++ {
+		lines = append(lines, fmt.Sprintf("2024-05-01T10:00:%02dZ INFO request id=%d path=/api/items status=200", i, 1000+i))
+	}
+	input := strings.Join(lines, "\n")
+	engine := NewDefault()
+	lossless, err := engine.ProcessContext(context.Background(), input)
+	if err != nil {
+		t.Fatalf("ProcessContext() error = %v", err)
+	}
+	budget := lossless.IRTokens - 1
+	result, err := engine.ProcessContext(context.Background(), input, TokenBudget(budget))
+	if err != nil {
+		t.Fatalf("ProcessContext() error = %v", err)
+	}
+	if result.Module != "IR-LOG" || result.IRTokens > budget || len(result.Escalations) == 0 || len(result.Losses) == 0 {
+		t.Fatalf("ProcessContext() = %s %d tokens, escalations %+v, losses %+v", result.Module, result.IRTokens, result.Escalations, result.Losses)
+	}
+}
+
+func TestEngine_ProcessContext_DomainHint(t *testing.T) {
+	engine := New(
+		WithModule(testModule{name: "IR-TASK", score: 0.9, detect: true}),
+		WithModule(testModule{name: "IR-LOG", score: 0.5, detect: true}),
+	)
+	result, err := engine.ProcessContext(context.Background(), "input", DomainHint("log"))
+	if err != nil {
+		t.Fatalf("ProcessContext() error = %v", err)
+	}
+	if result.Module != "IR-LOG" {
+		t.Fatalf("ProcessContext() module = %q, want IR-LOG", result.Module)
+	}
+}
@@ -0,0 +1,217 @@
+package iron
+
+import (
+	"context"
+	"embed"
+	"io/fs"
+	"math"
+	"path"
+	"sort"
+	"sync"
+)
+
+const (
+	// classifyMaxBytes bounds the prefix of an input that is classified.
+	classifyMaxBytes = 1024
+	// classifyEvidence caps the n-grams that count as evidence, so that
+	// long inputs do not saturate the confidence.
+	classifyEvidence = 64
+	// rankEpsilon is the rank difference below which modules tie.
+	rankEpsilon = 1e-9
+)
+
+// Classifier is implemented by modules that can say how well they fit an
+// input, from 0 to 1. The engine ranks detecting modules by this confidence
+// times Score; modules without it have confidence 1.
+type Classifier interface {
+	Classify(input string) float64
+}
+
+// Candidate is a module that detects an input, with its confidence for that
+// input and its static quality score.
+type Candidate struct {
+	Module     string  `json:"module"`
+	Confidence float64 `json:"confidence"`
+	Quality    float64 `json:"quality"`
+}
+
+// Rank returns the value candidates are ordered by.
+func (c Candidate) Rank() float64 {
+	return c.Confidence * c.Quality
+}
+
+// NaiveBayes is a character n-gram naive Bayes classifier. Labels are
+// equally likely a priori. Training is not safe for concurrent use;
+// prediction is.
+type NaiveBayes struct {
+	n      int
+	counts map[string]map[string]int
+	totals map[string]int
+	vocab  map[string]bool
+
+	// The last prediction is kept because every module of an engine
+	// classifies the same input in turn.
+	mu       sync.Mutex
+	lastText string
+	last     map[string]float64
+}
+
+// NewNaiveBayes returns an untrained classifier over n-grams of up to n
+// characters.
+func NewNaiveBayes(n int) *NaiveBayes {
+	if n < 1 {
+		n = 1
+	}
+	return &NaiveBayes{n: n, counts: map[string]map[string]int{}, totals: map[string]int{}, vocab: map[string]bool{}}
+}
+
+// Train adds the n-grams of text to label.
+func (nb *NaiveBayes) Train(label, text string) {
+	counts := nb.counts[label]
+	if counts == nil {
+		counts = map[string]int{}
+		nb.counts[label] = counts
+	}
+	nb.mu.Lock()
+	nb.last = nil
+	nb.mu.Unlock()
+	for _, gram := range nb.grams(text) {
+		counts[gram]++
+		nb.totals[label]++
+		nb.vocab[gram] = true
+	}
+}
+
+// Labels returns the trained labels in sorted order.
+func (nb *NaiveBayes) Labels() []string {
+	labels := make([]string, 0, len(nb.counts))
+	for label := range nb.counts {
+		labels = append(labels, label)
+	}
+	sort.Strings(labels)
+	return labels
+}
+
+// Predict returns the posterior probability of each label for text.
+func (nb *NaiveBayes) Predict(text string) map[string]float64 {
+	posteriors := map[string]float64{}
+	for label, p := range nb.predict(text) {
+		posteriors[label] = p
+	}
+	return posteriors
+}
+
+// predict returns the posteriors for text, which callers must not modify.
+// The lock guards only the memo, so that concurrent predictions on other
+// inputs do not wait for each other.
+func (nb *NaiveBayes) predict(text string) map[string]float64 {
+	nb.mu.Lock()
+	last, lastText := nb.last, nb.lastText
+	nb.mu.Unlock()
+	if last != nil && lastText == text {
+		return last
+	}
+	grams := nb.grams(text)
+	weight := 1.0
+	if len(grams) > classifyEvidence {
+		weight = classifyEvidence / float64(len(grams))
+	}
+	scores := map[string]float64{}
+	best := math.Inf(-1)
+	vocab := float64(len(nb.vocab) + 1)
+	for label, counts := range nb.counts {
+		total := float64(nb.totals[label]) + vocab
+		score := 0.0
+		for _, gram := range grams {
+			score += math.Log(float64(counts[gram]+1) / total)
+		}
+		scores[label] = score * weight
+		best = math.Max(best, scores[label])
+	}
+	sum := 0.0
+	for label, score := range scores {
+		scores[label] = math.Exp(score - best)
+		sum += scores[label]
+	}
+	for label := range scores {
+		scores[label] /= sum
+	}
+	nb.mu.Lock()
+	nb.lastText, nb.last = text, scores
+	nb.mu.Unlock()
+	return scores
+}
+
+// Confidence returns the probability of label for text relative to the
+// most likely label, so that the best label scores 1.
+func (nb *NaiveBayes) Confidence(label, text string) float64 {
+	posteriors := nb.predict(text)
+	best := 0.0
+	for _, p := range posteriors {
+		best = math.Max(best, p)
+	}
+	if best == 0 {
+		return 0
+	}
+	return posteriors[label] / best
+}
+
+// grams returns the character n-grams of text from 1 to n characters long,
+// with digits folded to '0' so that values do not split the vocabulary.
+func (nb *NaiveBayes) grams(text string) []string {
+	if len(text) > classifyMaxBytes {
+		text = text[:classifyMaxBytes]
+	}
+	runes := []rune(text)
+	for i, r := range runes {
+		if r >= '0' && r <= '9' {
+			runes[i] = '0'
+		}
+	}
+	var grams []string
+	for i := range runes {
+		for n := 1; n <= nb.n && i+n <= len(runes); n++ {
+			grams = append(grams, string(runes[i:i+n]))
+		}
+	}
+	return grams
+}
+
+//go:embed corpus
+var corpusFS embed.FS
+
+var (
+	defaultClassifier     *NaiveBayes
+	defaultClassifierOnce sync.Once
+)
+
+// DefaultClassifier returns a trigram classifier trained on the bundled
+// corpus, with the labels task, data, log, code, web, and prose.
+func DefaultClassifier() *NaiveBayes {
+	defaultClassifierOnce.Do(func() {
+		nb := NewNaiveBayes(3)
+		_ = fs.WalkDir(corpusFS, "corpus", func(name string, entry fs.DirEntry, err error) error {
+			if err != nil || entry.IsDir() {
+				return err
+			}
+			data, err := corpusFS.ReadFile(name)
+			if err != nil {
+				return err
+			}
+			nb.Train(path.Base(path.Dir(name)), string(data))
+			return nil
+		})
+		defaultClassifier = nb
+	})
+	return defaultClassifier
+}
+
+// newCandidate classifies input for module, clamping the confidence to
+// [0, 1].
+func newCandidate(ctx context.Context, module IRModule, input string) Candidate {
+	confidence := 1.0
+	if classifier, ok := module.(Classifier); ok {
+		confidence = math.Max(0, math.Min(1, classifier.Classify(input)))
+	}
+	return Candidate{Module: module.Name(), Confidence: confidence, Quality: scoreModule(ctx, module)}
+}
@@ -0,0 +1,113 @@
+package iron
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+type classifiedModule struct {
+	testModule
+	confidence float64
+}
+
+func (m classifiedModule) Classify(string) float64 {
+	return m.confidence
+}
+
+func TestNaiveBayes_Predict_PicksTrainedLabel(t *testing.T) {
+	nb := NewNaiveBayes(3)
+	nb.Train("greeting", "hello there, hi, good morning, hello friend")
+	nb.Train("number", "12 345 6789 0 42 1000 77")
+
+	if got := nb.Predict("hello"); got["greeting"] <= got["number"] {
+		t.Fatalf("Predict(hello) = %v, want greeting", got)
+	}
+	if got := nb.Predict("31415"); got["number"] <= got["greeting"] {
+		t.Fatalf("Predict(31415) = %v, want number", got)
+	}
+	if got := nb.Confidence("number", "31415"); got != 1 {
+		t.Fatalf("Confidence(number) = %v, want 1", got)
+	}
+}
+
+func TestNaiveBayes_Confidence_Concurrent(t *testing.T) {
+	nb := DefaultClassifier()
+	inputs := map[string]string{
+		"log":  "2024-05-01T10:00:00Z ERROR db: connection refused",
+		"data": `{"id": 1, "name": "disk", "usage": "42%"}`,
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		for label, input := range inputs {
+			wg.Add(1)
+			go func(label, input string) {
+				defer wg.Done()
+				if got := nb.Confidence(label, input); got != 1 {
+					t.Errorf("Confidence(%s, %q) = %v, want 1", label, input, got)
+				}
+			}(label, input)
+		}
+	}
+	wg.Wait()
+}
+
+func TestDefaultClassifier_Predict_LabelsTestdata(t *testing.T) {
+	classifier := DefaultClassifier()
+	for label, pattern := range map[string]string{"task": "*.txt", "data": "*", "log": "*", "code": "*", "web": "*.html"} {
+		paths, err := filepath.Glob(filepath.Join("testdata", label, pattern))
+		if err != nil {
+			t.Fatalf("Glob() error = %v", err)
+		}
+		for _, path := range paths {
+			text := ""
+			if label == "task" {
+				text = loadGolden(t, path).sections["input"]
+			} else {
+				data, err := os.ReadFile(path)
+				if err != nil {
+					t.Fatalf("ReadFile() error = %v", err)
+				}
+				text = string(data)
+			}
+			if got := classifier.Confidence(label, text); got != 1 {
+				t.Errorf("%s: Confidence(%s) = %.3f, want the top label; posteriors %v", path, label, got, classifier.Predict(text))
+			}
+		}
+	}
+}
+
+func TestEngine_Rank_MultipliesConfidenceAndScore(t *testing.T) {
+	engine := New(
+		WithModule(classifiedModule{testModule: testModule{name: "IR-HIGH", score: 0.9, detect: true}, confidence: 0.5}),
+		WithModule(classifiedModule{testModule: testModule{name: "IR-LOW", score: 0.6, detect: true}, confidence: 1}),
+	)
+	ranking := engine.Rank("input")
+	if len(ranking) != 3 || ranking[0].Module != "IR-LOW" || ranking[1].Module != "IR-HIGH" {
+		t.Fatalf("Rank() = %+v", ranking)
+	}
+
+	result, err := engine.ProcessDetailed("input")
+	if err != nil {
+		t.Fatalf("ProcessDetailed() error = %v", err)
+	}
+	if result.Module != "IR-LOW" || result.Confidence != 1 || len(result.Ties) != 0 {
+		t.Fatalf("result = %s confidence %v ties %v", result.Module, result.Confidence, result.Ties)
+	}
+}
+
+func TestEngine_Process_ReportsTies(t *testing.T) {
+	engine := New(
+		WithModule(testModule{name: "IR-A", score: 0.8, detect: true}),
+		WithModule(classifiedModule{testModule: testModule{name: "IR-B", score: 1, detect: true}, confidence: 0.8}),
+		WithModule(testModule{name: "IR-C", score: 0.5, detect: true}),
+	)
+	result, err := engine.ProcessDetailed("input")
+	if err != nil {
+		t.Fatalf("ProcessDetailed() error = %v", err)
+	}
+	if result.Module != "IR-A" || len(result.Ties) != 1 || result.Ties[0] != "IR-B" {
+		t.Fatalf("result = %s ties %v, want IR-A tied with IR-B", result.Module, result.Ties)
+	}
+}
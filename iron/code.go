@@ -0,0 +1,213 @@
+package iron
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// CodeModule strips Go, Python, and shell source down to what an LLM needs.
+//
+// At LevelLossless comments, blank lines, and indentation are removed and
+// Decode restores runnable, re-indented code. LevelBalanced additionally
+// collapses function bodies to their signatures ("skeleton" mode), and
+// LevelAggressive also drops imports and private declarations.
+type CodeModule struct {
+	Level Level
+}
+
+const (
+	codeGo     = "go"
+	codePython = "py"
+	codeShell  = "sh"
+	codeHeader = "@CODE"
+)
+
+// proseStopwords are frequent natural-language words that rarely appear as code tokens.
+var proseStopwords = map[string]bool{
+	"the": true, "a": true, "an": true, "and": true, "of": true, "to": true, "is": true, "are": true,
+	"was": true, "that": true, "this": true, "with": true, "we": true, "you": true, "it": true,
+	"de": true, "que": true, "e": true, "o": true, "um": true, "uma": true, "para": true, "com": true,
+	"não": true, "os": true, "as": true, "da": true, "do": true,
+}
+
+type codeStats struct {
+	comments int
+	bodies   int
+	imports  int
+	private  int
+}
+
+func (s codeStats) losses() []Loss {
+	var losses []Loss
+	if s.comments > 0 {
+		losses = append(losses, Loss{Kind: "comments", Count: s.comments, Detail: "comments removed"})
+	}
+	if s.bodies > 0 {
+		losses = append(losses, Loss{Kind: "bodies", Count: s.bodies, Detail: "function bodies collapsed to signatures"})
+	}
+	if s.imports > 0 {
+		losses = append(losses, Loss{Kind: "imports", Count: s.imports, Detail: "imports dropped"})
+	}
+	if s.private > 0 {
+		losses = append(losses, Loss{Kind: "private", Count: s.private, Detail: "private declarations dropped"})
+	}
+	return losses
+}
+
+func (CodeModule) Name() string {
+	return "IR-CODE"
+}
+
+// Detect reports whether the input parses or scans as Go, Python, or shell source.
+func (CodeModule) Detect(input string) bool {
+	_, ok := detectCodeLang(input)
+	return ok
+}
+
+// Encode strips the source at the module's level.
+func (m CodeModule) Encode(input string) (string, error) {
+	encoded, _, err := m.EncodeWithLoss(input)
+	return encoded, err
+}
+
+// EncodeWithLoss strips the source and reports what was removed.
+func (m CodeModule) EncodeWithLoss(input string) (string, []Loss, error) {
+	lang, ok := detectCodeLang(input)
+	if !ok {
+		return "", nil, fmt.Errorf("%w: input is not recognized source code", ErrInvalidIR)
+	}
+	var (
+		body  string
+		stats codeStats
+		err   error
+	)
+	switch lang {
+	case codeGo:
+		body, stats, err = encodeGo(input, m.Level)
+	case codePython:
+		body, stats = encodePython(input, m.Level)
+	default:
+		body, stats = encodeShell(input, m.Level)
+	}
+	if err != nil {
+		return "", nil, err
+	}
+	return formatHeader(codeHeader, lang, m.Level.String()) + "\n" + body, stats.losses(), nil
+}
+
+// EncodeContext encodes at the level hinted in ctx, if any.
+func (m CodeModule) EncodeContext(ctx context.Context, input string) (string, []Loss, error) {
+	if err := ctx.Err(); err != nil {
+		return "", nil, err
+	}
+	m.Level = HintsFromContext(ctx).LevelOr(m.Level)
+	return m.EncodeWithLoss(input)
+}
+
+// DecodeContext decodes unless ctx is done.
+func (m CodeModule) DecodeContext(ctx context.Context, ir string) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	return m.Decode(ir)
+}
+
+// Decode re-indents the stripped source.
+func (CodeModule) Decode(output string) (string, error) {
+	header, body, _ := strings.Cut(output, "\n")
+	args, err := parseHeader(header, codeHeader)
+	if err != nil {
+		return "", err
+	}
+	var lang string
+	if len(args) > 0 {
+		lang = args[0]
+	}
+	switch lang {
+	case codeGo:
+		return decodeGo(body), nil
+	case codePython:
+		return decodePython(body), nil
+	case codeShell:
+		return decodeShell(body), nil
+	}
+	return "", fmt.Errorf("%w: unknown code language %q", ErrInvalidIR, lang)
+}
+
+func (CodeModule) Score() float64 {
+	return 0.85
+}
+
+// Classify returns the default classifier's confidence that input is source code.
+func (CodeModule) Classify(input string) float64 {
+	return DefaultClassifier().Confidence("code", input)
+}
+
+// detectCodeLang identifies the source language using parsers and
+// structural signals rather than keywords alone.
+func detectCodeLang(input string) (string, bool) {
+	trimmed := strings.TrimSpace(input)
+	if trimmed == "" || strings.Count(trimmed, "\n") < 1 {
+		return "", false
+	}
+	if first, _, _ := strings.Cut(trimmed, "\n"); strings.HasPrefix(first, "#!") {
+		switch {
+		case strings.Contains(first, "python"):
+			return codePython, true
+		case strings.Contains(first, "sh"):
+			return codeShell, true
+		}
+	}
+	if proseRatio(trimmed) > 0.2 {
+		return "", false
+	}
+	if looksLikeGo(trimmed) {
+		return codeGo, true
+	}
+	if looksLikePython(trimmed) {
+		return codePython, true
+	}
+	if looksLikeShell(trimmed) {
+		return codeShell, true
+	}
+	return "", false
+}
+
+// proseRatio is the share of words that are common natural-language stopwords,
+// ignoring comment lines.
+func proseRatio(input string) float64 {
+	var words, stop int
+	for _, line := range strings.Split(input, "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "#") || strings.HasPrefix(line, "//") {
+			continue
+		}
+		for _, word := range strings.FieldsFunc(strings.ToLower(line), func(r rune) bool {
+			return !unicode.IsLetter(r)
+		}) {
+			words++
+			if proseStopwords[word] {
+				stop++
+			}
+		}
+	}
+	if words == 0 {
+		return 0
+	}
+	return float64(stop) / float64(words)
+}
+
+// codeLines returns the trimmed, non-blank, non-comment lines of the input.
+func codeLines(input, comment string) []string {
+	var lines []string
+	for _, line := range strings.Split(input, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, comment) {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
@@ -0,0 +1,205 @@
+package iron
+
+import (
+	"bytes"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/printer"
+	"go/scanner"
+	"go/token"
+	"strings"
+)
+
+const goFragmentPackage = "package _\n"
+
+// looksLikeGo reports whether the input parses as a Go file, a declaration
+// list, or a statement list.
+func looksLikeGo(input string) bool {
+	fset := token.NewFileSet()
+	if _, err := parser.ParseFile(fset, "", input, parser.PackageClauseOnly); err == nil {
+		_, err := parser.ParseFile(fset, "", input, 0)
+		return err == nil
+	}
+	if _, err := parser.ParseFile(fset, "", goFragmentPackage+input, 0); err == nil {
+		return true
+	}
+	lines := codeLines(input, "//")
+	if len(lines) < 2 {
+		return false
+	}
+	_, err := parser.ParseFile(fset, "", goFragmentPackage+"func _() {\n"+input+"\n}", 0)
+	return err == nil && goStatementSignals(lines)
+}
+
+// goStatementSignals guards statement-list parsing, which accepts some prose.
+func goStatementSignals(lines []string) bool {
+	var signals int
+	for _, line := range lines {
+		if strings.Contains(line, ":=") || strings.HasSuffix(line, "{") || strings.HasSuffix(line, ")") || line == "}" {
+			signals++
+		}
+	}
+	return signals*2 >= len(lines)
+}
+
+func encodeGo(input string, level Level) (string, codeStats, error) {
+	var stats codeStats
+	src := input
+	if level != LevelLossless {
+		skeleton, ok := goSkeleton(input, level, &stats)
+		if ok {
+			src = skeleton
+		}
+	}
+	body, comments := minifyGo(src)
+	stats.comments += comments
+	return body, stats, nil
+}
+
+// goSkeleton removes function bodies, and at LevelAggressive imports and
+// unexported functions, by rewriting the AST.
+func goSkeleton(input string, level Level, stats *codeStats) (string, bool) {
+	fset := token.NewFileSet()
+	src, fragment := input, false
+	file, err := parser.ParseFile(fset, "", src, parser.ParseComments)
+	if err != nil {
+		src, fragment = goFragmentPackage+input, true
+		fset = token.NewFileSet()
+		if file, err = parser.ParseFile(fset, "", src, parser.ParseComments); err != nil {
+			return "", false
+		}
+	}
+	stats.comments += len(file.Comments)
+	file.Comments = nil
+
+	decls := file.Decls[:0]
+	for _, decl := range file.Decls {
+		switch d := decl.(type) {
+		case *ast.GenDecl:
+			if d.Tok == token.IMPORT && level >= LevelAggressive {
+				stats.imports += len(d.Specs)
+				continue
+			}
+		case *ast.FuncDecl:
+			if level >= LevelAggressive && !d.Name.IsExported() && d.Name.Name != "main" {
+				stats.private++
+				continue
+			}
+			if d.Body != nil {
+				d.Body = nil
+				stats.bodies++
+			}
+		}
+		decls = append(decls, decl)
+	}
+	file.Decls = decls
+	if level >= LevelAggressive {
+		file.Imports = nil
+	}
+
+	var buf bytes.Buffer
+	if err := printer.Fprint(&buf, fset, file); err != nil {
+		return "", false
+	}
+	out := buf.String()
+	if fragment {
+		out = strings.TrimPrefix(out, goFragmentPackage)
+	}
+	return out, true
+}
+
+// minifyGo re-emits the token stream without comments, indentation, or
+// blank lines. Compiler directives are kept.
+func minifyGo(src string) (string, int) {
+	fset := token.NewFileSet()
+	file := fset.AddFile("", fset.Base(), len(src))
+	var s scanner.Scanner
+	s.Init(file, []byte(src), nil, scanner.ScanComments)
+
+	var (
+		sb          strings.Builder
+		prev        token.Token
+		atLineStart = true
+		comments    int
+	)
+	newline := func() {
+		if !atLineStart {
+			sb.WriteByte('\n')
+			atLineStart = true
+		}
+	}
+	for {
+		_, tok, lit := s.Scan()
+		if tok == token.EOF {
+			break
+		}
+		if tok == token.COMMENT {
+			if isGoDirective(lit) {
+				newline()
+				sb.WriteString(lit)
+				atLineStart = false
+				newline()
+				continue
+			}
+			comments++
+			continue
+		}
+		if tok == token.SEMICOLON && lit == "\n" {
+			newline()
+			continue
+		}
+		text := lit
+		if text == "" {
+			text = tok.String()
+		}
+		if !atLineStart && goNeedsSpace(prev, tok) {
+			sb.WriteByte(' ')
+		}
+		sb.WriteString(text)
+		atLineStart = false
+		prev = tok
+	}
+	return strings.TrimSuffix(sb.String(), "\n"), comments
+}
+
+func isGoDirective(comment string) bool {
+	return strings.HasPrefix(comment, "//go:") || strings.HasPrefix(comment, "// +build") || strings.HasPrefix(comment, "//line ")
+}
+
+// goNeedsSpace reports whether two adjacent tokens would merge without a space.
+func goNeedsSpace(prev, next token.Token) bool {
+	if goWordy(prev) && goWordy(next) {
+		return true
+	}
+	if prev == token.INT && next == token.PERIOD {
+		return true
+	}
+	return goOperator(prev) && goOperator(next)
+}
+
+func goWordy(tok token.Token) bool {
+	return tok == token.IDENT || tok.IsKeyword() || tok == token.INT || tok == token.FLOAT ||
+		tok == token.IMAG || tok == token.CHAR || tok == token.STRING
+}
+
+func goOperator(tok token.Token) bool {
+	if !tok.IsOperator() {
+		return false
+	}
+	switch tok {
+	case token.LPAREN, token.RPAREN, token.LBRACK, token.RBRACK, token.LBRACE, token.RBRACE,
+		token.COMMA, token.SEMICOLON, token.PERIOD:
+		return false
+	}
+	return true
+}
+
+// decodeGo restores gofmt layout; unformattable input is returned unchanged.
+func decodeGo(body string) string {
+	formatted, err := format.Source([]byte(body))
+	if err != nil {
+		return body
+	}
+	return strings.TrimSuffix(string(formatted), "\n")
+}
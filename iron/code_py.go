@@ -0,0 +1,258 @@
+package iron
+
+import (
+	"regexp"
+	"strings"
+)
+
+const pyIndent = "    "
+
+var (
+	pyBlockRe  = regexp.MustCompile(`^(?:def|class|if|elif|else|for|while|try|except|finally|with|async|match|case)\b.*:$`)
+	pyStmtRe   = regexp.MustCompile(`^(?:import \w|from [\w.]+ import |return\b|raise\b|yield\b|pass$|@\w)|^[\w.\[\], ]+\s*[-+*/]?=\s*\S`)
+	pyDefRe    = regexp.MustCompile(`^(?:async\s+)?def\s+(\w+)`)
+	pyImportRe = regexp.MustCompile(`^(?:import|from)\s`)
+)
+
+type pyLineKind int
+
+const (
+	pyLogical pyLineKind = iota
+	pyContinuation
+	pyString
+)
+
+// pyLine is a physical line with comments removed and indentation replaced by a depth.
+type pyLine struct {
+	text  string
+	kind  pyLineKind
+	depth int
+}
+
+// looksLikePython checks for colon-terminated blocks followed by deeper
+// indentation and a majority of statement-shaped lines.
+func looksLikePython(input string) bool {
+	lines := strings.Split(input, "\n")
+	var (
+		code, signals int
+		blocks        int
+	)
+	for i, raw := range lines {
+		line := strings.TrimSpace(raw)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		code++
+		if pyBlockRe.MatchString(line) {
+			if next := nextCodeLine(lines, i); next != "" && indentWidth(next) > indentWidth(raw) {
+				blocks++
+				signals++
+			}
+			continue
+		}
+		if pyStmtRe.MatchString(line) {
+			signals++
+		}
+	}
+	if code < 2 || strings.Contains(input, "{\n") || strings.Contains(input, ";\n") {
+		return false
+	}
+	return (blocks > 0 || signals == code) && signals*2 >= code
+}
+
+func nextCodeLine(lines []string, i int) string {
+	for _, line := range lines[i+1:] {
+		if trimmed := strings.TrimSpace(line); trimmed != "" && !strings.HasPrefix(trimmed, "#") {
+			return line
+		}
+	}
+	return ""
+}
+
+func indentWidth(line string) int {
+	width := 0
+	for _, r := range line {
+		switch r {
+		case ' ':
+			width++
+		case '\t':
+			width += 8 - width%8
+		default:
+			return width
+		}
+	}
+	return width
+}
+
+// scanPython splits source into lines, tracking strings, brackets, and
+// continuations so that comments and indentation can be rewritten safely.
+// When stripComments is false, lines are only classified.
+func scanPython(src string, stripComments bool) ([]pyLine, int) {
+	var (
+		lines    []pyLine
+		stack    = []int{0}
+		triple   string
+		brackets int
+		joined   bool
+		comments int
+	)
+	for i, raw := range strings.Split(src, "\n") {
+		switch {
+		case triple != "":
+			lines = append(lines, pyLine{text: raw, kind: pyString})
+		case brackets > 0 || joined:
+			lines = append(lines, pyLine{text: strings.TrimLeft(raw, " \t"), kind: pyContinuation, depth: len(stack)})
+		default:
+			width := indentWidth(raw)
+			if trimmed := strings.TrimSpace(raw); trimmed != "" && !strings.HasPrefix(trimmed, "#") {
+				for width < stack[len(stack)-1] && len(stack) > 1 {
+					stack = stack[:len(stack)-1]
+				}
+				if width > stack[len(stack)-1] {
+					stack = append(stack, width)
+				}
+			}
+			lines = append(lines, pyLine{text: strings.TrimLeft(raw, " \t"), kind: pyLogical, depth: len(stack) - 1})
+		}
+
+		line := &lines[len(lines)-1]
+		text, start := line.text, 0
+		if line.kind == pyString {
+			end := strings.Index(raw, triple)
+			if end < 0 {
+				continue
+			}
+			start = end + 3
+		}
+		var cut int
+		triple, brackets, cut = scanPythonLine(text, start, brackets)
+		joined = triple == "" && strings.HasSuffix(strings.TrimRight(text, " \t"), `\`)
+		if cut >= 0 && !(i == 0 && strings.HasPrefix(text, "#!")) {
+			comments++
+			if stripComments {
+				line.text = strings.TrimRight(text[:cut], " \t")
+			}
+		}
+	}
+	return lines, comments
+}
+
+// scanPythonLine scans one line from start, returning the open triple-quote
+// delimiter (if any), the updated bracket depth, and the comment offset or -1.
+func scanPythonLine(text string, start, brackets int) (string, int, int) {
+	for i := start; i < len(text); i++ {
+		switch c := text[i]; c {
+		case '#':
+			return "", brackets, i
+		case '(', '[', '{':
+			brackets++
+		case ')', ']', '}':
+			if brackets > 0 {
+				brackets--
+			}
+		case '\'', '"':
+			if strings.HasPrefix(text[i:], strings.Repeat(string(c), 3)) {
+				delim := strings.Repeat(string(c), 3)
+				end := strings.Index(text[i+3:], delim)
+				if end < 0 {
+					return delim, brackets, -1
+				}
+				i += 3 + end + 2
+				continue
+			}
+			for i++; i < len(text) && text[i] != c; i++ {
+				if text[i] == '\\' {
+					i++
+				}
+			}
+		}
+	}
+	return "", brackets, -1
+}
+
+func encodePython(input string, level Level) (string, codeStats) {
+	lines, comments := scanPython(input, true)
+	stats := codeStats{comments: comments}
+	if level != LevelLossless {
+		lines = pythonSkeleton(lines, level, &stats)
+	}
+	var out []string
+	for _, line := range lines {
+		switch {
+		case line.kind == pyString:
+			out = append(out, line.text)
+		case strings.TrimSpace(line.text) == "":
+			continue
+		default:
+			out = append(out, strings.Repeat(" ", line.depth)+line.text)
+		}
+	}
+	return strings.Join(out, "\n"), stats
+}
+
+// pythonSkeleton replaces def bodies with "..." and, at LevelAggressive,
+// drops imports and private functions.
+func pythonSkeleton(lines []pyLine, level Level, stats *codeStats) []pyLine {
+	var out []pyLine
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		if line.kind != pyLogical || strings.TrimSpace(line.text) == "" {
+			out = append(out, line)
+			continue
+		}
+		statementEnd := i
+		for statementEnd+1 < len(lines) && lines[statementEnd+1].kind != pyLogical {
+			statementEnd++
+		}
+		if level >= LevelAggressive && line.depth == 0 && pyImportRe.MatchString(line.text) {
+			stats.imports++
+			i = statementEnd
+			continue
+		}
+		match := pyDefRe.FindStringSubmatch(line.text)
+		if match == nil {
+			out = append(out, line)
+			continue
+		}
+		bodyEnd := statementEnd
+		for bodyEnd+1 < len(lines) {
+			next := lines[bodyEnd+1]
+			if next.kind == pyLogical && strings.TrimSpace(next.text) != "" && next.depth <= line.depth {
+				break
+			}
+			bodyEnd++
+		}
+		name := match[1]
+		if level >= LevelAggressive && strings.HasPrefix(name, "_") && !strings.HasSuffix(name, "__") {
+			stats.private++
+			for len(out) > 0 && out[len(out)-1].kind == pyLogical && strings.HasPrefix(out[len(out)-1].text, "@") &&
+				out[len(out)-1].depth == line.depth {
+				out = out[:len(out)-1]
+			}
+			i = bodyEnd
+			continue
+		}
+		out = append(out, lines[i:statementEnd+1]...)
+		signature := strings.TrimSpace(lines[statementEnd].text)
+		if bodyEnd > statementEnd && strings.HasSuffix(signature, ":") {
+			out = append(out, pyLine{text: "...", kind: pyLogical, depth: line.depth + 1})
+			stats.bodies++
+		}
+		i = bodyEnd
+	}
+	return out
+}
+
+// decodePython expands one-space depth markers to four-space indentation.
+func decodePython(body string) string {
+	lines, _ := scanPython(body, false)
+	out := strings.Split(body, "\n")
+	for i, line := range lines {
+		if line.kind == pyString {
+			continue
+		}
+		text := strings.TrimLeft(out[i], " ")
+		out[i] = strings.Repeat(pyIndent, len(out[i])-len(text)) + text
+	}
+	return strings.Join(out, "\n")
+}
@@ -0,0 +1,184 @@
+package iron
+
+import (
+	"regexp"
+	"strings"
+)
+
+const shIndent = "  "
+
+var (
+	shFuncRe    = regexp.MustCompile(`^(?:function\s+)?([A-Za-z_][\w-]*)\s*\(\)\s*\{?$`)
+	shHeredocRe = regexp.MustCompile(`<<-?\s*['"]?([A-Za-z_]\w*)['"]?`)
+	shCommandRe = regexp.MustCompile(`^(?:if |then$|fi$|for |while |do$|done\b|case |esac$|export |local |echo |printf |cd |set -|exit\b|return\b|[A-Za-z_]\w*=\S*|[\w./-]+(?:\s+-{1,2}[\w-]+)+|.*\s\|\s|.*\$\{?\w)`)
+	shIndentRe  = regexp.MustCompile(`(?:^|[;\s])(?:then|do|else)$|\{$|^case\s.*\sin$|^[^()]*\)$|\($`)
+	shDedentRe  = regexp.MustCompile(`^(?:(?:fi|done|esac|else|elif)\b|[})])`)
+	shImportRe  = regexp.MustCompile(`^(?:source|\.)\s+\S`)
+)
+
+// looksLikeShell requires most lines to look like commands, assignments,
+// pipelines, or control keywords.
+func looksLikeShell(input string) bool {
+	lines := codeLines(input, "#")
+	if len(lines) < 2 {
+		return false
+	}
+	var signals int
+	for _, line := range lines {
+		if shCommandRe.MatchString(line) {
+			signals++
+		}
+	}
+	return signals*3 >= len(lines)*2
+}
+
+// scanShell strips comments and indentation, leaving heredoc bodies and
+// multi-line quoted strings untouched. It returns the lines, whether each
+// line is verbatim, and the number of comments removed.
+func scanShell(src string, stripComments bool) ([]string, []bool, int) {
+	var (
+		lines    []string
+		verbatim []bool
+		heredoc  string
+		quote    byte
+		comments int
+	)
+	for i, raw := range strings.Split(src, "\n") {
+		if heredoc != "" {
+			lines, verbatim = append(lines, raw), append(verbatim, true)
+			if strings.TrimLeft(raw, "\t") == heredoc {
+				heredoc = ""
+			}
+			continue
+		}
+		text := raw
+		inQuote := quote != 0
+		if !inQuote {
+			text = strings.TrimLeft(raw, " \t")
+		}
+		var cut int
+		quote, cut = scanShellLine(text, quote)
+		if cut >= 0 && !(i == 0 && strings.HasPrefix(text, "#!")) {
+			comments++
+			if stripComments {
+				text = strings.TrimRight(text[:cut], " \t")
+			}
+		}
+		lines, verbatim = append(lines, text), append(verbatim, inQuote)
+		if quote == 0 {
+			if m := shHeredocRe.FindStringSubmatch(text); m != nil {
+				heredoc = m[1]
+			}
+		}
+	}
+	return lines, verbatim, comments
+}
+
+// scanShellLine returns the quote still open at the end of the line and the
+// offset of a comment, or -1.
+func scanShellLine(text string, quote byte) (byte, int) {
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		switch {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '\\':
+			i++
+		case c == '\'' || c == '"':
+			quote = c
+		case c == '#' && (i == 0 || text[i-1] == ' ' || text[i-1] == '\t' || text[i-1] == ';'):
+			return 0, i
+		}
+	}
+	return quote, -1
+}
+
+func encodeShell(input string, level Level) (string, codeStats) {
+	lines, verbatim, comments := scanShell(input, true)
+	stats := codeStats{comments: comments}
+	var out []string
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		if verbatim[i] {
+			out = append(out, line)
+			continue
+		}
+		if line == "" {
+			continue
+		}
+		if level >= LevelAggressive && shImportRe.MatchString(line) {
+			stats.imports++
+			continue
+		}
+		if level != LevelLossless {
+			if m := shFuncRe.FindStringSubmatch(line); m != nil {
+				if end := shellFuncEnd(lines, verbatim, i); end > i {
+					if level >= LevelAggressive && strings.HasPrefix(m[1], "_") {
+						stats.private++
+					} else {
+						out = append(out, m[1]+"() { :; }")
+						stats.bodies++
+					}
+					i = end
+					continue
+				}
+			}
+		}
+		out = append(out, line)
+	}
+	return strings.Join(out, "\n"), stats
+}
+
+// shellFuncEnd finds the line closing the function opened at start by
+// counting braces outside verbatim lines.
+func shellFuncEnd(lines []string, verbatim []bool, start int) int {
+	depth := 0
+	for i := start; i < len(lines); i++ {
+		if verbatim[i] {
+			continue
+		}
+		line := lines[i]
+		if i == start && !strings.HasSuffix(line, "{") {
+			if i+1 < len(lines) && lines[i+1] == "{" {
+				continue
+			}
+			return -1
+		}
+		if strings.HasSuffix(line, "{") {
+			depth++
+		}
+		if line == "}" || strings.HasPrefix(line, "} ") {
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// decodeShell re-indents control blocks and case arms with two spaces.
+func decodeShell(body string) string {
+	lines, verbatim, _ := scanShell(body, false)
+	depth := 0
+	for i, line := range lines {
+		if verbatim[i] || line == "" {
+			continue
+		}
+		if shDedentRe.MatchString(line) && depth > 0 {
+			depth--
+		}
+		lines[i] = strings.Repeat(shIndent, depth) + line
+		switch {
+		case shIndentRe.MatchString(line):
+			depth++
+		case strings.HasSuffix(line, ";;") && !strings.Contains(line, ")") && depth > 0:
+			depth--
+		}
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,231 @@
+package iron
+
+import (
+	"go/parser"
+	"go/token"
+	"os"
+	"strings"
+	"testing"
+)
+
+func readCodeFixture(t *testing.T, name string) string {
+	t.Helper()
+	data, err := os.ReadFile("testdata/code/" + name)
+	if err != nil {
+		t.Fatalf("read fixture: %v", err)
+	}
+	return strings.TrimSuffix(string(data), "\n")
+}
+
+func TestCodeModule_Go_LosslessCompiles(t *testing.T) {
+	input := readCodeFixture(t, "server.go")
+	module := CodeModule{}
+	encoded, losses, err := module.EncodeWithLoss(input)
+	if err != nil {
+		t.Fatalf("EncodeWithLoss() error = %v", err)
+	}
+	if !strings.HasPrefix(encoded, "@CODE[go,lossless]\n") {
+		t.Fatalf("EncodeWithLoss() header = %q", strings.SplitN(encoded, "\n", 2)[0])
+	}
+	if lossCount(losses, "comments") != 5 {
+		t.Fatalf("EncodeWithLoss() losses = %+v, want 5 comments", losses)
+	}
+	if len(encoded) >= len(input) {
+		t.Fatalf("IR length = %d, want < input length %d", len(encoded), len(input))
+	}
+	for _, want := range []string{"//go:generate stringer -type=Mode", "server [flags]   // not a comment", "x+ -y"} {
+		if !strings.Contains(encoded, want) {
+			t.Fatalf("EncodeWithLoss() missing %q\nIR:\n%s", want, encoded)
+		}
+	}
+
+	decoded, err := module.Decode(encoded)
+	if err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	if _, err := parser.ParseFile(token.NewFileSet(), "server.go", decoded, 0); err != nil {
+		t.Fatalf("decoded source does not parse: %v\n%s", err, decoded)
+	}
+	if !strings.Contains(decoded, "\tif name == \"\" {\n\t\tname = \"world\"\n\t}") {
+		t.Fatalf("Decode() not gofmt-indented:\n%s", decoded)
+	}
+}
+
+func TestCodeModule_Go_Skeleton(t *testing.T) {
+	input := readCodeFixture(t, "server.go")
+	encoded, losses, err := CodeModule{Level: LevelBalanced}.EncodeWithLoss(input)
+	if err != nil {
+		t.Fatalf("EncodeWithLoss() error = %v", err)
+	}
+	if strings.Contains(encoded, "Fprintf") || !strings.Contains(encoded, "func Handler(w http.ResponseWriter,r*http.Request)\n") {
+		t.Fatalf("EncodeWithLoss() did not collapse bodies\nIR:\n%s", encoded)
+	}
+	if lossCount(losses, "bodies") != 2 {
+		t.Fatalf("EncodeWithLoss() losses = %+v, want 2 bodies", losses)
+	}
+	decoded, err := CodeModule{}.Decode(encoded)
+	if err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	if _, err := parser.ParseFile(token.NewFileSet(), "server.go", decoded, 0); err != nil {
+		t.Fatalf("decoded skeleton does not parse: %v\n%s", err, decoded)
+	}
+
+	encoded, losses, err = CodeModule{Level: LevelAggressive}.EncodeWithLoss(input)
+	if err != nil {
+		t.Fatalf("EncodeWithLoss() error = %v", err)
+	}
+	if strings.Contains(encoded, "import") || strings.Contains(encoded, "helper") {
+		t.Fatalf("EncodeWithLoss() kept imports or private funcs\nIR:\n%s", encoded)
+	}
+	if lossCount(losses, "imports") != 2 || lossCount(losses, "private") != 1 {
+		t.Fatalf("EncodeWithLoss() losses = %+v, want 2 imports and 1 private", losses)
+	}
+}
+
+func TestCodeModule_Python_RoundTrip(t *testing.T) {
+	input := readCodeFixture(t, "jobs.py")
+	module := CodeModule{}
+	encoded, err := module.Encode(input)
+	if err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+	for _, want := range []string{
+		"@CODE[py,lossless]\n#!/usr/bin/env python3\nimport os",
+		"\n  self.jobs = jobs\n",
+		"\n    # this is not a comment\n",
+		"\n   if job.startswith(\"#\"):\n",
+	} {
+		if !strings.Contains(encoded, want) {
+			t.Fatalf("Encode() missing %q\nIR:\n%s", want, encoded)
+		}
+	}
+	if strings.Contains(encoded, "# Job runner.") || strings.Contains(encoded, "queued jobs") {
+		t.Fatalf("Encode() kept comments\nIR:\n%s", encoded)
+	}
+
+	decoded, err := module.Decode(encoded)
+	if err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	for _, want := range []string{
+		"\n    def run(self):\n        for job in self.jobs:\n            if job.startswith(\"#\"):\n                continue\n",
+		"\n    \"\"\"Runs jobs.\n\n    # this is not a comment\n    \"\"\"\n",
+	} {
+		if !strings.Contains(decoded, want) {
+			t.Fatalf("Decode() missing %q\ngot:\n%s", want, decoded)
+		}
+	}
+}
+
+func TestCodeModule_Python_Aggressive(t *testing.T) {
+	input := readCodeFixture(t, "jobs.py")
+	encoded, losses, err := CodeModule{Level: LevelAggressive}.EncodeWithLoss(input)
+	if err != nil {
+		t.Fatalf("EncodeWithLoss() error = %v", err)
+	}
+	if strings.Contains(encoded, "import") || strings.Contains(encoded, "_hidden") || strings.Contains(encoded, "@staticmethod") {
+		t.Fatalf("EncodeWithLoss() kept imports or private defs\nIR:\n%s", encoded)
+	}
+	if !strings.Contains(encoded, " def run(self):\n  ...") {
+		t.Fatalf("EncodeWithLoss() did not collapse run\nIR:\n%s", encoded)
+	}
+	if lossCount(losses, "bodies") != 2 || lossCount(losses, "imports") != 2 || lossCount(losses, "private") != 1 {
+		t.Fatalf("EncodeWithLoss() losses = %+v", losses)
+	}
+}
+
+func TestCodeModule_Shell_RoundTrip(t *testing.T) {
+	input := readCodeFixture(t, "deploy.sh")
+	module := CodeModule{}
+	encoded, err := module.Encode(input)
+	if err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+	if strings.Contains(encoded, "where to go") || strings.Contains(encoded, "# Deploy") {
+		t.Fatalf("Encode() kept comments\nIR:\n%s", encoded)
+	}
+	for _, want := range []string{"echo \"deploying to $target # now\"", "\n  keep   # this\n"} {
+		if !strings.Contains(encoded, want) {
+			t.Fatalf("Encode() missing %q\nIR:\n%s", want, encoded)
+		}
+	}
+
+	decoded, err := module.Decode(encoded)
+	if err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	want := `#!/bin/bash
+set -euo pipefail
+source ./env.sh
+deploy() {
+  local target="$1"
+  echo "deploying to $target # now"
+  if [ -z "$target" ]; then
+    exit 1
+  fi
+}
+case "$1" in
+  prod)
+    deploy prod
+    ;;
+  *) echo "usage" ;;
+esac
+cat <<EOT
+  keep   # this
+EOT`
+	if decoded != want {
+		t.Fatalf("Decode() mismatch\ngot:\n%s\nwant:\n%s", decoded, want)
+	}
+
+	encoded, losses, err := CodeModule{Level: LevelBalanced}.EncodeWithLoss(input)
+	if err != nil {
+		t.Fatalf("EncodeWithLoss() error = %v", err)
+	}
+	if !strings.Contains(encoded, "\ndeploy() { :; }\n") || lossCount(losses, "bodies") != 1 {
+		t.Fatalf("EncodeWithLoss() = %s, losses %+v, want collapsed deploy()", encoded, losses)
+	}
+}
+
+func TestCodeModule_Detect(t *testing.T) {
+	module := CodeModule{}
+	for _, input := range []string{
+		"If you want to import the data, return it\nfor each user while we wait.",
+		"Analyze the repository\nthen fix the bug",
+		"func main() { println(1) }",
+		readLogFixture(t),
+		`{"id": 1, "name": "x"}`,
+	} {
+		if module.Detect(input) {
+			t.Fatalf("Detect(%q) = true, want false", input)
+		}
+	}
+	for input, lang := range map[string]string{
+		"x := 1\nfmt.Println(x)":              codeGo,
+		"for item in items:\n    print(item)": codePython,
+		"ls -la\ngrep foo bar.txt | wc -l":    codeShell,
+	} {
+		if got, ok := detectCodeLang(input); !ok || got != lang {
+			t.Fatalf("detectCodeLang(%q) = %q, %v, want %q", input, got, ok, lang)
+		}
+	}
+}
+
+func TestCodeModule_Decode_Invalid(t *testing.T) {
+	for _, input := range []string{"package main", "@CODE[rb,lossless]\nputs 1"} {
+		if _, err := (CodeModule{}).Decode(input); err == nil {
+			t.Fatalf("Decode(%q) error = nil, want error", input)
+		}
+	}
+}
+
+func TestEngine_Process_SelectsCodeModule(t *testing.T) {
+	engine := New(WithModule(TaskModule{}), WithModule(CodeModule{}))
+	result, err := engine.ProcessDetailed(readCodeFixture(t, "server.go"))
+	if err != nil {
+		t.Fatalf("ProcessDetailed() error = %v", err)
+	}
+	if result.Module != "IR-CODE" {
+		t.Fatalf("Module = %q, want IR-CODE", result.Module)
+	}
+}
@@ -0,0 +1,677 @@
+package iron
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// DataModule compacts JSON payloads into a schema-plus-rows representation.
+//
+// Object key sets that repeat are hoisted into "#n:" schema lines, arrays of
+// objects sharing a schema become rows, and long strings that repeat are
+// interned into "$n:" lines. With a zero MaxString the encoding is lossless.
+type DataModule struct {
+	// MaxString truncates longer strings to this many runes. Zero disables truncation.
+	MaxString int
+}
+
+const (
+	dataHeader       = "@DATA"
+	dataInternMinLen = 24
+	dataElision      = "…"
+)
+
+var (
+	dataBareRe    = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.\-/]*$`)
+	dataSchemaRe  = regexp.MustCompile(`^#\d+:`)
+	dataStringsRe = regexp.MustCompile(`^\$\d+:`)
+)
+
+type dataKind int
+
+const (
+	dataNull dataKind = iota
+	dataBool
+	dataNumber
+	dataString
+	dataArray
+	dataObject
+)
+
+// dataNode is an order-preserving JSON value.
+type dataNode struct {
+	kind   dataKind
+	text   string // number literal, string value, or "true"/"false"
+	keys   []string
+	values []*dataNode
+}
+
+func (DataModule) Name() string {
+	return "IR-DATA"
+}
+
+// Detect reports whether the input is a JSON object or array.
+func (DataModule) Detect(input string) bool {
+	trimmed := strings.TrimSpace(input)
+	if trimmed == "" || (trimmed[0] != '{' && trimmed[0] != '[') {
+		return false
+	}
+	return json.Valid([]byte(trimmed))
+}
+
+// Encode converts JSON into IR.
+func (m DataModule) Encode(input string) (string, error) {
+	root, err := parseDataJSON(input)
+	if err != nil {
+		return "", err
+	}
+	if m.MaxString > 0 {
+		truncateDataStrings(root, m.MaxString)
+	}
+	enc := newDataEncoder(root)
+	return enc.encode(root), nil
+}
+
+// Decode rebuilds compact JSON from IR.
+func (DataModule) Decode(output string) (string, error) {
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	if len(lines) == 0 || strings.TrimSpace(lines[0]) != dataHeader {
+		return "", fmt.Errorf("%w: missing %s header", ErrInvalidIR, dataHeader)
+	}
+	dec := &dataDecoder{schemas: map[string][]string{}, strs: map[string]string{}}
+	var body []string
+	for _, line := range lines[1:] {
+		switch {
+		case len(body) == 0 && dataSchemaRe.MatchString(line):
+			if err := dec.defineSchema(line); err != nil {
+				return "", err
+			}
+		case len(body) == 0 && dataStringsRe.MatchString(line):
+			if err := dec.defineString(line); err != nil {
+				return "", err
+			}
+		default:
+			body = append(body, line)
+		}
+	}
+	node, err := dec.parseDocument(strings.Join(body, "\n"))
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	writeDataJSON(&buf, node)
+	return buf.String(), nil
+}
+
+func (DataModule) Score() float64 {
+	return 0.9
+}
+
+// Classify returns the default classifier's confidence that input is structured data.
+func (DataModule) Classify(input string) float64 {
+	return DefaultClassifier().Confidence("data", input)
+}
+
+func parseDataJSON(input string) (*dataNode, error) {
+	dec := json.NewDecoder(strings.NewReader(input))
+	dec.UseNumber()
+	node, err := readDataNode(dec)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, errors.New("unexpected data after JSON value")
+	}
+	return node, nil
+}
+
+func readDataNode(dec *json.Decoder) (*dataNode, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch v := tok.(type) {
+	case json.Delim:
+		switch v {
+		case '{':
+			node := &dataNode{kind: dataObject}
+			for dec.More() {
+				keyTok, err := dec.Token()
+				if err != nil {
+					return nil, err
+				}
+				value, err := readDataNode(dec)
+				if err != nil {
+					return nil, err
+				}
+				node.keys = append(node.keys, keyTok.(string))
+				node.values = append(node.values, value)
+			}
+			_, err := dec.Token()
+			return node, err
+		case '[':
+			node := &dataNode{kind: dataArray}
+			for dec.More() {
+				value, err := readDataNode(dec)
+				if err != nil {
+					return nil, err
+				}
+				node.values = append(node.values, value)
+			}
+			_, err := dec.Token()
+			return node, err
+		}
+		return nil, fmt.Errorf("unexpected delimiter %q", v)
+	case json.Number:
+		return &dataNode{kind: dataNumber, text: v.String()}, nil
+	case string:
+		return &dataNode{kind: dataString, text: v}, nil
+	case bool:
+		return &dataNode{kind: dataBool, text: strconv.FormatBool(v)}, nil
+	case nil:
+		return &dataNode{kind: dataNull}, nil
+	}
+	return nil, fmt.Errorf("unexpected token %v", tok)
+}
+
+func truncateDataStrings(node *dataNode, limit int) {
+	switch node.kind {
+	case dataString:
+		if utf8.RuneCountInString(node.text) > limit {
+			node.text = string([]rune(node.text)[:limit]) + dataElision
+		}
+	case dataArray, dataObject:
+		for _, child := range node.values {
+			truncateDataStrings(child, limit)
+		}
+	}
+}
+
+type dataEncoder struct {
+	schemaIDs   map[string]int
+	schemaOrder [][]string
+	stringIDs   map[string]int
+	stringOrder []string
+}
+
+func newDataEncoder(root *dataNode) *dataEncoder {
+	shapes := map[string]int{}
+	strs := map[string]int{}
+	countDataShapes(root, shapes, strs)
+
+	enc := &dataEncoder{schemaIDs: map[string]int{}, stringIDs: map[string]int{}}
+	enc.assign(root, shapes, strs)
+	return enc
+}
+
+func countDataShapes(node *dataNode, shapes, strs map[string]int) {
+	switch node.kind {
+	case dataString:
+		if len(node.text) >= dataInternMinLen {
+			strs[node.text]++
+		}
+	case dataObject:
+		if len(node.keys) > 0 {
+			shapes[dataShapeKey(node.keys)]++
+		}
+		fallthrough
+	case dataArray:
+		for _, child := range node.values {
+			countDataShapes(child, shapes, strs)
+		}
+	}
+}
+
+// assign numbers schemas and interned strings in document order so the
+// encoding is deterministic.
+func (e *dataEncoder) assign(node *dataNode, shapes, strs map[string]int) {
+	switch node.kind {
+	case dataString:
+		if strs[node.text] > 1 {
+			if _, ok := e.stringIDs[node.text]; !ok {
+				e.stringIDs[node.text] = len(e.stringOrder)
+				e.stringOrder = append(e.stringOrder, node.text)
+			}
+		}
+	case dataObject:
+		key := dataShapeKey(node.keys)
+		if shapes[key] > 1 {
+			if _, ok := e.schemaIDs[key]; !ok {
+				e.schemaIDs[key] = len(e.schemaOrder)
+				e.schemaOrder = append(e.schemaOrder, node.keys)
+			}
+		}
+		fallthrough
+	case dataArray:
+		for _, child := range node.values {
+			e.assign(child, shapes, strs)
+		}
+	}
+}
+
+func (e *dataEncoder) encode(root *dataNode) string {
+	var sb strings.Builder
+	sb.WriteString(dataHeader)
+	for id, keys := range e.schemaOrder {
+		fmt.Fprintf(&sb, "\n#%d:", id)
+		for i, key := range keys {
+			if i > 0 {
+				sb.WriteByte(',')
+			}
+			sb.WriteString(formatDataString(key))
+		}
+	}
+	for id, value := range e.stringOrder {
+		fmt.Fprintf(&sb, "\n$%d:%s", id, quoteDataString(value))
+	}
+	sb.WriteByte('\n')
+	e.writeValue(&sb, root)
+	return sb.String()
+}
+
+func (e *dataEncoder) writeValue(sb *strings.Builder, node *dataNode) {
+	switch node.kind {
+	case dataNull:
+		sb.WriteString("null")
+	case dataBool, dataNumber:
+		sb.WriteString(node.text)
+	case dataString:
+		if id, ok := e.stringIDs[node.text]; ok {
+			fmt.Fprintf(sb, "$%d", id)
+			return
+		}
+		sb.WriteString(formatDataString(node.text))
+	case dataObject:
+		if id, ok := e.schemaIDs[dataShapeKey(node.keys)]; ok && len(node.keys) > 0 {
+			fmt.Fprintf(sb, "#%d(", id)
+			e.writeRow(sb, node)
+			sb.WriteByte(')')
+			return
+		}
+		sb.WriteByte('{')
+		for i, key := range node.keys {
+			if i > 0 {
+				sb.WriteByte(',')
+			}
+			sb.WriteString(formatDataString(key))
+			sb.WriteByte(':')
+			e.writeValue(sb, node.values[i])
+		}
+		sb.WriteByte('}')
+	case dataArray:
+		if id, ok := e.tableSchema(node); ok {
+			fmt.Fprintf(sb, "#%d[", id)
+			for i, row := range node.values {
+				if i > 0 {
+					sb.WriteByte(';')
+				}
+				e.writeRow(sb, row)
+			}
+			sb.WriteByte(']')
+			return
+		}
+		sb.WriteByte('[')
+		for i, child := range node.values {
+			if i > 0 {
+				sb.WriteByte(',')
+			}
+			e.writeValue(sb, child)
+		}
+		sb.WriteByte(']')
+	}
+}
+
+func (e *dataEncoder) writeRow(sb *strings.Builder, node *dataNode) {
+	for i, value := range node.values {
+		if i > 0 {
+			sb.WriteByte(',')
+		}
+		e.writeValue(sb, value)
+	}
+}
+
+// tableSchema reports whether every element of the array is an object of the same schema.
+func (e *dataEncoder) tableSchema(node *dataNode) (int, bool) {
+	if len(node.values) < 2 {
+		return 0, false
+	}
+	var shape string
+	for i, child := range node.values {
+		if child.kind != dataObject || len(child.keys) == 0 {
+			return 0, false
+		}
+		key := dataShapeKey(child.keys)
+		if i == 0 {
+			shape = key
+		} else if key != shape {
+			return 0, false
+		}
+	}
+	id, ok := e.schemaIDs[shape]
+	return id, ok
+}
+
+func dataShapeKey(keys []string) string {
+	return strings.Join(keys, "\x00")
+}
+
+// formatDataString renders a string bare when unambiguous, quoted otherwise.
+func formatDataString(value string) string {
+	if dataBareRe.MatchString(value) && value != "true" && value != "false" && value != "null" {
+		return value
+	}
+	return quoteDataString(value)
+}
+
+func quoteDataString(value string) string {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	_ = enc.Encode(value)
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+func writeDataJSON(buf *bytes.Buffer, node *dataNode) {
+	switch node.kind {
+	case dataNull:
+		buf.WriteString("null")
+	case dataBool, dataNumber:
+		buf.WriteString(node.text)
+	case dataString:
+		buf.WriteString(quoteDataString(node.text))
+	case dataObject:
+		buf.WriteByte('{')
+		for i, key := range node.keys {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			buf.WriteString(quoteDataString(key))
+			buf.WriteByte(':')
+			writeDataJSON(buf, node.values[i])
+		}
+		buf.WriteByte('}')
+	case dataArray:
+		buf.WriteByte('[')
+		for i, child := range node.values {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			writeDataJSON(buf, child)
+		}
+		buf.WriteByte(']')
+	}
+}
+
+type dataDecoder struct {
+	schemas map[string][]string
+	strs    map[string]string
+	src     string
+	pos     int
+}
+
+func (d *dataDecoder) defineSchema(line string) error {
+	id, rest, ok := strings.Cut(line, ":")
+	if !ok {
+		return fmt.Errorf("%w: schema line %q", ErrInvalidIR, line)
+	}
+	d.src, d.pos = rest, 0
+	var keys []string
+	for d.pos < len(d.src) {
+		key, err := d.parseString()
+		if err != nil {
+			return err
+		}
+		keys = append(keys, key)
+		if d.pos < len(d.src) {
+			if err := d.expect(','); err != nil {
+				return err
+			}
+		}
+	}
+	d.schemas[id] = keys
+	return nil
+}
+
+func (d *dataDecoder) defineString(line string) error {
+	id, rest, ok := strings.Cut(line, ":")
+	if !ok {
+		return fmt.Errorf("%w: string line %q", ErrInvalidIR, line)
+	}
+	var value string
+	if err := json.Unmarshal([]byte(rest), &value); err != nil {
+		return fmt.Errorf("%w: string line %q: %v", ErrInvalidIR, line, err)
+	}
+	d.strs[id] = value
+	return nil
+}
+
+func (d *dataDecoder) parseDocument(body string) (*dataNode, error) {
+	d.src, d.pos = body, 0
+	node, err := d.parseValue()
+	if err != nil {
+		return nil, err
+	}
+	if d.pos != len(d.src) {
+		return nil, d.errorf("trailing data")
+	}
+	return node, nil
+}
+
+func (d *dataDecoder) parseValue() (*dataNode, error) {
+	if d.pos >= len(d.src) {
+		return nil, d.errorf("unexpected end of input")
+	}
+	switch c := d.src[d.pos]; {
+	case c == '{':
+		return d.parseObject()
+	case c == '[':
+		d.pos++
+		return d.parseArray()
+	case c == '#':
+		return d.parseSchemaValue()
+	case c == '$':
+		id := d.readWhile(func(b byte) bool { return b == '$' || (b >= '0' && b <= '9') })
+		value, ok := d.strs[id]
+		if !ok {
+			return nil, d.errorf("unknown string %s", id)
+		}
+		return &dataNode{kind: dataString, text: value}, nil
+	case c == '-' || (c >= '0' && c <= '9'):
+		literal := d.readWhile(func(b byte) bool { return strings.IndexByte("+-.eE0123456789", b) >= 0 })
+		if !json.Valid([]byte(literal)) {
+			return nil, d.errorf("invalid number %q", literal)
+		}
+		return &dataNode{kind: dataNumber, text: literal}, nil
+	default:
+		if strings.HasPrefix(d.src[d.pos:], "null") && d.atBoundary(4) {
+			d.pos += 4
+			return &dataNode{kind: dataNull}, nil
+		}
+		for _, lit := range []string{"true", "false"} {
+			if strings.HasPrefix(d.src[d.pos:], lit) && d.atBoundary(len(lit)) {
+				d.pos += len(lit)
+				return &dataNode{kind: dataBool, text: lit}, nil
+			}
+		}
+		value, err := d.parseString()
+		if err != nil {
+			return nil, err
+		}
+		return &dataNode{kind: dataString, text: value}, nil
+	}
+}
+
+func (d *dataDecoder) parseObject() (*dataNode, error) {
+	d.pos++
+	node := &dataNode{kind: dataObject}
+	if d.peek() == '}' {
+		d.pos++
+		return node, nil
+	}
+	for {
+		key, err := d.parseString()
+		if err != nil {
+			return nil, err
+		}
+		if err := d.expect(':'); err != nil {
+			return nil, err
+		}
+		value, err := d.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		node.keys = append(node.keys, key)
+		node.values = append(node.values, value)
+		if d.peek() == '}' {
+			d.pos++
+			return node, nil
+		}
+		if err := d.expect(','); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// parseArray parses elements after the opening bracket has been consumed.
+func (d *dataDecoder) parseArray() (*dataNode, error) {
+	node := &dataNode{kind: dataArray}
+	if d.peek() == ']' {
+		d.pos++
+		return node, nil
+	}
+	for {
+		value, err := d.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		node.values = append(node.values, value)
+		if d.peek() == ']' {
+			d.pos++
+			return node, nil
+		}
+		if err := d.expect(','); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (d *dataDecoder) parseSchemaValue() (*dataNode, error) {
+	id := d.readWhile(func(b byte) bool { return b == '#' || (b >= '0' && b <= '9') })
+	keys, ok := d.schemas[id]
+	if !ok {
+		return nil, d.errorf("unknown schema %s", id)
+	}
+	switch d.peek() {
+	case '(':
+		d.pos++
+		row, err := d.parseRow(keys)
+		if err != nil {
+			return nil, err
+		}
+		return row, d.expect(')')
+	case '[':
+		d.pos++
+		table := &dataNode{kind: dataArray}
+		for {
+			row, err := d.parseRow(keys)
+			if err != nil {
+				return nil, err
+			}
+			table.values = append(table.values, row)
+			if d.peek() == ']' {
+				d.pos++
+				return table, nil
+			}
+			if err := d.expect(';'); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return nil, d.errorf("expected ( or [ after schema %s", id)
+}
+
+// parseRow reads one value per schema key, leaving the terminator unconsumed.
+func (d *dataDecoder) parseRow(keys []string) (*dataNode, error) {
+	node := &dataNode{kind: dataObject, keys: keys}
+	for i := range keys {
+		if i > 0 {
+			if err := d.expect(','); err != nil {
+				return nil, err
+			}
+		}
+		value, err := d.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		node.values = append(node.values, value)
+	}
+	return node, nil
+}
+
+func (d *dataDecoder) parseString() (string, error) {
+	if d.peek() != '"' {
+		value := d.readWhile(func(b byte) bool {
+			return b == '_' || b == '.' || b == '-' || b == '/' ||
+				(b >= 'a' && b <= 'z') || (b >= 'A' && b <= 'Z') || (b >= '0' && b <= '9')
+		})
+		if value == "" {
+			return "", d.errorf("expected string")
+		}
+		return value, nil
+	}
+	end := d.pos + 1
+	for end < len(d.src) && d.src[end] != '"' {
+		if d.src[end] == '\\' {
+			end++
+		}
+		end++
+	}
+	if end >= len(d.src) {
+		return "", d.errorf("unterminated string")
+	}
+	var value string
+	if err := json.Unmarshal([]byte(d.src[d.pos:end+1]), &value); err != nil {
+		return "", d.errorf("invalid string: %v", err)
+	}
+	d.pos = end + 1
+	return value, nil
+}
+
+func (d *dataDecoder) readWhile(accept func(byte) bool) string {
+	start := d.pos
+	for d.pos < len(d.src) && accept(d.src[d.pos]) {
+		d.pos++
+	}
+	return d.src[start:d.pos]
+}
+
+func (d *dataDecoder) atBoundary(n int) bool {
+	end := d.pos + n
+	return end >= len(d.src) || strings.IndexByte(",;:)]}", d.src[end]) >= 0
+}
+
+func (d *dataDecoder) peek() byte {
+	if d.pos >= len(d.src) {
+		return 0
+	}
+	return d.src[d.pos]
+}
+
+func (d *dataDecoder) expect(c byte) error {
+	if d.peek() != c {
+		return d.errorf("expected %q", c)
+	}
+	d.pos++
+	return nil
+}
+
+func (d *dataDecoder) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: offset %d: %s", ErrInvalidIR, d.pos, fmt.Sprintf(format, args...))
+}
@@ -0,0 +1,118 @@
+package iron
+
+import (
+	"encoding/json"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDataModule_RoundTrip(t *testing.T) {
+	fixture, err := os.ReadFile("testdata/data/issues.json")
+	if err != nil {
+		t.Fatalf("read fixture: %v", err)
+	}
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{name: "scalar array", input: `[1, 2.5, -3e10, true, false, null, "x"]`},
+		{name: "nested", input: `{"a":{"b":{"c":[{"d":1},{"d":2}]}},"e":[]}`},
+		{name: "mixed array", input: `[{"id":1,"name":"a"},{"id":2},"loose",[{"id":3,"name":"c"}]]`},
+		{name: "key order", input: `{"z":1,"a":{"y":true,"x":null},"m":{"y":false,"x":"null"}}`},
+		{name: "ambiguous strings", input: `{"t":"true","n":"null","num":"42","neg":"-1","ref":"$0","schema":"#0(","empty":"","sp":"a b"}`},
+		{name: "unicode", input: `{"saudação":"olá, mundo ✓","emoji":"🚀🔥","cjk":["漢字","かな"],"esc":"line\nbreak\t\"quoted\" <html> & \\"}`},
+		{name: "weird keys", input: `[{"a b":1,"":2,"c:d":3},{"a b":4,"":5,"c:d":6}]`},
+		{name: "empty containers", input: `{"o":{},"a":[],"rows":[{},{}]}`},
+		{name: "fixture", input: string(fixture)},
+	}
+	module := DataModule{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !module.Detect(tt.input) {
+				t.Fatalf("Detect() = false, want true")
+			}
+			encoded, err := module.Encode(tt.input)
+			if err != nil {
+				t.Fatalf("Encode() error = %v", err)
+			}
+			decoded, err := module.Decode(encoded)
+			if err != nil {
+				t.Fatalf("Decode() error = %v\nIR:\n%s", err, encoded)
+			}
+			if !jsonEqual(t, tt.input, decoded) {
+				t.Fatalf("Decode() = %s, want semantic equal to %s\nIR:\n%s", decoded, tt.input, encoded)
+			}
+		})
+	}
+}
+
+func TestDataModule_Encode_HoistsSchemaAndInternsStrings(t *testing.T) {
+	fixture, err := os.ReadFile("testdata/data/issues.json")
+	if err != nil {
+		t.Fatalf("read fixture: %v", err)
+	}
+	encoded, err := DataModule{}.Encode(string(fixture))
+	if err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+	if !strings.Contains(encoded, "#0:id,title,state,labels,author,repository_url,comments,closed_at") {
+		t.Fatalf("Encode() = %s, want row schema header", encoded)
+	}
+	if !strings.Contains(encoded, `$0:"https://api.github.com/repos/iagomussel/IRon"`) {
+		t.Fatalf("Encode() = %s, want interned repository_url", encoded)
+	}
+	compact, _ := json.Marshal(json.RawMessage(fixture))
+	if len(encoded) >= len(compact) {
+		t.Fatalf("IR length = %d, want < compact JSON length %d", len(encoded), len(compact))
+	}
+}
+
+func TestDataModule_Encode_MaxStringElides(t *testing.T) {
+	encoded, err := DataModule{MaxString: 5}.Encode(`{"body":"a very long body"}`)
+	if err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+	decoded, err := DataModule{}.Decode(encoded)
+	if err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	if decoded != `{"body":"a ver…"}` {
+		t.Fatalf("Decode() = %s, want elided body", decoded)
+	}
+}
+
+func TestDataModule_Detect(t *testing.T) {
+	module := DataModule{}
+	for _, input := range []string{"plain text", "{not json}", `"just a string"`, "42", ""} {
+		if module.Detect(input) {
+			t.Errorf("Detect(%q) = true, want false", input)
+		}
+	}
+}
+
+func TestDataModule_Decode_RejectsMalformedIR(t *testing.T) {
+	module := DataModule{}
+	for _, ir := range []string{"{}", "@DATA\n#9(1)", "@DATA\n{a:1", "@DATA\n[1,2]x", "@DATA\n#0:a\n#0[1;2,3]"} {
+		if _, err := module.Decode(ir); err == nil {
+			t.Errorf("Decode(%q) error = nil, want error", ir)
+		}
+	}
+}
+
+func jsonEqual(t *testing.T, a, b string) bool {
+	t.Helper()
+	var va, vb interface{}
+	decA := json.NewDecoder(strings.NewReader(a))
+	decA.UseNumber()
+	if err := decA.Decode(&va); err != nil {
+		t.Fatalf("decode %s: %v", a, err)
+	}
+	decB := json.NewDecoder(strings.NewReader(b))
+	decB.UseNumber()
+	if err := decB.Decode(&vb); err != nil {
+		t.Fatalf("decode %s: %v", b, err)
+	}
+	return reflect.DeepEqual(va, vb)
+}
@@ -0,0 +1,233 @@
+package iron
+
+import (
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	dictHeader = "@DEF"
+	// dictAliasPrefix starts every alias, as in "^1".
+	dictAliasPrefix = "^"
+	dictMinCount    = 2
+	dictMinLength   = 12
+	dictMaxNGram    = 4
+	dictMaxEntries  = 64
+	dictMaxCounts   = 2048
+)
+
+var dictAliasRe = regexp.MustCompile(`\^\d+`)
+
+// Dictionary learns phrases that recur across the turns of a session, such
+// as paths, entity names, and project terms, and replaces them with short
+// aliases. The first text that uses an alias carries its definition in a
+// "@DEF ^1=phrase" preamble, so the model reads each definition once.
+// A Dictionary is not safe for concurrent use.
+type Dictionary struct {
+	Entries []DictEntry `json:"entries,omitempty"`
+	// Counts holds occurrences of candidate phrases not yet promoted.
+	Counts map[string]int `json:"counts,omitempty"`
+}
+
+// DictEntry maps an alias to its phrase. Sent is set once the definition
+// has been emitted.
+type DictEntry struct {
+	Alias  string `json:"alias"`
+	Phrase string `json:"phrase"`
+	Sent   bool   `json:"sent,omitempty"`
+}
+
+// NewDictionary returns an empty Dictionary.
+func NewDictionary() *Dictionary {
+	return &Dictionary{Counts: map[string]int{}}
+}
+
+// Clone returns a deep copy of the dictionary.
+func (d *Dictionary) Clone() *Dictionary {
+	clone := &Dictionary{Entries: append([]DictEntry(nil), d.Entries...), Counts: make(map[string]int, len(d.Counts))}
+	for phrase, count := range d.Counts {
+		clone.Counts[phrase] = count
+	}
+	return clone
+}
+
+// Resend marks every definition as not sent, so that Apply emits them
+// again, such as for a model that starts a new conversation.
+func (d *Dictionary) Resend() {
+	for i := range d.Entries {
+		d.Entries[i].Sent = false
+	}
+}
+
+// Learn counts the word n-grams of text and promotes phrases seen at least
+// twice to aliases, preferring those that save the most characters. Once
+// the dictionary holds dictMaxEntries aliases it stops counting and drops
+// the counts it kept.
+func (d *Dictionary) Learn(text string) {
+	if len(d.Entries) >= dictMaxEntries {
+		d.Counts = nil
+		return
+	}
+	if d.Counts == nil {
+		d.Counts = map[string]int{}
+	}
+	words := strings.Fields(text)
+	for n := 1; n <= dictMaxNGram; n++ {
+		for i := 0; i+n <= len(words); i++ {
+			ngram := words[i : i+n]
+			if !dictCandidate(ngram) {
+				continue
+			}
+			phrase := strings.Join(ngram, " ")
+			if len(phrase) >= dictMinLength && d.alias(phrase) == "" {
+				d.Counts[phrase]++
+			}
+		}
+	}
+	d.promote()
+	if len(d.Entries) >= dictMaxEntries {
+		d.Counts = nil
+		return
+	}
+	if len(d.Counts) > dictMaxCounts {
+		for phrase, count := range d.Counts {
+			if count < dictMinCount {
+				delete(d.Counts, phrase)
+			}
+		}
+	}
+}
+
+// dictCandidate rejects n-grams containing IR directives or aliases.
+func dictCandidate(words []string) bool {
+	for _, word := range words {
+		if strings.HasPrefix(word, "@") || dictAliasRe.MatchString(word) {
+			return false
+		}
+	}
+	return true
+}
+
+func (d *Dictionary) promote() {
+	var candidates []string
+	for phrase, count := range d.Counts {
+		if count >= dictMinCount {
+			candidates = append(candidates, phrase)
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		si := d.Counts[candidates[i]] * len(candidates[i])
+		sj := d.Counts[candidates[j]] * len(candidates[j])
+		if si != sj {
+			return si > sj
+		}
+		return candidates[i] < candidates[j]
+	})
+	for _, phrase := range candidates {
+		if len(d.Entries) >= dictMaxEntries {
+			break
+		}
+		delete(d.Counts, phrase)
+		if d.overlaps(phrase) {
+			continue
+		}
+		alias := dictAliasPrefix + strconv.Itoa(len(d.Entries)+1)
+		d.Entries = append(d.Entries, DictEntry{Alias: alias, Phrase: phrase})
+	}
+}
+
+// overlaps reports whether phrase contains or is contained in an entry.
+func (d *Dictionary) overlaps(phrase string) bool {
+	for _, entry := range d.Entries {
+		if strings.Contains(entry.Phrase, phrase) || strings.Contains(phrase, entry.Phrase) {
+			return true
+		}
+	}
+	return false
+}
+
+func (d *Dictionary) alias(phrase string) string {
+	for _, entry := range d.Entries {
+		if entry.Phrase == phrase {
+			return entry.Alias
+		}
+	}
+	return ""
+}
+
+// Apply substitutes aliases for known phrases and prepends the definitions
+// of aliases used for the first time. Text that already contains
+// alias-like tokens is returned unchanged, so that Expand stays exact.
+func (d *Dictionary) Apply(text string) string {
+	if len(d.Entries) == 0 || dictAliasRe.MatchString(text) {
+		return text
+	}
+	entries := append([]DictEntry(nil), d.Entries...)
+	sort.SliceStable(entries, func(i, j int) bool {
+		return len(entries[i].Phrase) > len(entries[j].Phrase)
+	})
+	quoted := make([]string, len(entries))
+	aliases := map[string]string{}
+	for i, entry := range entries {
+		quoted[i] = regexp.QuoteMeta(entry.Phrase)
+		aliases[entry.Phrase] = entry.Alias
+	}
+	var (
+		sb   strings.Builder
+		last int
+	)
+	for _, loc := range regexp.MustCompile(strings.Join(quoted, "|")).FindAllStringIndex(text, -1) {
+		// An alias followed by a digit would read as a different alias.
+		if loc[1] < len(text) && text[loc[1]] >= '0' && text[loc[1]] <= '9' {
+			continue
+		}
+		sb.WriteString(text[last:loc[0]])
+		sb.WriteString(aliases[text[loc[0]:loc[1]]])
+		last = loc[1]
+	}
+	sb.WriteString(text[last:])
+	out := sb.String()
+
+	used := map[string]bool{}
+	for _, alias := range dictAliasRe.FindAllString(out, -1) {
+		used[alias] = true
+	}
+	var defs []string
+	for i, entry := range d.Entries {
+		if used[entry.Alias] && !entry.Sent {
+			defs = append(defs, dictHeader+" "+entry.Alias+"="+entry.Phrase)
+			d.Entries[i].Sent = true
+		}
+	}
+	if len(defs) == 0 {
+		return out
+	}
+	return strings.Join(defs, "\n") + "\n" + out
+}
+
+// Expand replaces aliases with their phrases and drops @DEF lines, such as
+// in a model reply.
+func (d *Dictionary) Expand(text string) string {
+	if len(d.Entries) == 0 || !strings.Contains(text, dictAliasPrefix) {
+		return text
+	}
+	phrases := map[string]string{}
+	for _, entry := range d.Entries {
+		phrases[entry.Alias] = entry.Phrase
+	}
+	lines := strings.Split(text, "\n")
+	kept := lines[:0]
+	for _, line := range lines {
+		if !strings.HasPrefix(line, dictHeader+" ") {
+			kept = append(kept, line)
+		}
+	}
+	return dictAliasRe.ReplaceAllStringFunc(strings.Join(kept, "\n"), func(alias string) string {
+		if phrase, ok := phrases[alias]; ok {
+			return phrase
+		}
+		return alias
+	})
+}
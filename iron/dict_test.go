@@ -0,0 +1,97 @@
+package iron
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestDictionary_LearnApplyExpand(t *testing.T) {
+	dict := NewDictionary()
+	first := "fix internal/gateway/gateway.go and run go test"
+	dict.Learn(first)
+	if got := dict.Apply(first); got != first {
+		t.Fatalf("Apply() after one sighting = %q", got)
+	}
+
+	second := "now internal/gateway/gateway.go fails; see internal/gateway/gateway.go:42"
+	dict.Learn(second)
+	got := dict.Apply(second)
+	want := "@DEF ^1=internal/gateway/gateway.go\nnow ^1 fails; see ^1:42"
+	if got != want {
+		t.Fatalf("Apply() = %q, want %q", got, want)
+	}
+	if expanded := dict.Expand(got); expanded != second {
+		t.Fatalf("Expand() = %q, want %q", expanded, second)
+	}
+
+	third := "revert internal/gateway/gateway.go"
+	dict.Learn(third)
+	if got := dict.Apply(third); got != "revert ^1" {
+		t.Fatalf("Apply() after definition = %q, want no preamble", got)
+	}
+}
+
+func TestDictionary_Apply_SkipsAliasLikeText(t *testing.T) {
+	dict := NewDictionary()
+	dict.Learn("internal/gateway/gateway.go internal/gateway/gateway.go")
+	for _, text := range []string{"x^2 near internal/gateway/gateway.go", "internal/gateway/gateway.go1"} {
+		if got := dict.Apply(text); strings.Contains(got, "^1") {
+			t.Fatalf("Apply(%q) = %q", text, got)
+		}
+	}
+}
+
+func TestDictionary_JSONRoundTrip(t *testing.T) {
+	dict := NewDictionary()
+	dict.Learn("project-alpha-service project-alpha-service")
+	dict.Apply("project-alpha-service")
+	data, err := json.Marshal(dict)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	var loaded Dictionary
+	if err := json.Unmarshal(data, &loaded); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if got := loaded.Apply("deploy project-alpha-service"); got != "deploy ^1" {
+		t.Fatalf("Apply() after reload = %q", got)
+	}
+	clone := loaded.Clone()
+	clone.Entries[0].Phrase = "changed"
+	if loaded.Entries[0].Phrase != "project-alpha-service" {
+		t.Fatal("Clone() shares entries")
+	}
+}
+
+func TestDictionary_Resend(t *testing.T) {
+	dict := NewDictionary()
+	dict.Learn("project-alpha-service project-alpha-service")
+	if got := dict.Apply("deploy project-alpha-service"); got != "@DEF ^1=project-alpha-service\ndeploy ^1" {
+		t.Fatalf("Apply() = %q", got)
+	}
+	dict.Resend()
+	if got := dict.Apply("deploy project-alpha-service"); got != "@DEF ^1=project-alpha-service\ndeploy ^1" {
+		t.Fatalf("Apply() after Resend = %q, want the definition again", got)
+	}
+}
+
+func TestDictionary_Learn_DropsCountsWhenFull(t *testing.T) {
+	dict := NewDictionary()
+	for i := 0; len(dict.Entries) < dictMaxEntries; i++ {
+		phrase := fmt.Sprintf("service-%03d.internal", i)
+		dict.Learn(phrase + " " + phrase)
+	}
+	dict.Learn("another-long-phrase once and another-unique-phrase")
+	if len(dict.Counts) != 0 {
+		t.Fatalf("Counts = %d phrases, want none once the dictionary is full", len(dict.Counts))
+	}
+	data, err := json.Marshal(dict)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	if strings.Contains(string(data), `"counts"`) {
+		t.Fatalf("Marshal() = %s, want no counts", data)
+	}
+}
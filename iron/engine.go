@@ -1,8 +1,15 @@
 package iron
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
+	"fmt"
+	"math"
+	"sort"
 	"strings"
+	"time"
 )
 
 // Normalizer prepares input for module selection and encoding.
@@ -13,6 +20,13 @@ type Engine struct {
 	modules     []IRModule
 	normalizers []Normalizer
 	cache       Cache
+	tokenizer   Tokenizer
+	profiler    *Profiler
+	validators  []Validator
+	segment     bool
+	redactor    *Redactor
+	// moduleKey identifies the registered module set in cache keys.
+	moduleKey string
 }
 
 // Option configures the Engine.
@@ -47,18 +61,82 @@ func WithCache(cache Cache) Option {
 	}
 }
 
+// WithTokenizer replaces the default BPETokenizer used to count tokens.
+func WithTokenizer(tokenizer Tokenizer) Option {
+	return func(e *Engine) {
+		if tokenizer == nil {
+			return
+		}
+		e.tokenizer = tokenizer
+	}
+}
+
+// WithProfiler records every processed result in the profiler.
+func WithProfiler(profiler *Profiler) Option {
+	return func(e *Engine) {
+		e.profiler = profiler
+	}
+}
+
+// WithValidator checks every decoded output against the normalized input.
+// A module whose output fails is skipped in favor of the next-best module.
+func WithValidator(validator Validator) Option {
+	return func(e *Engine) {
+		if validator == nil {
+			return
+		}
+		e.validators = append(e.validators, validator)
+	}
+}
+
+// WithSegmentation splits mixed inputs, such as prose around a JSON blob or
+// a log excerpt, into regions that are each encoded by their best module.
+func WithSegmentation() Option {
+	return func(e *Engine) {
+		e.segment = true
+	}
+}
+
+// WithRedactor replaces sensitive values with placeholders after
+// normalization. The IR and cache keep the placeholders; Result.Input and
+// Result.Output are restored, and Result.Redactions reports what was replaced.
+func WithRedactor(redactor *Redactor) Option {
+	return func(e *Engine) {
+		e.redactor = redactor
+	}
+}
+
 // New creates a new Engine with a passthrough module by default.
 func New(options ...Option) *Engine {
 	e := &Engine{
 		modules:     []IRModule{PassthroughModule{}},
 		normalizers: []Normalizer{strings.TrimSpace},
+		tokenizer:   BPETokenizer{},
 	}
 	for _, option := range options {
 		option(e)
 	}
+	e.moduleKey = moduleSetKey(e.modules)
 	return e
 }
 
+// NewDefault creates an Engine with the built-in IR-TASK, IR-DATA, IR-LOG,
+// IR-CODE, IR-WEB, IR-KNOW, IR-PIPE, and IR-META modules registered before
+// any other options.
+func NewDefault(options ...Option) *Engine {
+	builtins := []Option{
+		WithModule(TaskModule{}),
+		WithModule(DataModule{}),
+		WithModule(LogModule{}),
+		WithModule(CodeModule{}),
+		WithModule(WebModule{}),
+		WithModule(KnowModule{}),
+		WithModule(PipeModule{}),
+		WithModule(MetaModule{}),
+	}
+	return New(append(builtins, options...)...)
+}
+
 // RegisterModule registers a module with validation.
 func (e *Engine) RegisterModule(module IRModule) error {
 	if module == nil {
@@ -68,9 +146,25 @@ func (e *Engine) RegisterModule(module IRModule) error {
 		return ErrEmptyModuleName
 	}
 	e.modules = append(e.modules, module)
+	e.moduleKey = moduleSetKey(e.modules)
 	return nil
 }
 
+// CacheStats returns the statistics of the engine's cache, if it has one
+// that reports them.
+func (e *Engine) CacheStats() (CacheStats, bool) {
+	reporter, ok := e.cache.(StatsReporter)
+	if !ok {
+		return CacheStats{}, false
+	}
+	return reporter.Stats(), true
+}
+
+// Tokenizer returns the tokenizer that counts Result tokens.
+func (e *Engine) Tokenizer() Tokenizer {
+	return e.tokenizer
+}
+
 // Modules returns the registered modules in order.
 func (e *Engine) Modules() []IRModule {
 	modules := make([]IRModule, len(e.modules))
@@ -87,47 +181,370 @@ func (e *Engine) Process(input string) (string, error) {
 	return result.Output, nil
 }
 
-// ProcessDetailed returns the IR and output with metadata.
+// ProcessDetailed returns the IR and output with metadata, including token
+// counts and timings.
 func (e *Engine) ProcessDetailed(input string) (Result, error) {
+	return e.ProcessContext(context.Background(), input)
+}
+
+// ProcessContext is ProcessDetailed with cancellation and per-call options.
+func (e *Engine) ProcessContext(ctx context.Context, input string, options ...CallOption) (Result, error) {
+	var call callOptions
+	for _, option := range options {
+		option(&call)
+	}
+	result, err := e.process(ContextWithHints(ctx, call.hints), input, call)
+	if err != nil {
+		return Result{}, err
+	}
+	if e.profiler != nil {
+		e.profiler.Record(result)
+	}
+	return result, nil
+}
+
+func (e *Engine) process(ctx context.Context, input string, call callOptions) (Result, error) {
+	if err := ctx.Err(); err != nil {
+		return Result{}, err
+	}
 	normalized := e.normalize(input)
-	if e.cache != nil {
-		if cached, ok := e.cache.Get(normalized); ok {
+	var redactions []Redaction
+	if e.redactor != nil {
+		normalized, redactions = e.redactor.Redact(normalized)
+	}
+	key := e.cacheKey(normalized, call)
+	cache := e.cache
+	if call.skipCache {
+		cache = nil
+	}
+	if cache != nil {
+		if cached, ok := cache.Get(key); ok {
 			cached.Cached = true
-			return cached, nil
+			return restoreResult(cached, redactions), nil
 		}
 	}
 
-	module := e.selectModule(normalized)
-	if module == nil {
-		result := Result{Input: normalized, Output: normalized}
-		if e.cache != nil {
-			e.cache.Set(normalized, result)
+	modules, ranking, err := e.candidates(ctx, normalized, call)
+	if err != nil {
+		return Result{}, err
+	}
+	var (
+		result    Result
+		rejected  []Rejection
+		segmented bool
+	)
+	if segments := e.segments(normalized, call); len(segments) > 1 {
+		if result, rejected, segmented, err = e.processSegments(ctx, normalized, segments, call); err != nil {
+			return Result{}, err
+		}
+	}
+	if !segmented {
+		if result, rejected, err = e.selectModule(ctx, modules, normalized); err != nil {
+			return Result{}, err
+		}
+		rankResult(&result, ranking)
+	}
+	if budget := call.hints.TokenBudget; budget > 0 && result.IRTokens > budget {
+		if result, err = e.escalate(ctx, modules, normalized, call, result); err != nil {
+			return Result{}, err
 		}
-		return result, nil
 	}
+	result.Rejected = rejected
+	if cache != nil {
+		cache.Set(key, result)
+	}
+	return restoreResult(result, redactions), nil
+}
+
+// segments splits the input when segmentation is enabled and no module is forced.
+func (e *Engine) segments(input string, call callOptions) []Segment {
+	if !e.segment || call.module != "" {
+		return nil
+	}
+	return SegmentInput(input)
+}
 
-	encoded, err := module.Encode(normalized)
+// selectModule returns the first module result that passes validation, or
+// the normalized input unchanged when every module is rejected.
+func (e *Engine) selectModule(ctx context.Context, modules []IRModule, input string) (Result, []Rejection, error) {
+	var rejected []Rejection
+	for _, module := range modules {
+		result, err := e.run(ctx, module, input)
+		if err != nil {
+			return Result{}, nil, err
+		}
+		if rejection, ok := e.validate(module, input, result.Output); !ok {
+			rejected = append(rejected, rejection)
+			continue
+		}
+		return result, rejected, nil
+	}
+	result := Result{Input: input, Output: input}
+	e.countTokens(&result, input)
+	return result, rejected, nil
+}
+
+// escalate retries the candidates at each lossier level, in rank order,
+// until the IR fits the call's token budget. Escalated attempts skip
+// validation because the caller asked for the loss. When nothing fits, the
+// smallest attempt is returned with OverBudget set if the call is
+// BestEffort, and ErrTokenBudget otherwise.
+func (e *Engine) escalate(ctx context.Context, modules []IRModule, input string, call callOptions, best Result) (Result, error) {
+	budget := call.hints.TokenBudget
+	seen := map[string]bool{best.Module + "\x00" + best.IR: true}
+	var steps []Escalation
+	for level := call.hints.LevelOr(LevelLossless) + 1; level <= LevelAggressive; level++ {
+		hints := call.hints
+		hints.Level, hints.HasLevel = level, true
+		levelCtx := ContextWithHints(ctx, hints)
+		for _, module := range modules {
+			if _, ok := module.(PassthroughModule); ok {
+				continue
+			}
+			result, err := e.run(levelCtx, module, input)
+			if err != nil {
+				return Result{}, err
+			}
+			if seen[result.Module+"\x00"+result.IR] {
+				continue
+			}
+			seen[result.Module+"\x00"+result.IR] = true
+			steps = append(steps, Escalation{Module: result.Module, Level: level, IRTokens: result.IRTokens})
+			if result.IRTokens <= budget {
+				result.Escalations = steps
+				return result, nil
+			}
+			if result.IRTokens < best.IRTokens {
+				best = result
+			}
+		}
+	}
+	if !call.bestEffort {
+		return Result{}, fmt.Errorf("%w: smallest IR has %d tokens, budget %d", ErrTokenBudget, best.IRTokens, budget)
+	}
+	best.Escalations = steps
+	best.OverBudget = true
+	return best, nil
+}
+
+// Decode restores IR produced by a registered module, such as an IR reply
+// from a model. With ForceModule only the named module decodes the IR.
+// Otherwise modules are tried in registration order, and IR without an "@"
+// header that none of them accepts is taken as the passthrough module's,
+// which returns it unchanged; ErrInvalidIR is returned when no module
+// accepts the IR.
+func (e *Engine) Decode(ctx context.Context, ir string, options ...CallOption) (Result, error) {
+	var call callOptions
+	for _, option := range options {
+		option(&call)
+	}
+	ctx = ContextWithHints(ctx, call.hints)
+	if call.module == segmentModule || call.module == "" && strings.HasPrefix(ir, segmentHeader+"[") {
+		return e.decodeSegments(ctx, ir)
+	}
+	if call.module != "" {
+		module, ok := e.module(call.module)
+		if !ok {
+			return Result{}, fmt.Errorf("%w: %s", ErrUnknownModule, call.module)
+		}
+		return decodeResult(ctx, module, ir)
+	}
+	var passthrough IRModule
+	for _, module := range e.modules {
+		if module == nil {
+			continue
+		}
+		if _, ok := module.(PassthroughModule); ok {
+			passthrough = module
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return Result{}, err
+		}
+		if result, err := decodeResult(ctx, module, ir); err == nil {
+			return result, nil
+		}
+	}
+	if passthrough != nil && !strings.HasPrefix(strings.TrimSpace(ir), "@") {
+		return decodeResult(ctx, passthrough, ir)
+	}
+	return Result{}, fmt.Errorf("%w: no registered module decodes it", ErrInvalidIR)
+}
+
+// decodeResult decodes ir with module.
+func decodeResult(ctx context.Context, module IRModule, ir string) (Result, error) {
+	start := time.Now()
+	decoded, err := decodeModule(ctx, module, ir)
 	if err != nil {
 		return Result{}, err
 	}
-	decoded, err := module.Decode(encoded)
+	return Result{
+		Module:         module.Name(),
+		IR:             ir,
+		Output:         decoded,
+		Score:          scoreModule(ctx, module),
+		DecodeDuration: time.Since(start),
+	}, nil
+}
+
+// candidates returns the modules to try in order, with their ranking: the
+// forced module alone, or the detecting modules ranked by domain hint, then
+// confidence times score.
+func (e *Engine) candidates(ctx context.Context, input string, call callOptions) ([]IRModule, []Candidate, error) {
+	if call.module != "" {
+		if module, ok := e.module(call.module); ok {
+			return []IRModule{module}, []Candidate{newCandidate(ctx, module, input)}, nil
+		}
+		return nil, nil, fmt.Errorf("%w: %s", ErrUnknownModule, call.module)
+	}
+	modules, ranking := e.rankModules(ctx, input)
+	if domain := call.hints.Domain; domain != "" {
+		order := make([]int, len(modules))
+		for i := range order {
+			order[i] = i
+		}
+		sort.SliceStable(order, func(i, j int) bool {
+			return matchesDomain(modules[order[i]], domain) && !matchesDomain(modules[order[j]], domain)
+		})
+		sortedModules := make([]IRModule, len(modules))
+		sortedRanking := make([]Candidate, len(ranking))
+		for i, index := range order {
+			sortedModules[i], sortedRanking[i] = modules[index], ranking[index]
+		}
+		modules, ranking = sortedModules, sortedRanking
+	}
+	return modules, ranking, nil
+}
+
+// Rank returns the modules that detect input, ordered by confidence times
+// quality. Modules that tie keep their registration order.
+func (e *Engine) Rank(input string) []Candidate {
+	_, ranking := e.rankModules(context.Background(), e.normalize(input))
+	return ranking
+}
+
+// module returns the registered module with the given name.
+func (e *Engine) module(name string) (IRModule, bool) {
+	for _, module := range e.modules {
+		if module != nil && module.Name() == name {
+			return module, true
+		}
+	}
+	return nil, false
+}
+
+func matchesDomain(module IRModule, domain string) bool {
+	name := module.Name()
+	return strings.EqualFold(name, domain) || strings.EqualFold(name, "IR-"+domain)
+}
+
+// run encodes and decodes the input with a single module.
+func (e *Engine) run(ctx context.Context, module IRModule, input string) (Result, error) {
+	if err := ctx.Err(); err != nil {
+		return Result{}, err
+	}
+	start := time.Now()
+	encoded, losses, err := encodeModule(ctx, module, input)
 	if err != nil {
 		return Result{}, err
 	}
+	encodeDuration := time.Since(start)
+	if err := ctx.Err(); err != nil {
+		return Result{}, err
+	}
+	start = time.Now()
+	decoded, err := decodeModule(ctx, module, encoded)
+	if err != nil {
+		return Result{}, err
+	}
+	decodeDuration := time.Since(start)
 
 	result := Result{
 		Module: module.Name(),
-		Input:  normalized,
+		Input:  input,
 		IR:     encoded,
 		Output: decoded,
-		Score:  module.Score(),
-	}
-	if e.cache != nil {
-		e.cache.Set(normalized, result)
+		Score:  scoreModule(ctx, module),
+		Losses: losses,
+
+		EncodeDuration: encodeDuration,
+		DecodeDuration: decodeDuration,
 	}
+	e.countTokens(&result, encoded)
 	return result, nil
 }
 
+// validate runs every validator against the decoded output.
+func (e *Engine) validate(module IRModule, input, output string) (Rejection, bool) {
+	for _, validator := range e.validators {
+		if err := validator.Validate(input, output); err != nil {
+			return Rejection{Module: module.Name(), Validator: validator.Name(), Reason: err.Error()}, false
+		}
+	}
+	return Rejection{}, true
+}
+
+// cacheKey scopes a normalized input to the registered module set and the
+// call options, so that registering or reconfiguring a module never serves
+// stale IR.
+func (e *Engine) cacheKey(normalized string, call callOptions) string {
+	key := e.moduleKey
+	if e.segment {
+		key += "+seg"
+	}
+	return key + call.cacheScope() + "\x00" + normalized
+}
+
+// moduleSetKey hashes the type, name, and configuration of every module.
+func moduleSetKey(modules []IRModule) string {
+	hash := sha256.New()
+	for _, module := range modules {
+		fmt.Fprintf(hash, "%s\x00%T\x00%+v\x00", module.Name(), module, module)
+	}
+	return hex.EncodeToString(hash.Sum(nil)[:8])
+}
+
+// countTokens fills the token fields of the result for the given IR.
+func (e *Engine) countTokens(result *Result, ir string) {
+	result.InputTokens = e.tokenizer.Count(result.Input)
+	result.IRTokens = e.tokenizer.Count(ir)
+	if result.InputTokens > 0 {
+		result.Ratio = float64(result.IRTokens) / float64(result.InputTokens)
+	}
+}
+
+func encodeModule(ctx context.Context, module IRModule, input string) (string, []Loss, error) {
+	if contextual, ok := module.(ContextModule); ok {
+		return contextual.EncodeContext(ctx, input)
+	}
+	if reporter, ok := module.(LossReporter); ok {
+		return reporter.EncodeWithLoss(input)
+	}
+	encoded, err := module.Encode(input)
+	return encoded, nil, err
+}
+
+func decodeModule(ctx context.Context, module IRModule, ir string) (string, error) {
+	if contextual, ok := module.(ContextModule); ok {
+		return contextual.DecodeContext(ctx, ir)
+	}
+	return module.Decode(ir)
+}
+
+func detectModule(ctx context.Context, module IRModule, input string) bool {
+	if contextual, ok := module.(ContextDetector); ok {
+		return contextual.DetectContext(ctx, input)
+	}
+	return module.Detect(input)
+}
+
+func scoreModule(ctx context.Context, module IRModule) float64 {
+	if contextual, ok := module.(ContextDetector); ok {
+		return contextual.ScoreContext(ctx)
+	}
+	return module.Score()
+}
+
 func (e *Engine) normalize(input string) string {
 	value := input
 	for _, normalizer := range e.normalizers {
@@ -136,30 +553,64 @@ func (e *Engine) normalize(input string) string {
 	return value
 }
 
-func (e *Engine) selectModule(input string) IRModule {
+// rankModules returns the modules that detect the input, best score first.
+// Ties keep registration order.
+func (e *Engine) rankModules(ctx context.Context, input string) ([]IRModule, []Candidate) {
 	var (
-		bestModule IRModule
-		bestScore  float64
+		modules []IRModule
+		ranking []Candidate
 	)
 	for _, module := range e.modules {
 		if module == nil {
 			continue
 		}
-		if !module.Detect(input) {
+		if !detectModule(ctx, module, input) {
 			continue
 		}
-		score := module.Score()
-		if bestModule == nil || score > bestScore {
-			bestModule = module
-			bestScore = score
+		modules = append(modules, module)
+		ranking = append(ranking, newCandidate(ctx, module, input))
+	}
+	order := make([]int, len(modules))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return ranking[order[i]].Rank() > ranking[order[j]].Rank()+rankEpsilon
+	})
+	sortedModules := make([]IRModule, len(modules))
+	sortedRanking := make([]Candidate, len(ranking))
+	for i, index := range order {
+		sortedModules[i], sortedRanking[i] = modules[index], ranking[index]
+	}
+	return sortedModules, sortedRanking
+}
+
+// rankResult records the confidence of the chosen module and the modules
+// that ranked equal to it.
+func rankResult(result *Result, ranking []Candidate) {
+	var chosen *Candidate
+	for i := range ranking {
+		if ranking[i].Module == result.Module {
+			chosen = &ranking[i]
+		}
+	}
+	if chosen == nil {
+		return
+	}
+	result.Confidence = chosen.Confidence
+	for _, candidate := range ranking {
+		if candidate.Module != chosen.Module && math.Abs(candidate.Rank()-chosen.Rank()) <= rankEpsilon {
+			result.Ties = append(result.Ties, candidate.Module)
 		}
 	}
-	return bestModule
 }
 
 var (
 	ErrEmptyModuleName = errors.New("module name cannot be empty")
 	ErrNilModule       = errors.New("module cannot be nil")
+	ErrInvalidIR       = errors.New("invalid IR")
+	ErrUnknownModule   = errors.New("unknown module")
+	ErrTokenBudget     = errors.New("token budget exceeded")
 )
 
 // PassthroughModule is the default module that preserves input.
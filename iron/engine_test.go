@@ -1,6 +1,8 @@
 package iron
 
 import (
+	"context"
+	"errors"
 	"strings"
 	"testing"
 )
@@ -104,3 +106,42 @@ func TestEngine_RegisterModule_ValidatesName(t *testing.T) {
 		t.Fatalf("RegisterModule() error = nil, want error")
 	}
 }
+
+func TestEngine_Decode_FindsModule(t *testing.T) {
+	engine := NewDefault()
+	encoded, err := DataModule{}.Encode(`{"name":"iron","tags":["ir","llm"]}`)
+	if err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+	result, err := engine.Decode(context.Background(), encoded)
+	if err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	if result.Module != "IR-DATA" || !strings.Contains(result.Output, `"tags"`) {
+		t.Fatalf("Decode() = %+v, want IR-DATA output", result)
+	}
+	if _, err := engine.Decode(context.Background(), "@NOPE{x}"); !errors.Is(err, ErrInvalidIR) {
+		t.Fatalf("Decode() error = %v, want ErrInvalidIR", err)
+	}
+	if result, err := engine.Decode(context.Background(), "plain text"); err != nil || result.Module != "IR-PASS" || result.Output != "plain text" {
+		t.Fatalf("Decode(plain text) = %+v, %v, want the passthrough output", result, err)
+	}
+}
+
+func TestEngine_Decode_ForceModule(t *testing.T) {
+	engine := NewDefault()
+	result, err := engine.Decode(context.Background(), "@TASK looks like a task", ForceModule("IR-PASS"))
+	if err != nil || result.Module != "IR-PASS" || result.Output != "@TASK looks like a task" {
+		t.Fatalf("Decode() = %+v, %v, want the passthrough output", result, err)
+	}
+	if _, err := engine.Decode(context.Background(), "@TASK x", ForceModule("missing")); !errors.Is(err, ErrUnknownModule) {
+		t.Fatalf("Decode() error = %v, want ErrUnknownModule", err)
+	}
+	encoded, err := DataModule{}.Encode(`{"name":"iron"}`)
+	if err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+	if _, err := engine.Decode(context.Background(), encoded, ForceModule("IR-LOG")); err == nil {
+		t.Fatal("Decode() with the wrong module error = nil")
+	}
+}
@@ -0,0 +1,262 @@
+package iron
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"sync"
+	"time"
+)
+
+// ExternalModule is an IRModule served by a long-lived subprocess that
+// speaks line-delimited JSON on stdin and stdout. Each request is one line:
+//
+//	{"id":1,"op":"encode","input":"...","level":"balanced"}
+//
+// where op is detect, encode, decode, or score, and the process answers
+// with one line carrying the same id:
+//
+//	{"id":1,"detect":true,"output":"...","score":0.8,"losses":[...],"error":"..."}
+//
+// Requests are sent one at a time. A process that crashes, times out, or
+// answers out of turn is killed and restarted on the next request.
+type ExternalModule struct {
+	ModuleName string
+	Command    []string
+	// Timeout bounds each request; zero relies on the caller's context.
+	Timeout time.Duration
+	// Stderr receives the process's standard error, if set.
+	Stderr io.Writer
+
+	mu       sync.Mutex
+	proc     *externalProcess
+	nextID   uint64
+	score    float64
+	hasScore bool
+}
+
+type externalRequest struct {
+	ID    uint64 `json:"id"`
+	Op    string `json:"op"`
+	Input string `json:"input,omitempty"`
+	Level string `json:"level,omitempty"`
+}
+
+type externalResponse struct {
+	ID     uint64         `json:"id"`
+	Detect bool           `json:"detect"`
+	Output string         `json:"output"`
+	Score  float64        `json:"score"`
+	Losses []externalLoss `json:"losses"`
+	Error  string         `json:"error"`
+}
+
+type externalLoss struct {
+	Kind   string `json:"kind"`
+	Count  int    `json:"count"`
+	Detail string `json:"detail"`
+}
+
+type externalProcess struct {
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stdout *bufio.Reader
+}
+
+// ErrExternalModule wraps failures talking to an external module process.
+var ErrExternalModule = errors.New("external module")
+
+func (m *ExternalModule) Name() string {
+	return m.ModuleName
+}
+
+// String identifies the module by name and command, keeping cache keys
+// stable while the process state changes.
+func (m *ExternalModule) String() string {
+	return fmt.Sprintf("external:%s:%q", m.ModuleName, m.Command)
+}
+
+func (m *ExternalModule) Detect(input string) bool {
+	return m.DetectContext(context.Background(), input)
+}
+
+// DetectContext asks the process; failures count as not detected.
+func (m *ExternalModule) DetectContext(ctx context.Context, input string) bool {
+	resp, err := m.call(ctx, externalRequest{Op: "detect", Input: input})
+	return err == nil && resp.Detect
+}
+
+func (m *ExternalModule) Encode(input string) (string, error) {
+	encoded, _, err := m.EncodeContext(context.Background(), input)
+	return encoded, err
+}
+
+func (m *ExternalModule) Decode(ir string) (string, error) {
+	return m.DecodeContext(context.Background(), ir)
+}
+
+// EncodeContext sends the hinted level, if any, and returns the reported losses.
+func (m *ExternalModule) EncodeContext(ctx context.Context, input string) (string, []Loss, error) {
+	req := externalRequest{Op: "encode", Input: input}
+	if hints := HintsFromContext(ctx); hints.HasLevel {
+		req.Level = hints.Level.String()
+	}
+	resp, err := m.call(ctx, req)
+	if err != nil {
+		return "", nil, err
+	}
+	var losses []Loss
+	for _, loss := range resp.Losses {
+		losses = append(losses, Loss{Kind: loss.Kind, Count: loss.Count, Detail: loss.Detail})
+	}
+	return resp.Output, losses, nil
+}
+
+func (m *ExternalModule) DecodeContext(ctx context.Context, ir string) (string, error) {
+	resp, err := m.call(ctx, externalRequest{Op: "decode", Input: ir})
+	if err != nil {
+		return "", err
+	}
+	return resp.Output, nil
+}
+
+func (m *ExternalModule) Score() float64 {
+	return m.ScoreContext(context.Background())
+}
+
+// ScoreContext asks the process once and remembers the answer; failures
+// score 0 and are asked again next time.
+func (m *ExternalModule) ScoreContext(ctx context.Context) float64 {
+	m.mu.Lock()
+	if m.hasScore {
+		defer m.mu.Unlock()
+		return m.score
+	}
+	m.mu.Unlock()
+	resp, err := m.call(ctx, externalRequest{Op: "score"})
+	if err != nil {
+		return 0
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.score, m.hasScore = resp.Score, true
+	return m.score
+}
+
+// Close stops the process. A later request starts it again.
+func (m *ExternalModule) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.stop()
+	return nil
+}
+
+// call sends one request, retrying once on a fresh process when the
+// running one has died.
+func (m *ExternalModule) call(ctx context.Context, req externalRequest) (externalResponse, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, m.Timeout)
+		defer cancel()
+	}
+	restarted := m.proc == nil
+	for {
+		if err := m.start(); err != nil {
+			return externalResponse{}, fmt.Errorf("%w: %s: start: %v", ErrExternalModule, m.ModuleName, err)
+		}
+		m.nextID++
+		req.ID = m.nextID
+		resp, err := m.roundTrip(ctx, req)
+		if err == nil {
+			if resp.Error != "" {
+				return externalResponse{}, fmt.Errorf("%w: %s: %s: %s", ErrExternalModule, m.ModuleName, req.Op, resp.Error)
+			}
+			return resp, nil
+		}
+		m.stop()
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return externalResponse{}, fmt.Errorf("%w: %s: %s: %w", ErrExternalModule, m.ModuleName, req.Op, ctxErr)
+		}
+		if restarted {
+			return externalResponse{}, fmt.Errorf("%w: %s: %s: %v", ErrExternalModule, m.ModuleName, req.Op, err)
+		}
+		restarted = true
+	}
+}
+
+// roundTrip writes the request and waits for its response or ctx.
+func (m *ExternalModule) roundTrip(ctx context.Context, req externalRequest) (externalResponse, error) {
+	line, err := json.Marshal(req)
+	if err != nil {
+		return externalResponse{}, err
+	}
+	if _, err := m.proc.stdin.Write(append(line, '\n')); err != nil {
+		return externalResponse{}, err
+	}
+
+	type reply struct {
+		resp externalResponse
+		err  error
+	}
+	done := make(chan reply, 1)
+	stdout := m.proc.stdout
+	go func() {
+		var r reply
+		line, err := stdout.ReadBytes('\n')
+		if err != nil {
+			r.err = err
+		} else if err := json.Unmarshal(line, &r.resp); err != nil {
+			r.err = fmt.Errorf("bad response: %v", err)
+		} else if r.resp.ID != req.ID {
+			r.err = fmt.Errorf("response id %d, want %d", r.resp.ID, req.ID)
+		}
+		done <- r
+	}()
+	select {
+	case r := <-done:
+		return r.resp, r.err
+	case <-ctx.Done():
+		return externalResponse{}, ctx.Err()
+	}
+}
+
+func (m *ExternalModule) start() error {
+	if m.proc != nil {
+		return nil
+	}
+	if len(m.Command) == 0 {
+		return errors.New("command is required")
+	}
+	cmd := exec.Command(m.Command[0], m.Command[1:]...)
+	cmd.Stderr = m.Stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	m.proc = &externalProcess{cmd: cmd, stdin: stdin, stdout: bufio.NewReader(stdout)}
+	return nil
+}
+
+// stop kills the process, which also unblocks any pending read.
+func (m *ExternalModule) stop() {
+	if m.proc == nil {
+		return
+	}
+	_ = m.proc.stdin.Close()
+	_ = m.proc.cmd.Process.Kill()
+	_ = m.proc.cmd.Wait()
+	m.proc = nil
+}
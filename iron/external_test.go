@@ -0,0 +1,130 @@
+package iron
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestExternalModuleHelper is the subprocess for the ExternalModule tests.
+// It upper-cases inputs that start with "shout", hangs on "hang", and exits
+// on "crash".
+func TestExternalModuleHelper(t *testing.T) {
+	if os.Getenv("IRON_EXTERNAL_HELPER") != "1" {
+		t.Skip("helper process")
+	}
+	scanner := bufio.NewScanner(os.Stdin)
+	out := json.NewEncoder(os.Stdout)
+	for scanner.Scan() {
+		var req externalRequest
+		if err := json.Unmarshal(scanner.Bytes(), &req); err != nil {
+			os.Exit(2)
+		}
+		resp := externalResponse{ID: req.ID}
+		switch {
+		case req.Input == "crash":
+			os.Exit(1)
+		case req.Input == "hang":
+			time.Sleep(time.Minute)
+		case req.Op == "detect":
+			resp.Detect = strings.HasPrefix(req.Input, "shout")
+		case req.Op == "score":
+			resp.Score = 0.95
+		case req.Op == "encode":
+			resp.Output = "@UP " + strings.ToUpper(req.Input)
+			if req.Level != "" {
+				resp.Output = "@UP[" + req.Level + "] " + strings.ToUpper(req.Input)
+			}
+			resp.Losses = []externalLoss{{Kind: "case", Count: 1, Detail: "lower case dropped"}}
+		case req.Op == "decode" && strings.HasPrefix(req.Input, "@UP"):
+			_, body, _ := strings.Cut(req.Input, " ")
+			resp.Output = strings.ToLower(body)
+		default:
+			resp.Error = fmt.Sprintf("cannot %s", req.Op)
+		}
+		_ = out.Encode(resp)
+	}
+	os.Exit(0)
+}
+
+func helperModule(t *testing.T) *ExternalModule {
+	t.Helper()
+	t.Setenv("IRON_EXTERNAL_HELPER", "1")
+	m := &ExternalModule{
+		ModuleName: "IR-UP",
+		Command:    []string{os.Args[0], "-test.run=^TestExternalModuleHelper$"},
+		Timeout:    2 * time.Second,
+	}
+	t.Cleanup(func() { _ = m.Close() })
+	return m
+}
+
+func TestExternalModule_Engine(t *testing.T) {
+	m := helperModule(t)
+	engine := New(WithModule(m))
+	result, err := engine.ProcessContext(context.Background(), "shout hello", AtLevel(LevelBalanced))
+	if err != nil {
+		t.Fatalf("ProcessContext() error = %v", err)
+	}
+	if result.Module != "IR-UP" || result.IR != "@UP[balanced] SHOUT HELLO" || result.Output != "shout hello" {
+		t.Fatalf("ProcessContext() = %+v", result)
+	}
+	if len(result.Losses) != 1 || result.Losses[0].Kind != "case" || result.Score != 0.95 {
+		t.Fatalf("ProcessContext() losses = %+v, score = %v", result.Losses, result.Score)
+	}
+	if m.Detect("quiet") {
+		t.Fatal("Detect() = true, want false")
+	}
+	if _, err := m.Decode("plain"); !errors.Is(err, ErrExternalModule) {
+		t.Fatalf("Decode() error = %v, want ErrExternalModule", err)
+	}
+}
+
+func TestExternalModule_Timeout(t *testing.T) {
+	m := helperModule(t)
+	m.Timeout = 200 * time.Millisecond
+	if _, err := m.Encode("hang"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Encode() error = %v, want deadline exceeded", err)
+	}
+	if got, err := m.Encode("ok"); err != nil || got != "@UP OK" {
+		t.Fatalf("Encode() after timeout = %q, %v", got, err)
+	}
+}
+
+func TestExternalModule_RestartsAfterCrash(t *testing.T) {
+	m := helperModule(t)
+	if _, err := m.Encode("first"); err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+	if _, err := m.Encode("crash"); err == nil {
+		t.Fatal("Encode() error = nil after crash")
+	}
+	if m.proc != nil {
+		t.Fatal("crashed process was not cleared")
+	}
+	if got, err := m.Encode("again"); err != nil || got != "@UP AGAIN" {
+		t.Fatalf("Encode() after crash = %q, %v", got, err)
+	}
+}
+
+func TestExternalModule_DetectUsesCallerContext(t *testing.T) {
+	m := helperModule(t)
+	m.Timeout = 0
+	engine := New(WithModule(m))
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, _ = engine.ProcessContext(ctx, "hang")
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("ProcessContext() returned after %v, want the caller's deadline", elapsed)
+	}
+	if m.DetectContext(ctx, "shout again") {
+		t.Fatal("DetectContext() = true after the deadline")
+	}
+}
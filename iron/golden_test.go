@@ -0,0 +1,109 @@
+package iron
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+var updateGolden = flag.Bool("update", false, "rewrite golden files under testdata")
+
+// goldenFile is a txtar-like fixture made of "-- name --" sections.
+type goldenFile struct {
+	path     string
+	sections map[string]string
+	order    []string
+}
+
+func loadGoldenDir(t *testing.T, dir string) []*goldenFile {
+	t.Helper()
+	paths, err := filepath.Glob(filepath.Join("testdata", dir, "*.txt"))
+	if err != nil {
+		t.Fatalf("glob %s: %v", dir, err)
+	}
+	if len(paths) == 0 {
+		t.Fatalf("no golden files in testdata/%s", dir)
+	}
+	sort.Strings(paths)
+	files := make([]*goldenFile, 0, len(paths))
+	for _, path := range paths {
+		files = append(files, loadGolden(t, path))
+	}
+	return files
+}
+
+func loadGolden(t *testing.T, path string) *goldenFile {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read %s: %v", path, err)
+	}
+	g := &goldenFile{path: path, sections: map[string]string{}}
+	var (
+		name string
+		body []string
+	)
+	flush := func() {
+		if name != "" {
+			g.sections[name] = strings.Join(body, "\n")
+			g.order = append(g.order, name)
+		}
+	}
+	for _, line := range strings.Split(strings.TrimSuffix(string(data), "\n"), "\n") {
+		if strings.HasPrefix(line, "-- ") && strings.HasSuffix(line, " --") {
+			flush()
+			name = strings.TrimSuffix(strings.TrimPrefix(line, "-- "), " --")
+			body = nil
+			continue
+		}
+		body = append(body, line)
+	}
+	flush()
+	return g
+}
+
+func (g *goldenFile) name() string {
+	return strings.TrimSuffix(filepath.Base(g.path), ".txt")
+}
+
+func (g *goldenFile) get(section string) string {
+	return g.sections[section]
+}
+
+// check compares got with the named section, rewriting it under -update.
+func (g *goldenFile) check(t *testing.T, section, got string) {
+	t.Helper()
+	if *updateGolden {
+		if _, ok := g.sections[section]; !ok {
+			g.order = append(g.order, section)
+		}
+		g.sections[section] = got
+		return
+	}
+	want, ok := g.sections[section]
+	if !ok {
+		t.Fatalf("%s: missing section %q (run with -update)", g.path, section)
+	}
+	if got != want {
+		t.Fatalf("%s: %s mismatch\ngot:\n%s\nwant:\n%s", g.path, section, got, want)
+	}
+}
+
+func (g *goldenFile) save(t *testing.T) {
+	t.Helper()
+	if !*updateGolden {
+		return
+	}
+	var sb strings.Builder
+	for _, name := range g.order {
+		sb.WriteString("-- " + name + " --\n")
+		sb.WriteString(g.sections[name])
+		sb.WriteString("\n")
+	}
+	if err := os.WriteFile(g.path, []byte(sb.String()), 0o644); err != nil {
+		t.Fatalf("write %s: %v", g.path, err)
+	}
+}
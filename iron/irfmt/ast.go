@@ -0,0 +1,125 @@
+package irfmt
+
+// Node is a top-level line of a Document: a *Directive or a *Text.
+type Node interface {
+	Pos() Pos
+	node()
+}
+
+// Term is an element of a Chain: an *Atom, a nested *Directive, or a *Block.
+type Term interface {
+	Pos() Pos
+	term()
+}
+
+// Document is a parsed IR text.
+type Document struct {
+	Nodes []Node
+}
+
+// Text is a free-form line. Value must not contain a newline.
+type Text struct {
+	At    Pos
+	Value string
+}
+
+// Directive is "@NAME[args]{block}", optionally followed on the same line by
+// Text when it appears at the top level.
+type Directive struct {
+	At    Pos
+	Name  string
+	Args  []Arg
+	Block *Block
+	Text  string
+}
+
+// Arg is a positional (empty Key) or keyed directive argument.
+type Arg struct {
+	At    Pos
+	Key   string
+	Value string
+}
+
+// Block is a brace-delimited list of chains. Sep is ',' or '|'; zero prints
+// as ','.
+type Block struct {
+	At    Pos
+	Sep   byte
+	Items []Chain
+}
+
+// Chain is a sequence of terms joined by "->". An empty Chain is an empty
+// list item.
+type Chain struct {
+	Terms []Term
+}
+
+// Atom is a scalar value; the printer quotes it when needed.
+type Atom struct {
+	At    Pos
+	Value string
+}
+
+func (t *Text) Pos() Pos      { return t.At }
+func (d *Directive) Pos() Pos { return d.At }
+func (b *Block) Pos() Pos     { return b.At }
+func (a *Atom) Pos() Pos      { return a.At }
+
+func (*Text) node()      {}
+func (*Directive) node() {}
+func (*Directive) term() {}
+func (*Block) term()     {}
+func (*Atom) term()      {}
+
+// Directive returns the first top-level directive with the given name.
+func (d *Document) Directive(name string) *Directive {
+	for _, node := range d.Nodes {
+		if directive, ok := node.(*Directive); ok && directive.Name == name {
+			return directive
+		}
+	}
+	return nil
+}
+
+// Arg returns the value of the keyed argument.
+func (d *Directive) Arg(key string) (string, bool) {
+	for _, arg := range d.Args {
+		if arg.Key == key {
+			return arg.Value, true
+		}
+	}
+	return "", false
+}
+
+// Positional returns the values of the arguments without a key.
+func (d *Directive) Positional() []string {
+	var values []string
+	for _, arg := range d.Args {
+		if arg.Key == "" {
+			values = append(values, arg.Value)
+		}
+	}
+	return values
+}
+
+// Atoms returns the atom values of the chain, or false if it holds other terms.
+func (c Chain) Atoms() ([]string, bool) {
+	values := make([]string, 0, len(c.Terms))
+	for _, term := range c.Terms {
+		atom, ok := term.(*Atom)
+		if !ok {
+			return nil, false
+		}
+		values = append(values, atom.Value)
+	}
+	return values, true
+}
+
+// NewChain builds a chain of atoms.
+func NewChain(values ...string) Chain {
+	chain := Chain{Terms: make([]Term, len(values))}
+	for i, value := range values {
+		chain.Terms[i] = &Atom{Value: value}
+	}
+	return chain
+}
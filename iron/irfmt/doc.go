@@ -0,0 +1,32 @@
+// Package irfmt parses and prints the line-oriented IR text format of the
+// iron directives, for example:
+//
+//	@TASK[tech] ANALYZE repo|SUM|SUGG improvements
+//	@CODE[go,lossless]
+//
+// IR-TASK is written and read entirely with this package, and IR-CODE and
+// IR-LOG use it for their header line; the other modules' IR has a line
+// syntax of its own.
+//
+// The grammar, in EBNF:
+//
+//	Document  = [ Line { "\n" Line } [ "\n" ] ] .
+//	Line      = Directive [ " " Text ] | Text .
+//	Directive = "@" Name [ Args ] [ Block ] .
+//	Name      = upper { upper | digit | "_" | "-" } .
+//	Args      = "[" [ Arg { "," Arg } ] "]" .
+//	Arg       = [ Key "=" ] Value .
+//	Key       = letter { letter | digit | "_" | "." | "-" } .
+//	Block     = "{" [ Chain { Sep Chain } ] "}" .
+//	Sep       = "," | "|" .
+//	Chain     = [ Term { "->" Term } ] .
+//	Term      = Value | Directive | Block .
+//	Value     = Atom | String .
+//
+// An Atom is any run of characters up to the next delimiter, with surrounding
+// blanks trimmed; inside Args the delimiters are []{},=" and inside Blocks
+// they are []{},|"@ and "->". A String is a double-quoted Go string literal.
+// A Block uses a single separator throughout. Newlines inside Args and Blocks
+// are whitespace. A Text line that begins with "@" or "\" is escaped with a
+// leading "\".
+package irfmt
@@ -0,0 +1,224 @@
+package irfmt
+
+import (
+	"fmt"
+	"regexp"
+)
+
+var keyRe = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_.-]*$`)
+
+// Parse parses IR source into a Document. Errors are *Error values carrying
+// the position of the offending token.
+func Parse(src string) (*Document, error) {
+	p := &parser{lexer: NewLexer(src)}
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+	doc := &Document{}
+	for p.tok.Kind != EOF {
+		var node Node
+		switch p.tok.Kind {
+		case TextLine:
+			node = &Text{At: p.tok.Pos, Value: p.tok.Value}
+			if err := p.next(); err != nil {
+				return nil, err
+			}
+		case DirectiveName:
+			directive, err := p.directive(true)
+			if err != nil {
+				return nil, err
+			}
+			node = directive
+		default:
+			return nil, p.unexpected("line")
+		}
+		doc.Nodes = append(doc.Nodes, node)
+
+		switch p.tok.Kind {
+		case Newline:
+			if err := p.next(); err != nil {
+				return nil, err
+			}
+		case EOF:
+		default:
+			return nil, p.unexpected("end of line")
+		}
+	}
+	return doc, nil
+}
+
+type parser struct {
+	lexer *Lexer
+	tok   Token
+}
+
+func (p *parser) next() error {
+	tok, err := p.lexer.Next()
+	if err != nil {
+		return err
+	}
+	p.tok = tok
+	return nil
+}
+
+func (p *parser) errorf(pos Pos, format string, args ...any) error {
+	return &Error{Pos: pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+func (p *parser) unexpected(want string) error {
+	return p.errorf(p.tok.Pos, "unexpected %s, expected %s", p.tok.Kind, want)
+}
+
+func (p *parser) directive(top bool) (*Directive, error) {
+	directive := &Directive{At: p.tok.Pos, Name: p.tok.Value}
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+	if p.tok.Kind == LBrack {
+		args, err := p.args()
+		if err != nil {
+			return nil, err
+		}
+		directive.Args = args
+	}
+	if p.tok.Kind == LBrace {
+		block, err := p.block()
+		if err != nil {
+			return nil, err
+		}
+		directive.Block = block
+	}
+	if top && p.tok.Kind == TextLine {
+		directive.Text = p.tok.Value
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+	}
+	return directive, nil
+}
+
+func (p *parser) args() ([]Arg, error) {
+	open := p.tok.Pos
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+	var args []Arg
+	if p.tok.Kind == RBrack {
+		return args, p.next()
+	}
+	for {
+		arg := Arg{At: p.tok.Pos}
+		switch p.tok.Kind {
+		case AtomValue, StringValue:
+			arg.Value = p.tok.Value
+		case EOF:
+			return nil, p.errorf(open, "unclosed \"[\"")
+		default:
+			return nil, p.unexpected("argument")
+		}
+		keyKind := p.tok.Kind
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		if p.tok.Kind == Equals {
+			if keyKind != AtomValue || !keyRe.MatchString(arg.Value) {
+				return nil, p.errorf(arg.At, "invalid argument key %q", arg.Value)
+			}
+			arg.Key, arg.Value = arg.Value, ""
+			if err := p.next(); err != nil {
+				return nil, err
+			}
+			if p.tok.Kind == AtomValue || p.tok.Kind == StringValue {
+				arg.Value = p.tok.Value
+				if err := p.next(); err != nil {
+					return nil, err
+				}
+			}
+		}
+		args = append(args, arg)
+
+		switch p.tok.Kind {
+		case Comma:
+			if err := p.next(); err != nil {
+				return nil, err
+			}
+		case RBrack:
+			return args, p.next()
+		case EOF:
+			return nil, p.errorf(open, "unclosed \"[\"")
+		default:
+			return nil, p.unexpected(`"," or "]"`)
+		}
+	}
+}
+
+func (p *parser) block() (*Block, error) {
+	block := &Block{At: p.tok.Pos}
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+	if p.tok.Kind == RBrace {
+		return block, p.next()
+	}
+	for {
+		chain, err := p.chain(block.At)
+		if err != nil {
+			return nil, err
+		}
+		block.Items = append(block.Items, chain)
+
+		switch p.tok.Kind {
+		case Comma, Pipe:
+			sep := p.tok.Value[0]
+			if block.Sep != 0 && block.Sep != sep {
+				return nil, p.errorf(p.tok.Pos, "mixed separators %q and %q in block", block.Sep, sep)
+			}
+			block.Sep = sep
+			if err := p.next(); err != nil {
+				return nil, err
+			}
+		case RBrace:
+			return block, p.next()
+		case EOF:
+			return nil, p.errorf(block.At, "unclosed \"{\"")
+		default:
+			return nil, p.unexpected(`separator or "}"`)
+		}
+	}
+}
+
+func (p *parser) chain(open Pos) (Chain, error) {
+	var chain Chain
+	switch p.tok.Kind {
+	case Comma, Pipe, RBrace:
+		return chain, nil
+	}
+	for {
+		term, err := p.term(open)
+		if err != nil {
+			return Chain{}, err
+		}
+		chain.Terms = append(chain.Terms, term)
+		if p.tok.Kind != Arrow {
+			return chain, nil
+		}
+		if err := p.next(); err != nil {
+			return Chain{}, err
+		}
+	}
+}
+
+func (p *parser) term(open Pos) (Term, error) {
+	switch p.tok.Kind {
+	case AtomValue, StringValue:
+		atom := &Atom{At: p.tok.Pos, Value: p.tok.Value}
+		return atom, p.next()
+	case DirectiveName:
+		return p.directive(false)
+	case LBrace:
+		return p.block()
+	case EOF:
+		return nil, p.errorf(open, "unclosed \"{\"")
+	}
+	return nil, p.unexpected("term")
+}
@@ -0,0 +1,95 @@
+package irfmt
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParse_TaskIR(t *testing.T) {
+	doc, err := Parse("@CTX[min]\n@TASK{ANALYZE->SUM->SUGG}\n@OBJ{repo||readme}\n@OUT{list,json}")
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if len(doc.Nodes) != 4 {
+		t.Fatalf("len(Nodes) = %d, want 4", len(doc.Nodes))
+	}
+	if got := doc.Directive("CTX").Positional(); len(got) != 1 || got[0] != "min" {
+		t.Fatalf("CTX args = %q, want [min]", got)
+	}
+
+	task := doc.Directive("TASK")
+	if len(task.Block.Items) != 1 {
+		t.Fatalf("TASK items = %d, want 1", len(task.Block.Items))
+	}
+	steps, ok := task.Block.Items[0].Atoms()
+	if !ok || len(steps) != 3 || steps[0] != "ANALYZE" || steps[2] != "SUGG" {
+		t.Fatalf("TASK chain = %q, want ANALYZE->SUM->SUGG", steps)
+	}
+
+	obj := doc.Directive("OBJ").Block
+	if obj.Sep != '|' || len(obj.Items) != 3 || len(obj.Items[1].Terms) != 0 {
+		t.Fatalf("OBJ block = %+v, want three |-separated items with an empty middle", obj)
+	}
+	if out := doc.Directive("OUT").Block; out.Sep != ',' || len(out.Items) != 2 {
+		t.Fatalf("OUT block = %+v, want two ,-separated items", out)
+	}
+}
+
+func TestParse_NestedAndText(t *testing.T) {
+	doc, err := Parse("@PIPE{@SH[cmd=\"df -h\"]->@LLM{summ,{a|b}}->send} trailing text\n\\@literal\nplain")
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	pipe := doc.Nodes[0].(*Directive)
+	if pipe.Text != "trailing text" {
+		t.Fatalf("Text = %q, want trailing text", pipe.Text)
+	}
+	terms := pipe.Block.Items[0].Terms
+	if len(terms) != 3 {
+		t.Fatalf("len(terms) = %d, want 3", len(terms))
+	}
+	sh := terms[0].(*Directive)
+	if cmd, _ := sh.Arg("cmd"); cmd != "df -h" {
+		t.Fatalf("SH cmd = %q, want df -h", cmd)
+	}
+	llm := terms[1].(*Directive)
+	if inner, ok := llm.Block.Items[1].Terms[0].(*Block); !ok || inner.Sep != '|' || len(inner.Items) != 2 {
+		t.Fatalf("LLM nested block = %+v", llm.Block.Items[1].Terms[0])
+	}
+	if text := doc.Nodes[1].(*Text); text.Value != "@literal" {
+		t.Fatalf("escaped text = %q, want @literal", text.Value)
+	}
+}
+
+func TestParse_MultiLineBlock(t *testing.T) {
+	doc, err := Parse("@PLAN{\n  fetch ->\n  parse,\n  store\n}\n@END")
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if got := Print(doc); got != "@PLAN{fetch->parse,store}\n@END" {
+		t.Fatalf("Print() = %q", got)
+	}
+}
+
+func TestParse_ErrorPositions(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{src: "@A{x", want: `irfmt: 1:3: unclosed "{"`},
+		{src: "@A[x", want: `irfmt: 1:3: unclosed "["`},
+		{src: "@A[,]", want: `irfmt: 1:4: unexpected ",", expected argument`},
+		{src: "@A[bad key=1]", want: `irfmt: 1:4: invalid argument key "bad key"`},
+		{src: "ok\n@A{a,b|c}", want: `irfmt: 2:7: mixed separators ',' and '|' in block`},
+		{src: "@A{a->}", want: `irfmt: 1:7: unexpected "}", expected term`},
+		{src: "@A{@B c}", want: `irfmt: 1:7: unexpected atom, expected separator or "}"`},
+		{src: "@A{x}[y]", want: `irfmt: 1:6: unexpected "[", expected end of line`},
+	}
+	for _, tt := range tests {
+		_, err := Parse(tt.src)
+		var syntaxErr *Error
+		if !errors.As(err, &syntaxErr) || err.Error() != tt.want {
+			t.Fatalf("Parse(%q) error = %v, want %s", tt.src, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package irfmt
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Print renders the document in canonical form: no optional whitespace,
+// atoms quoted only when necessary, and lines joined by "\n".
+func Print(doc *Document) string {
+	var b strings.Builder
+	for i, node := range doc.Nodes {
+		if i > 0 {
+			b.WriteByte('\n')
+		}
+		switch n := node.(type) {
+		case *Text:
+			if strings.HasPrefix(n.Value, "@") || strings.HasPrefix(n.Value, `\`) {
+				b.WriteByte('\\')
+			}
+			b.WriteString(n.Value)
+		case *Directive:
+			printDirective(&b, n)
+			if n.Text != "" {
+				b.WriteByte(' ')
+				b.WriteString(n.Text)
+			}
+		}
+	}
+	// A trailing empty line needs an explicit terminator to survive parsing.
+	if n := len(doc.Nodes); n > 0 {
+		if text, ok := doc.Nodes[n-1].(*Text); ok && text.Value == "" {
+			b.WriteByte('\n')
+		}
+	}
+	return b.String()
+}
+
+// Format parses src and prints it canonically.
+func Format(src string) (string, error) {
+	doc, err := Parse(src)
+	if err != nil {
+		return "", err
+	}
+	return Print(doc), nil
+}
+
+func (d *Document) String() string {
+	return Print(d)
+}
+
+func printDirective(b *strings.Builder, d *Directive) {
+	b.WriteByte('@')
+	b.WriteString(d.Name)
+	if d.Args != nil {
+		b.WriteByte('[')
+		for i, arg := range d.Args {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			if arg.Key != "" {
+				b.WriteString(arg.Key)
+				b.WriteByte('=')
+			}
+			writeValue(b, arg.Value, argDelims, arg.Key == "")
+		}
+		b.WriteByte(']')
+	}
+	if d.Block != nil {
+		printBlock(b, d.Block)
+	}
+}
+
+func printBlock(b *strings.Builder, block *Block) {
+	sep := block.Sep
+	if sep == 0 {
+		sep = ','
+	}
+	b.WriteByte('{')
+	for i, chain := range block.Items {
+		if i > 0 {
+			b.WriteByte(sep)
+		}
+		for j, term := range chain.Terms {
+			if j > 0 {
+				b.WriteString("->")
+			}
+			switch t := term.(type) {
+			case *Atom:
+				writeValue(b, t.Value, blockDelims, true)
+			case *Directive:
+				printDirective(b, t)
+			case *Block:
+				printBlock(b, t)
+			}
+		}
+	}
+	b.WriteByte('}')
+}
+
+const (
+	argDelims   = `[]{},="`
+	blockDelims = `[]{},|"@`
+)
+
+// writeValue writes an atom, quoting it when it would not lex back unchanged.
+// Empty values are quoted unless the context allows an empty atom.
+func writeValue(b *strings.Builder, value, delims string, quoteEmpty bool) {
+	if needsQuote(value, delims, quoteEmpty) {
+		b.WriteString(strconv.Quote(value))
+		return
+	}
+	b.WriteString(value)
+}
+
+func needsQuote(value, delims string, quoteEmpty bool) bool {
+	if value == "" {
+		return quoteEmpty
+	}
+	if strings.TrimSpace(value) != value || strings.ContainsAny(value, delims) {
+		return true
+	}
+	if delims == blockDelims && strings.Contains(value, "->") {
+		return true
+	}
+	for _, r := range value {
+		if r == unicode.ReplacementChar || !unicode.IsPrint(r) && r != ' ' {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,124 @@
+package irfmt
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestPrint_Canonical(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{src: "@TASK{ ANALYZE -> SUM }", want: "@TASK{ANALYZE->SUM}"},
+		{src: "@A[ x , k = \"v\" ]", want: "@A[x,k=v]"},
+		{src: "@A[]{}", want: "@A{}"},
+		{src: `@A{"a,b"|"x->y"|"@me"|" pad"|""}`, want: `@A{"a,b"|"x->y"|"@me"|" pad"|""}`},
+		{src: `@A[k="a=b",""]`, want: `@A[k="a=b",""]`},
+		{src: "\\\\x\n\n", want: "\\\\x\n\n"},
+		{src: "@T0  two spaces", want: "@T0  two spaces"},
+		{src: "text\n", want: "text"},
+	}
+	for _, tt := range tests {
+		got, err := Format(tt.src)
+		if err != nil {
+			t.Fatalf("Format(%q) error = %v", tt.src, err)
+		}
+		if got != tt.want {
+			t.Fatalf("Format(%q) = %q, want %q", tt.src, got, tt.want)
+		}
+	}
+}
+
+func TestPrint_BuiltDocument(t *testing.T) {
+	doc := &Document{Nodes: []Node{
+		&Directive{Name: "CTX", Args: []Arg{{Value: "pt"}}},
+		&Directive{Name: "TASK", Block: &Block{Items: []Chain{NewChain("SEARCH", "EXTRACT")}}},
+		&Directive{Name: "OBJ", Block: &Block{Sep: '|', Items: []Chain{NewChain("a|b"), {}, NewChain("c")}}},
+		&Text{Value: "@not a directive"},
+	}}
+	want := "@CTX[pt]\n@TASK{SEARCH->EXTRACT}\n@OBJ{\"a|b\"||c}\n\\@not a directive"
+	if got := Print(doc); got != want {
+		t.Fatalf("Print() = %q, want %q", got, want)
+	}
+	if _, err := Parse(want); err != nil {
+		t.Fatalf("Parse(Print()) error = %v", err)
+	}
+}
+
+func FuzzPrintParse(f *testing.F) {
+	for _, seed := range []string{
+		"@CTX[min]\n@TASK{ANALYZE->SUM->SUGG}\n@OBJ{repo}\n@OUT{tech}",
+		"@OBJ{a||b}\n@OUT{list,json}",
+		"@PIPE{@SH[cmd=\"df -h\"]->@LLM{summ}->send} note",
+		"plain\n\\@escaped\n\n",
+		"@A[k=,x]{{a|b},\"\"}",
+		"@A{\n x ->\n y\n}",
+	} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, src string) {
+		doc, err := Parse(src)
+		if err != nil {
+			return
+		}
+		printed := Print(doc)
+		reparsed, err := Parse(printed)
+		if err != nil {
+			t.Fatalf("Parse(Print(%q)) = %q: %v", src, printed, err)
+		}
+		if a, b := dump(doc), dump(reparsed); a != b {
+			t.Fatalf("round trip changed %q:\nbefore: %s\nafter:  %s", src, a, b)
+		}
+		if again := Print(reparsed); again != printed {
+			t.Fatalf("Print not stable for %q:\nfirst:  %q\nsecond: %q", src, printed, again)
+		}
+	})
+}
+
+// dump renders a document's structure without positions.
+func dump(doc *Document) string {
+	var b strings.Builder
+	for _, node := range doc.Nodes {
+		switch n := node.(type) {
+		case *Text:
+			fmt.Fprintf(&b, "text(%q)", n.Value)
+		case *Directive:
+			dumpDirective(&b, n)
+			fmt.Fprintf(&b, "+%q", n.Text)
+		}
+		b.WriteByte(';')
+	}
+	return b.String()
+}
+
+func dumpDirective(b *strings.Builder, d *Directive) {
+	fmt.Fprintf(b, "@%s", d.Name)
+	for _, arg := range d.Args {
+		fmt.Fprintf(b, "[%q=%q]", arg.Key, arg.Value)
+	}
+	if d.Block != nil {
+		dumpBlock(b, d.Block)
+	}
+}
+
+func dumpBlock(b *strings.Builder, block *Block) {
+	fmt.Fprintf(b, "{%q", block.Sep)
+	for _, chain := range block.Items {
+		b.WriteString("(")
+		for _, term := range chain.Terms {
+			switch t := term.(type) {
+			case *Atom:
+				fmt.Fprintf(b, "%q", t.Value)
+			case *Directive:
+				dumpDirective(b, t)
+			case *Block:
+				dumpBlock(b, t)
+			}
+			b.WriteString(">")
+		}
+		b.WriteString(")")
+	}
+	b.WriteString("}")
+}
@@ -0,0 +1,302 @@
+package irfmt
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Kind identifies a lexical token.
+type Kind int
+
+const (
+	EOF Kind = iota
+	Newline
+	TextLine
+	DirectiveName
+	AtomValue
+	StringValue
+	LBrack
+	RBrack
+	LBrace
+	RBrace
+	Comma
+	Pipe
+	Equals
+	Arrow
+)
+
+var kindNames = [...]string{
+	EOF:           "end of input",
+	Newline:       "newline",
+	TextLine:      "text",
+	DirectiveName: "directive",
+	AtomValue:     "atom",
+	StringValue:   "string",
+	LBrack:        `"["`,
+	RBrack:        `"]"`,
+	LBrace:        `"{"`,
+	RBrace:        `"}"`,
+	Comma:         `","`,
+	Pipe:          `"|"`,
+	Equals:        `"="`,
+	Arrow:         `"->"`,
+}
+
+func (k Kind) String() string {
+	if k < 0 || int(k) >= len(kindNames) {
+		return "unknown"
+	}
+	return kindNames[k]
+}
+
+// Pos is a position in the source. Line and Column are 1-based; Column
+// counts bytes.
+type Pos struct {
+	Offset int
+	Line   int
+	Column int
+}
+
+func (p Pos) String() string {
+	return fmt.Sprintf("%d:%d", p.Line, p.Column)
+}
+
+// Error is a syntax error at a source position.
+type Error struct {
+	Pos Pos
+	Msg string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("irfmt: %s: %s", e.Pos, e.Msg)
+}
+
+// Token is a lexical token. Value holds the directive name, the trimmed atom,
+// the unquoted string, or the unescaped text.
+type Token struct {
+	Kind  Kind
+	Value string
+	Pos   Pos
+}
+
+// Lexer splits IR source into tokens. It tracks open brackets so that
+// delimiters and whitespace are interpreted per context.
+type Lexer struct {
+	src       string
+	pos       Pos
+	stack     []byte
+	lineStart bool
+}
+
+// NewLexer creates a Lexer for src.
+func NewLexer(src string) *Lexer {
+	return &Lexer{src: src, pos: Pos{Line: 1, Column: 1}, lineStart: true}
+}
+
+// Tokenize returns all tokens of src up to and including EOF.
+func Tokenize(src string) ([]Token, error) {
+	lexer := NewLexer(src)
+	var tokens []Token
+	for {
+		tok, err := lexer.Next()
+		if err != nil {
+			return tokens, err
+		}
+		tokens = append(tokens, tok)
+		if tok.Kind == EOF {
+			return tokens, nil
+		}
+	}
+}
+
+// Next returns the next token.
+func (l *Lexer) Next() (Token, error) {
+	if l.lineStart && len(l.stack) == 0 {
+		if l.eof() {
+			return Token{Kind: EOF, Pos: l.pos}, nil
+		}
+		l.lineStart = false
+		if l.peek() != '@' {
+			return l.text(true), nil
+		}
+		return l.directive()
+	}
+
+	for !l.eof() {
+		switch l.peek() {
+		case ' ', '\t':
+			l.advance(1)
+			if len(l.stack) == 0 {
+				return l.text(false), nil
+			}
+			continue
+		case '\n':
+			if len(l.stack) == 0 {
+				tok := Token{Kind: Newline, Pos: l.pos}
+				l.advance(1)
+				l.lineStart = true
+				return tok, nil
+			}
+			l.advance(1)
+			continue
+		}
+		break
+	}
+	if l.eof() {
+		return Token{Kind: EOF, Pos: l.pos}, nil
+	}
+
+	pos, c := l.pos, l.peek()
+	switch c {
+	case '[':
+		return l.open(LBrack), nil
+	case '{':
+		return l.open(LBrace), nil
+	case ']':
+		return l.close(RBrack, '[')
+	case '}':
+		return l.close(RBrace, '{')
+	}
+	if len(l.stack) == 0 {
+		return Token{}, l.errorf(pos, "unexpected %q after directive; trailing text must follow a space", c)
+	}
+
+	top := l.stack[len(l.stack)-1]
+	switch {
+	case c == ',':
+		l.advance(1)
+		return Token{Kind: Comma, Value: ",", Pos: pos}, nil
+	case c == '"':
+		return l.str()
+	case top == '[' && c == '=':
+		l.advance(1)
+		return Token{Kind: Equals, Value: "=", Pos: pos}, nil
+	case top == '{' && c == '|':
+		l.advance(1)
+		return Token{Kind: Pipe, Value: "|", Pos: pos}, nil
+	case top == '{' && c == '@':
+		return l.directive()
+	case top == '{' && strings.HasPrefix(l.src[l.pos.Offset:], "->"):
+		l.advance(2)
+		return Token{Kind: Arrow, Value: "->", Pos: pos}, nil
+	}
+	return l.atom(top), nil
+}
+
+func (l *Lexer) open(kind Kind) Token {
+	tok := Token{Kind: kind, Value: string(l.peek()), Pos: l.pos}
+	l.stack = append(l.stack, l.peek())
+	l.advance(1)
+	return tok
+}
+
+func (l *Lexer) close(kind Kind, open byte) (Token, error) {
+	tok := Token{Kind: kind, Value: string(l.peek()), Pos: l.pos}
+	if len(l.stack) == 0 || l.stack[len(l.stack)-1] != open {
+		return Token{}, l.errorf(tok.Pos, "unexpected %q", tok.Value)
+	}
+	l.stack = l.stack[:len(l.stack)-1]
+	l.advance(1)
+	return tok, nil
+}
+
+func (l *Lexer) eof() bool {
+	return l.pos.Offset >= len(l.src)
+}
+
+func (l *Lexer) peek() byte {
+	return l.src[l.pos.Offset]
+}
+
+func (l *Lexer) advance(n int) {
+	for i := 0; i < n && !l.eof(); i++ {
+		if l.peek() == '\n' {
+			l.pos.Line++
+			l.pos.Column = 1
+		} else {
+			l.pos.Column++
+		}
+		l.pos.Offset++
+	}
+}
+
+func (l *Lexer) errorf(pos Pos, format string, args ...any) error {
+	return &Error{Pos: pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+// text reads the rest of the line. Whole lines drop one escaping backslash.
+func (l *Lexer) text(line bool) Token {
+	pos := l.pos
+	end := strings.IndexByte(l.src[pos.Offset:], '\n')
+	if end < 0 {
+		end = len(l.src) - pos.Offset
+	}
+	value := l.src[pos.Offset : pos.Offset+end]
+	l.advance(end)
+	if line && strings.HasPrefix(value, `\`) {
+		value = value[1:]
+	}
+	return Token{Kind: TextLine, Value: value, Pos: pos}
+}
+
+func (l *Lexer) directive() (Token, error) {
+	pos := l.pos
+	l.advance(1)
+	start := l.pos.Offset
+	for !l.eof() {
+		c := l.peek()
+		upper := c >= 'A' && c <= 'Z'
+		if l.pos.Offset == start && !upper {
+			break
+		}
+		if !upper && !(c >= '0' && c <= '9') && c != '_' && (c != '-' || strings.HasPrefix(l.src[l.pos.Offset:], "->")) {
+			break
+		}
+		l.advance(1)
+	}
+	if l.pos.Offset == start {
+		return Token{}, l.errorf(pos, "expected directive name after \"@\"")
+	}
+	return Token{Kind: DirectiveName, Value: l.src[start:l.pos.Offset], Pos: pos}, nil
+}
+
+func (l *Lexer) str() (Token, error) {
+	pos := l.pos
+	rest := l.src[pos.Offset:]
+	for i := 1; i < len(rest); i++ {
+		switch rest[i] {
+		case '\\':
+			i++
+		case '\n':
+			return Token{}, l.errorf(pos, "unterminated string")
+		case '"':
+			value, err := strconv.Unquote(rest[:i+1])
+			if err != nil {
+				return Token{}, l.errorf(pos, "invalid string literal %s", rest[:i+1])
+			}
+			l.advance(i + 1)
+			return Token{Kind: StringValue, Value: value, Pos: pos}, nil
+		}
+	}
+	return Token{}, l.errorf(pos, "unterminated string")
+}
+
+// atom reads up to the next delimiter of the enclosing bracket.
+func (l *Lexer) atom(top byte) Token {
+	pos := l.pos
+	delims := `[]{},="` + "\n"
+	if top == '{' {
+		delims = `[]{},|"@` + "\n"
+	}
+	start := pos.Offset
+	end := start
+	for end < len(l.src) && strings.IndexByte(delims, l.src[end]) < 0 {
+		if top == '{' && strings.HasPrefix(l.src[end:], "->") {
+			break
+		}
+		end++
+	}
+	l.advance(end - start)
+	return Token{Kind: AtomValue, Value: strings.TrimRight(l.src[start:end], " \t"), Pos: pos}
+}
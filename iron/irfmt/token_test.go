@@ -0,0 +1,65 @@
+package irfmt
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTokenize_Kinds(t *testing.T) {
+	tokens, err := Tokenize("@TASK[pt,k=v]{A->B|\"c d\"} tail\ntext")
+	if err != nil {
+		t.Fatalf("Tokenize() error = %v", err)
+	}
+	var kinds []Kind
+	var values []string
+	for _, tok := range tokens {
+		kinds = append(kinds, tok.Kind)
+		values = append(values, tok.Value)
+	}
+	wantKinds := []Kind{
+		DirectiveName, LBrack, AtomValue, Comma, AtomValue, Equals, AtomValue, RBrack,
+		LBrace, AtomValue, Arrow, AtomValue, Pipe, StringValue, RBrace, TextLine, Newline, TextLine, EOF,
+	}
+	if !reflect.DeepEqual(kinds, wantKinds) {
+		t.Fatalf("kinds = %v, want %v", kinds, wantKinds)
+	}
+	wantValues := []string{"TASK", "[", "pt", ",", "k", "=", "v", "]", "{", "A", "->", "B", "|", "c d", "}", "tail", "", "text", ""}
+	if !reflect.DeepEqual(values, wantValues) {
+		t.Fatalf("values = %q, want %q", values, wantValues)
+	}
+}
+
+func TestTokenize_Positions(t *testing.T) {
+	tokens, err := Tokenize("@A{\n  x ,y}")
+	if err != nil {
+		t.Fatalf("Tokenize() error = %v", err)
+	}
+	want := map[string]Pos{
+		"x": {Offset: 6, Line: 2, Column: 3},
+		"y": {Offset: 9, Line: 2, Column: 6},
+	}
+	for _, tok := range tokens {
+		if pos, ok := want[tok.Value]; ok && tok.Pos != pos {
+			t.Fatalf("%q at %+v, want %+v", tok.Value, tok.Pos, pos)
+		}
+	}
+}
+
+func TestTokenize_Errors(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{src: "@lower", want: `irfmt: 1:1: expected directive name after "@"`},
+		{src: "@A}", want: `irfmt: 1:3: unexpected "}"`},
+		{src: "@Ax", want: `irfmt: 1:3: unexpected 'x' after directive; trailing text must follow a space`},
+		{src: "ok\n@A{\"open", want: `irfmt: 2:4: unterminated string`},
+		{src: `@A{"\q"}`, want: `irfmt: 1:4: invalid string literal "\q"`},
+	}
+	for _, tt := range tests {
+		_, err := Tokenize(tt.src)
+		if err == nil || err.Error() != tt.want {
+			t.Fatalf("Tokenize(%q) error = %v, want %s", tt.src, err, tt.want)
+		}
+	}
+}
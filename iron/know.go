@@ -0,0 +1,544 @@
+package iron
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// KnowModule compacts the context chunks stuffed into a prompt, such as
+// notes, lists, and fetched documents, for a query.
+//
+// The input is the text FormatChunks produces: an optional "[query: ...]"
+// line followed by chunks, each under a "[source: ...]" line. Chunks are
+// ranked by their BM25 relevance to the query, repeated sentences are
+// dropped from all but the most relevant chunk, and the chunks that do not
+// fit the token budget are cut; no chunk is dropped for relevance alone. At
+// LevelLossless only verbatim repeats are dropped and lines are kept as
+// written; LevelBalanced also drops near-duplicates and collapses runs of
+// whitespace, and LevelAggressive keeps only the sentences of each chunk that
+// mention the query.
+//
+// The IR is a "@KNOW{query}" header followed by the chunks in rank order,
+// each starting with a "[Sn source]" tag that the model cites as "[Sn]";
+// Citations maps those references back to the sources.
+type KnowModule struct {
+	Level Level
+	// Budget is the maximum IR size in tokens when the call sets no
+	// TokenBudget; zero means unlimited.
+	Budget int
+	// Tokenizer counts tokens for the budget; nil means BPETokenizer.
+	Tokenizer Tokenizer
+}
+
+// Chunk is one piece of retrieved context and where it came from.
+type Chunk struct {
+	Source string `json:"source"`
+	Text   string `json:"text"`
+}
+
+// Citation is a "[Sn]" reference in a model answer and the source it
+// stands for.
+type Citation struct {
+	Ref    string `json:"ref"`
+	Source string `json:"source"`
+}
+
+const (
+	knowHeader = "@KNOW"
+	// knowNearDuplicate is the word-set overlap above which two sentences
+	// count as the same.
+	knowNearDuplicate = 0.8
+	// knowNearMinWords keeps short sentences, such as "Yes.", from matching
+	// by overlap alone.
+	knowNearMinWords = 4
+	bm25K1           = 1.2
+	bm25B            = 0.75
+)
+
+var (
+	knowQueryRe    = regexp.MustCompile(`^\[query: ?(.*)\]$`)
+	knowSourceRe   = regexp.MustCompile(`^\[source: ?(.*)\]$`)
+	knowChunkRe    = regexp.MustCompile(`^\[S(\d+)(?: ([^\]]*))?\](?: (.*))?$`)
+	knowCitationRe = regexp.MustCompile(`\[(S\d+(?:\s*[,;]\s*S\d+)*)\]`)
+)
+
+// FormatChunks writes a query and its context chunks in the input format
+// of KnowModule. Chunk lines that could be read as a "[source: ...]" line
+// are escaped with a backslash, which ParseChunks removes.
+func FormatChunks(query string, chunks []Chunk) string {
+	var lines []string
+	if query = strings.TrimSpace(query); query != "" {
+		lines = append(lines, "[query: "+query+"]")
+	}
+	for _, chunk := range chunks {
+		lines = append(lines, "[source: "+strings.TrimSpace(chunk.Source)+"]")
+		for _, line := range strings.Split(strings.TrimSpace(chunk.Text), "\n") {
+			trimmed := strings.TrimLeft(line, " \t")
+			if strings.HasPrefix(trimmed, "[source:") || strings.HasPrefix(trimmed, `\`) {
+				line = line[:len(line)-len(trimmed)] + `\` + trimmed
+			}
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// ParseChunks reads text written by FormatChunks.
+func ParseChunks(input string) (string, []Chunk) {
+	var (
+		query  string
+		chunks []Chunk
+		body   []string
+	)
+	flush := func() {
+		if len(chunks) > 0 {
+			chunks[len(chunks)-1].Text = strings.TrimSpace(strings.Join(body, "\n"))
+		}
+		body = nil
+	}
+	for _, line := range strings.Split(input, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if match := knowQueryRe.FindStringSubmatch(trimmed); match != nil && len(chunks) == 0 {
+			query = strings.TrimSpace(match[1])
+			continue
+		}
+		if match := knowSourceRe.FindStringSubmatch(trimmed); match != nil {
+			flush()
+			chunks = append(chunks, Chunk{Source: strings.TrimSpace(match[1])})
+			continue
+		}
+		if unescaped := strings.TrimLeft(line, " \t"); strings.HasPrefix(unescaped, `\`) {
+			line = line[:len(line)-len(unescaped)] + unescaped[1:]
+		}
+		body = append(body, line)
+	}
+	flush()
+	return query, chunks
+}
+
+func (KnowModule) Name() string {
+	return "IR-KNOW"
+}
+
+// Detect reports whether the input is a query and chunk set written by
+// FormatChunks.
+func (KnowModule) Detect(input string) bool {
+	trimmed := strings.TrimSpace(input)
+	if !strings.HasPrefix(trimmed, "[query:") && !strings.HasPrefix(trimmed, "[source:") {
+		return false
+	}
+	_, chunks := ParseChunks(trimmed)
+	return len(chunks) > 0
+}
+
+// Encode compacts the chunks at the module's level.
+func (m KnowModule) Encode(input string) (string, error) {
+	encoded, _, err := m.EncodeWithLoss(input)
+	return encoded, err
+}
+
+// EncodeWithLoss compacts the chunks and reports what was dropped.
+func (m KnowModule) EncodeWithLoss(input string) (string, []Loss, error) {
+	query, chunks := ParseChunks(input)
+	if len(chunks) == 0 {
+		return "", nil, fmt.Errorf("%w: no context chunks", ErrInvalidIR)
+	}
+	enc := &knowEncoder{level: m.Level, stats: map[string]int{}}
+	ranked := enc.rank(query, chunks)
+	kept := enc.dedupe(query, ranked)
+	header := knowHeader
+	if query != "" {
+		header += "{" + query + "}"
+	}
+	out := enc.fit(header, kept, m.Budget, m.Tokenizer)
+	return out, enc.losses(), nil
+}
+
+// EncodeContext encodes at the level and token budget hinted in ctx, if
+// any.
+func (m KnowModule) EncodeContext(ctx context.Context, input string) (string, []Loss, error) {
+	if err := ctx.Err(); err != nil {
+		return "", nil, err
+	}
+	hints := HintsFromContext(ctx)
+	m.Level = hints.LevelOr(m.Level)
+	if hints.TokenBudget > 0 {
+		m.Budget = hints.TokenBudget
+	}
+	return m.EncodeWithLoss(input)
+}
+
+// DecodeContext decodes unless ctx is done.
+func (m KnowModule) DecodeContext(ctx context.Context, ir string) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	return m.Decode(ir)
+}
+
+// Decode restores the kept chunks, in rank order, in the input format.
+func (KnowModule) Decode(output string) (string, error) {
+	query, chunks, err := parseKnowIR(output)
+	if err != nil {
+		return "", err
+	}
+	return FormatChunks(query, chunks), nil
+}
+
+func (KnowModule) Score() float64 {
+	return 0.9
+}
+
+// Citations returns the sources of the "[Sn]" references in answer, in
+// order of first citation, for chunks encoded as ir. References to unknown
+// chunks are skipped.
+func Citations(ir, answer string) ([]Citation, error) {
+	_, chunks, err := parseKnowIR(ir)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		citations []Citation
+		seen      = map[string]bool{}
+	)
+	for _, match := range knowCitationRe.FindAllStringSubmatch(answer, -1) {
+		for _, ref := range strings.FieldsFunc(match[1], func(r rune) bool { return r == ',' || r == ';' || unicode.IsSpace(r) }) {
+			var n int
+			if _, err := fmt.Sscanf(ref, "S%d", &n); err != nil || n < 1 || n > len(chunks) || seen[ref] {
+				continue
+			}
+			seen[ref] = true
+			citations = append(citations, Citation{Ref: ref, Source: chunks[n-1].Source})
+		}
+	}
+	return citations, nil
+}
+
+// parseKnowIR reads the query and chunks of a KnowModule IR.
+func parseKnowIR(ir string) (string, []Chunk, error) {
+	lines := strings.Split(strings.TrimSpace(ir), "\n")
+	header := strings.TrimSpace(lines[0])
+	if !strings.HasPrefix(header, knowHeader) {
+		return "", nil, fmt.Errorf("%w: missing %s header", ErrInvalidIR, knowHeader)
+	}
+	query := ""
+	if rest := header[len(knowHeader):]; rest != "" {
+		if !strings.HasPrefix(rest, "{") || !strings.HasSuffix(rest, "}") {
+			return "", nil, fmt.Errorf("%w: malformed %s header", ErrInvalidIR, knowHeader)
+		}
+		query = rest[1 : len(rest)-1]
+	}
+	var (
+		chunks []Chunk
+		body   []string
+	)
+	flush := func() {
+		if len(chunks) > 0 {
+			chunks[len(chunks)-1].Text = strings.Join(body, "\n")
+		}
+		body = nil
+	}
+	for i, line := range lines[1:] {
+		if match := knowChunkRe.FindStringSubmatch(line); match != nil && match[1] == fmt.Sprint(len(chunks)+1) {
+			flush()
+			chunks = append(chunks, Chunk{Source: match[2]})
+			body = append(body, match[3])
+			continue
+		}
+		if len(chunks) == 0 {
+			return "", nil, fmt.Errorf("%w: line %d: text before the first chunk", ErrInvalidIR, i+2)
+		}
+		body = append(body, strings.TrimPrefix(line, `\`))
+	}
+	flush()
+	return query, chunks, nil
+}
+
+type knowEncoder struct {
+	level Level
+	stats map[string]int
+}
+
+// knowChunk is a chunk split into lines of sentences.
+type knowChunk struct {
+	source string
+	lines  [][]string
+	score  float64
+}
+
+// rank orders the chunks by their BM25 score for query, keeping the input
+// order among equal scores and when there is no query.
+func (e *knowEncoder) rank(query string, chunks []Chunk) []knowChunk {
+	docs := make([][]string, len(chunks))
+	df := map[string]int{}
+	total := 0
+	for i, chunk := range chunks {
+		docs[i] = knowTerms(chunk.Text)
+		total += len(docs[i])
+		seen := map[string]bool{}
+		for _, term := range docs[i] {
+			if !seen[term] {
+				seen[term] = true
+				df[term]++
+			}
+		}
+	}
+	avg := math.Max(float64(total)/float64(len(chunks)), 1)
+	terms := uniqueTerms(knowTerms(query))
+
+	ranked := make([]knowChunk, 0, len(chunks))
+	for i, chunk := range chunks {
+		tf := map[string]int{}
+		for _, term := range docs[i] {
+			tf[term]++
+		}
+		score := 0.0
+		for _, term := range terms {
+			if tf[term] == 0 {
+				continue
+			}
+			idf := math.Log(1 + (float64(len(chunks)-df[term])+0.5)/(float64(df[term])+0.5))
+			f := float64(tf[term])
+			score += idf * f * (bm25K1 + 1) / (f + bm25K1*(1-bm25B+bm25B*float64(len(docs[i]))/avg))
+		}
+		ranked = append(ranked, knowChunk{source: chunk.Source, lines: knowSentences(chunk.Text, e.level), score: score})
+	}
+	sort.SliceStable(ranked, func(i, j int) bool {
+		return ranked[i].score > ranked[j].score
+	})
+	return ranked
+}
+
+// dedupe drops the sentences already kept from a more relevant chunk and,
+// at LevelAggressive, those that do not mention the query. Chunks left
+// empty are dropped.
+func (e *knowEncoder) dedupe(query string, chunks []knowChunk) []knowChunk {
+	terms := map[string]bool{}
+	for _, term := range knowTerms(query) {
+		terms[term] = true
+	}
+	exact := map[string]bool{}
+	var near [][]string
+	kept := chunks[:0]
+	for _, chunk := range chunks {
+		var lines [][]string
+		for _, line := range chunk.lines {
+			var sentences []string
+			for _, sentence := range line {
+				words := knowTerms(sentence)
+				key := strings.Join(words, " ")
+				switch {
+				case key == "":
+				case exact[key] || e.level > LevelLossless && isNearDuplicate(words, near):
+					e.stats["duplicate"]++
+					continue
+				case e.level >= LevelAggressive && len(terms) > 0 && !mentionsAny(words, terms):
+					e.stats["off-topic"]++
+					continue
+				}
+				if key != "" {
+					exact[key] = true
+					near = append(near, uniqueTerms(words))
+				}
+				sentences = append(sentences, sentence)
+			}
+			if len(sentences) < len(line) && len(sentences) > 0 {
+				sentences[len(sentences)-1] = strings.TrimRight(sentences[len(sentences)-1], " \t")
+			}
+			if len(sentences) > 0 {
+				lines = append(lines, sentences)
+			}
+		}
+		if len(lines) == 0 {
+			e.stats["redundant"]++
+			continue
+		}
+		chunk.lines = lines
+		kept = append(kept, chunk)
+	}
+	return kept
+}
+
+// fit writes the IR, cutting the chunks that do not fit budget tokens at a
+// sentence boundary. The first sentence is always kept, so that an IR over
+// budget still says what it is about.
+func (e *knowEncoder) fit(header string, chunks []knowChunk, budget int, tokenizer Tokenizer) string {
+	if tokenizer == nil {
+		tokenizer = BPETokenizer{}
+	}
+	out := []string{header}
+	fits := func(lines []string) bool {
+		return budget <= 0 || tokenizer.Count(strings.Join(append(out, lines...), "\n")) <= budget
+	}
+	for i, chunk := range chunks {
+		if lines := knowLines(i+1, chunk.source, chunk.lines); fits(lines) {
+			out = append(out, lines...)
+			continue
+		}
+		// Keep the longest prefix of the chunk's sentences that fits.
+		var prefix, partial [][]string
+	sentences:
+		for _, line := range chunk.lines {
+			prefix = append(prefix, nil)
+			for _, sentence := range line {
+				prefix[len(prefix)-1] = append(prefix[len(prefix)-1], sentence)
+				if !fits(knowLines(i+1, chunk.source, prefix)) {
+					break sentences
+				}
+				partial = clonePrefix(prefix)
+			}
+		}
+		if partial == nil && i == 0 {
+			partial = [][]string{chunk.lines[0][:1]}
+		}
+		if partial != nil {
+			last := partial[len(partial)-1]
+			last[len(last)-1] = strings.TrimRight(last[len(last)-1], " \t")
+			out = append(out, knowLines(i+1, chunk.source, partial)...)
+		}
+		e.stats["budget"] += len(chunks) - i
+		break
+	}
+	return strings.Join(out, "\n")
+}
+
+func clonePrefix(lines [][]string) [][]string {
+	clone := make([][]string, len(lines))
+	for i, line := range lines {
+		clone[i] = append([]string(nil), line...)
+	}
+	return clone
+}
+
+func (e *knowEncoder) losses() []Loss {
+	var losses []Loss
+	for _, kind := range []string{"duplicate", "off-topic", "redundant", "budget"} {
+		if count := e.stats[kind]; count > 0 {
+			detail := "dropped"
+			if kind == "budget" {
+				detail = "chunks cut to fit the token budget"
+			}
+			losses = append(losses, Loss{Kind: kind, Count: count, Detail: detail})
+		}
+	}
+	return losses
+}
+
+// knowLines writes chunk n under its "[Sn source]" tag. Continuation lines
+// that could be read as a tag are escaped with a backslash.
+func knowLines(n int, source string, lines [][]string) []string {
+	tag := fmt.Sprintf("[S%d]", n)
+	if source != "" {
+		tag = fmt.Sprintf("[S%d %s]", n, source)
+	}
+	out := make([]string, 0, len(lines))
+	for i, sentences := range lines {
+		text := strings.Join(sentences, "")
+		if i == 0 {
+			if text != "" {
+				tag += " " + text
+			}
+			out = append(out, tag)
+			continue
+		}
+		if strings.HasPrefix(text, "[S") || strings.HasPrefix(text, `\`) {
+			text = `\` + text
+		}
+		out = append(out, text)
+	}
+	return out
+}
+
+// knowSentences splits text into its non-blank lines and each line into
+// sentences that keep their trailing whitespace, so that joining them
+// restores the line. Above LevelLossless, runs of whitespace are collapsed
+// first.
+func knowSentences(text string, level Level) [][]string {
+	var lines [][]string
+	for _, line := range strings.Split(text, "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		if level > LevelLossless {
+			line = strings.Join(strings.Fields(line), " ")
+		}
+		var sentences []string
+		start := 0
+		for i := 0; i < len(line); i++ {
+			if strings.IndexByte(".!?", line[i]) < 0 || i+1 == len(line) || (line[i+1] != ' ' && line[i+1] != '\t') {
+				continue
+			}
+			end := i + 1
+			for end < len(line) && (line[end] == ' ' || line[end] == '\t') {
+				end++
+			}
+			if end == len(line) {
+				break
+			}
+			sentences = append(sentences, line[start:end])
+			start, i = end, end-1
+		}
+		lines = append(lines, append(sentences, line[start:]))
+	}
+	return lines
+}
+
+// knowTerms returns the lowercase words and numbers of text.
+func knowTerms(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+func uniqueTerms(terms []string) []string {
+	seen := map[string]bool{}
+	unique := terms[:0:0]
+	for _, term := range terms {
+		if !seen[term] {
+			seen[term] = true
+			unique = append(unique, term)
+		}
+	}
+	return unique
+}
+
+// isNearDuplicate reports whether the words of a sentence overlap one of
+// the kept word sets by at least knowNearDuplicate.
+func isNearDuplicate(words []string, kept [][]string) bool {
+	set := uniqueTerms(words)
+	if len(set) < knowNearMinWords {
+		return false
+	}
+	for _, other := range kept {
+		if len(other) < knowNearMinWords {
+			continue
+		}
+		shared := 0
+		for _, word := range set {
+			for _, o := range other {
+				if word == o {
+					shared++
+					break
+				}
+			}
+		}
+		if float64(shared)/float64(len(set)+len(other)-shared) >= knowNearDuplicate {
+			return true
+		}
+	}
+	return false
+}
+
+func mentionsAny(words []string, terms map[string]bool) bool {
+	for _, word := range words {
+		if terms[word] {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,187 @@
+package iron
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var knowChunks = []Chunk{
+	{Source: "notes/groceries.md", Text: "Buy milk and eggs on Friday. The market opens at 8."},
+	{Source: "notes/logging.md", Text: "Rotate logs daily with logrotate. Keep seven compressed copies.\nSend rotated logs to the archive bucket."},
+	{Source: "wiki/ops.md", Text: "Rotate logs daily with logrotate! Keep seven compressed log copies.\nLogrotate runs from cron at midnight."},
+}
+
+func TestKnowModule_Encode_RanksAndTagsSources(t *testing.T) {
+	input := FormatChunks("how do we rotate logs with logrotate?", knowChunks)
+	if !(KnowModule{}).Detect(input) {
+		t.Fatalf("Detect() = false for\n%s", input)
+	}
+	ir, losses, err := KnowModule{Level: LevelBalanced}.EncodeWithLoss(input)
+	if err != nil {
+		t.Fatalf("EncodeWithLoss() error = %v", err)
+	}
+	want := strings.Join([]string{
+		"@KNOW{how do we rotate logs with logrotate?}",
+		"[S1 wiki/ops.md] Rotate logs daily with logrotate! Keep seven compressed log copies.",
+		"Logrotate runs from cron at midnight.",
+		"[S2 notes/logging.md] Send rotated logs to the archive bucket.",
+		"[S3 notes/groceries.md] Buy milk and eggs on Friday. The market opens at 8.",
+	}, "\n")
+	if ir != want {
+		t.Fatalf("Encode() =\n%s\nwant:\n%s", ir, want)
+	}
+	kinds := map[string]int{}
+	for _, loss := range losses {
+		kinds[loss.Kind] = loss.Count
+	}
+	if kinds["duplicate"] != 2 {
+		t.Fatalf("losses = %+v", losses)
+	}
+
+	decoded, err := KnowModule{}.Decode(ir)
+	if err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	query, chunks := ParseChunks(decoded)
+	if query != "how do we rotate logs with logrotate?" || len(chunks) != 3 || chunks[0].Source != "wiki/ops.md" {
+		t.Fatalf("Decode() = %q", decoded)
+	}
+}
+
+func TestKnowModule_Encode_LosslessKeepsNearDuplicates(t *testing.T) {
+	input := FormatChunks("logrotate", knowChunks)
+	lossless, err := KnowModule{}.Encode(input)
+	if err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+	if strings.Count(lossless, "Rotate logs daily with logrotate") != 1 ||
+		!strings.Contains(lossless, "Keep seven compressed copies.") || !strings.Contains(lossless, "market") {
+		t.Fatalf("lossless Encode() =\n%s", lossless)
+	}
+	balanced, err := KnowModule{Level: LevelBalanced}.Encode(input)
+	if err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+	if strings.Contains(balanced, "Keep seven compressed copies.") || !strings.HasSuffix(balanced, "The market opens at 8.") {
+		t.Fatalf("balanced Encode() =\n%s", balanced)
+	}
+}
+
+func TestKnowModule_Encode_KeepsUnrelatedToolOutput(t *testing.T) {
+	df := "Filesystem      Size  Used Avail Use% Mounted on\n/dev/sda1        50G   47G  3.0G  94% /\ntmpfs           2.0G     0  2.0G   0% /dev/shm"
+	input := FormatChunks("Summarize the disk usage and warn me if anything is nearly full", []Chunk{{Source: "shell_exec", Text: df}})
+	want := "@KNOW{Summarize the disk usage and warn me if anything is nearly full}\n[S1 shell_exec] " + df
+	for _, level := range []Level{LevelLossless, LevelBalanced} {
+		ir, losses, err := KnowModule{Level: level}.EncodeWithLoss(input)
+		if err != nil {
+			t.Fatalf("EncodeWithLoss(%s) error = %v", level, err)
+		}
+		if level == LevelLossless && (ir != want || len(losses) != 0) {
+			t.Fatalf("lossless EncodeWithLoss() = %+v\n%s\nwant:\n%s", losses, ir, want)
+		}
+		if !strings.Contains(ir, "/dev/sda1") {
+			t.Fatalf("EncodeWithLoss(%s) dropped the tool output:\n%s", level, ir)
+		}
+	}
+}
+
+func TestKnowModule_Encode_AggressiveKeepsQuerySentences(t *testing.T) {
+	ir, err := KnowModule{Level: LevelAggressive}.Encode(FormatChunks("archive bucket", knowChunks))
+	if err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+	if ir != "@KNOW{archive bucket}\n[S1 notes/logging.md] Send rotated logs to the archive bucket." {
+		t.Fatalf("Encode() =\n%s", ir)
+	}
+}
+
+func TestKnowModule_EncodeContext_TruncatesToBudget(t *testing.T) {
+	input := FormatChunks("logrotate", knowChunks)
+	full, err := KnowModule{}.Encode(input)
+	if err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+	budget := BPETokenizer{}.Count(full) / 2
+	ctx := ContextWithHints(context.Background(), Hints{TokenBudget: budget})
+	ir, losses, err := KnowModule{}.EncodeContext(ctx, input)
+	if err != nil {
+		t.Fatalf("EncodeContext() error = %v", err)
+	}
+	if got := (BPETokenizer{}).Count(ir); got > budget || !strings.HasPrefix(ir, "@KNOW{logrotate}\n[S1 wiki/ops.md] Rotate logs") {
+		t.Fatalf("EncodeContext() = %d tokens, budget %d:\n%s", got, budget, ir)
+	}
+	if len(losses) == 0 || losses[len(losses)-1].Kind != "budget" {
+		t.Fatalf("losses = %+v", losses)
+	}
+
+	tiny, err := KnowModule{Budget: 1}.Encode(input)
+	if err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+	if tiny != "@KNOW{logrotate}\n[S1 wiki/ops.md] Rotate logs daily with logrotate!" {
+		t.Fatalf("Encode() with budget 1 =\n%s", tiny)
+	}
+}
+
+func TestKnowModule_Encode_EscapesTagLikeLines(t *testing.T) {
+	input := FormatChunks("", []Chunk{{Source: "a", Text: "first\n[S2 b] not a tag\n\\ backslash"}, {Text: "second"}})
+	ir, err := KnowModule{}.Encode(input)
+	if err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+	decoded, err := KnowModule{}.Decode(ir)
+	if err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	if decoded != input {
+		t.Fatalf("Decode() =\n%s\nwant:\n%s\nIR:\n%s", decoded, input, ir)
+	}
+}
+
+func TestParseChunks_KeepsSourceLikeLines(t *testing.T) {
+	chunks := []Chunk{
+		{Source: "notes/a.md", Text: "see below\n[source: fake.md]\n  \\path\\to\\file"},
+		{Source: "notes/b.md", Text: "second"},
+	}
+	query, got := ParseChunks(FormatChunks("find it", chunks))
+	if query != "find it" || !reflect.DeepEqual(got, chunks) {
+		t.Fatalf("ParseChunks() = %q, %+v, want %+v", query, got, chunks)
+	}
+}
+
+func TestCitations_MapsReferencesToSources(t *testing.T) {
+	ir := "@KNOW{q}\n[S1 wiki/ops.md] a\n[S2 notes/logging.md] b\n[S3] c"
+	got, err := Citations(ir, "Use logrotate [S2]. It runs nightly [S1, S2]; see also [S9] and [S3].")
+	if err != nil {
+		t.Fatalf("Citations() error = %v", err)
+	}
+	want := []Citation{{"S2", "notes/logging.md"}, {"S1", "wiki/ops.md"}, {"S3", ""}}
+	if len(got) != len(want) {
+		t.Fatalf("Citations() = %+v, want %+v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("Citations() = %+v, want %+v", got, want)
+		}
+	}
+	if _, err := Citations("@WEB\nP x", "[S1]"); err == nil {
+		t.Fatal("Citations() error = nil for other IR")
+	}
+}
+
+func TestKnowModule_Detect(t *testing.T) {
+	cases := map[string]bool{
+		"[query: q]\n[source: a]\ntext": true,
+		"[source: a]\ntext":             true,
+		"[query: q] only":               false,
+		"[INFO] source: a":              false,
+		"see [source: a]":               false,
+	}
+	for input, want := range cases {
+		if got := (KnowModule{}).Detect(input); got != want {
+			t.Errorf("Detect(%q) = %t, want %t", input, got, want)
+		}
+	}
+}
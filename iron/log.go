@@ -0,0 +1,519 @@
+package iron
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// LogModule compresses shell, docker, and service log output.
+//
+// Lines are reduced to templates whose timestamps, UUIDs, IPs, hashes, and
+// numbers become typed placeholders. At LevelLossless the placeholder values
+// are kept, except those every line of a template shares, which stay in the
+// template, and the log is restored exactly; higher levels drop the values,
+// collapse runs, and discard debug lines. Error and warning lines are always
+// kept in full.
+type LogModule struct {
+	Level Level
+}
+
+const logHeader = "@LOG"
+
+var (
+	logTokenRe = regexp.MustCompile(
+		`(\d{4}-\d{2}-\d{2}[T ]\d{2}:\d{2}:\d{2}(?:[.,]\d+)?(?:Z|[+-]\d{2}:?\d{2})?|\d{4}/\d{2}/\d{2} \d{2}:\d{2}:\d{2}(?:\.\d+)?|(?:Jan|Feb|Mar|Apr|May|Jun|Jul|Aug|Sep|Oct|Nov|Dec) [ \d]\d \d{2}:\d{2}:\d{2}|\b\d{2}:\d{2}:\d{2}(?:\.\d+)?)` +
+			`|([0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12})` +
+			`|\b(\d{1,3}(?:\.\d{1,3}){3}(?::\d+)?)\b` +
+			`|\b(0x[0-9a-fA-F]+|[0-9a-f]{12,64})\b` +
+			`|(\d+(?:\.\d+)?)`)
+	logTokenKinds  = []string{"ts", "uuid", "ip", "hex", "n"}
+	logLevelRe     = regexp.MustCompile(`(?i)\b(?:INFO|WARN|WARNING|ERROR|ERR|DEBUG|DBG|TRACE|FATAL|PANIC|CRIT|NOTICE)\b|\blevel=\w+`)
+	logPrefixRe    = regexp.MustCompile(`^\S+\s+\|\s|^\[[^\]]+\]|^\w+=\S+`)
+	logSevereRe    = regexp.MustCompile(`(?i)\b(?:error|err|fatal|panic|warn|warning|exception|critical|crit|fail|failed|failure|traceback)\b|level=(?:error|warn|warning|fatal)`)
+	logDebugRe     = regexp.MustCompile(`(?i)\b(?:DEBUG|DBG|TRACE)\b|level=(?:debug|trace)`)
+	logInstanceRe  = regexp.MustCompile(`^@(\d+)(?:\*(\d+))?(?: (.*))?$`)
+	logRepeatRe    = regexp.MustCompile(`^@\*(\d+) (.*)$`)
+	logTemplateRe  = regexp.MustCompile(`^@T(\d+) (.*)$`)
+	logDropRe      = regexp.MustCompile(`^@DROP (\d+) (\w+)$`)
+	logPlaceholder = regexp.MustCompile(`<(?:ts|uuid|ip|hex|n)>`)
+)
+
+type logLine struct {
+	text     string
+	template string
+	values   []string
+	severe   bool
+	debug    bool
+}
+
+func (LogModule) Name() string {
+	return "IR-LOG"
+}
+
+// Detect reports whether most lines look like log output.
+func (LogModule) Detect(input string) bool {
+	lines := strings.Split(strings.TrimSpace(input), "\n")
+	if len(lines) < 3 {
+		return false
+	}
+	var nonEmpty, logLike int
+	templates := map[string]int{}
+	for _, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		nonEmpty++
+		template, _ := templateLogLine(line)
+		templates[template]++
+		if logLevelRe.MatchString(line) || logPrefixRe.MatchString(line) || strings.Contains(template, "<ts>") {
+			logLike++
+		}
+	}
+	if nonEmpty < 3 {
+		return false
+	}
+	repeated := nonEmpty - len(templates)
+	return logLike*2 >= nonEmpty || repeated*2 >= nonEmpty
+}
+
+// Encode compresses the log at the module's level.
+func (m LogModule) Encode(input string) (string, error) {
+	encoded, _, err := m.EncodeWithLoss(input)
+	return encoded, err
+}
+
+// EncodeWithLoss compresses the log and reports what the level discarded.
+func (m LogModule) EncodeWithLoss(input string) (string, []Loss, error) {
+	lines := classifyLogLines(strings.Split(input, "\n"))
+	if m.Level == LevelLossless {
+		foldLogTemplates(lines)
+	}
+	enc := &logEncoder{level: m.Level, ids: map[string]int{}, uses: map[string]int{}}
+	for _, line := range lines {
+		if !line.severe && len(line.values) > 0 {
+			enc.uses[line.template]++
+		}
+	}
+	switch m.Level {
+	case LevelLossless:
+		enc.encodeLossless(lines)
+	case LevelBalanced:
+		enc.encodeRuns(lines, false)
+	default:
+		enc.encodeRuns(lines, true)
+	}
+	return enc.finish(), enc.losses(), nil
+}
+
+// EncodeContext encodes at the level hinted in ctx, if any.
+func (m LogModule) EncodeContext(ctx context.Context, input string) (string, []Loss, error) {
+	if err := ctx.Err(); err != nil {
+		return "", nil, err
+	}
+	m.Level = HintsFromContext(ctx).LevelOr(m.Level)
+	return m.EncodeWithLoss(input)
+}
+
+// DecodeContext decodes unless ctx is done.
+func (m LogModule) DecodeContext(ctx context.Context, ir string) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	return m.Decode(ir)
+}
+
+// Decode restores the log; lossy encodings expand to templates with counts.
+func (LogModule) Decode(output string) (string, error) {
+	lines := strings.Split(output, "\n")
+	args, err := parseHeader(lines[0], logHeader)
+	if err != nil {
+		return "", err
+	}
+	level, ok := Level(0), len(args) == 1
+	if ok {
+		level, ok = ParseLevel(args[0])
+	}
+	if !ok {
+		return "", fmt.Errorf("%w: unknown log level %q", ErrInvalidIR, strings.Join(args, ","))
+	}
+
+	templates := map[string]string{}
+	var out []string
+	for _, line := range lines[1:] {
+		if match := logTemplateRe.FindStringSubmatch(line); match != nil {
+			templates[match[1]] = match[2]
+			continue
+		}
+		if match := logRepeatRe.FindStringSubmatch(line); match != nil {
+			count, _ := strconv.Atoi(match[1])
+			out = appendLogRun(out, unescapeLogLine(match[2]), count, level)
+			continue
+		}
+		if match := logDropRe.FindStringSubmatch(line); match != nil {
+			out = append(out, fmt.Sprintf("[%s %s lines dropped]", match[1], match[2]))
+			continue
+		}
+		if match := logInstanceRe.FindStringSubmatch(line); match != nil {
+			template, ok := templates[match[1]]
+			if !ok {
+				return "", fmt.Errorf("%w: unknown log template %s", ErrInvalidIR, match[1])
+			}
+			count := 1
+			if match[2] != "" {
+				count, _ = strconv.Atoi(match[2])
+			}
+			text := template
+			if level == LevelLossless {
+				text, err = fillLogTemplate(template, match[3])
+				if err != nil {
+					return "", err
+				}
+			}
+			out = appendLogRun(out, text, count, level)
+			continue
+		}
+		out = append(out, unescapeLogLine(line))
+	}
+	return strings.Join(out, "\n"), nil
+}
+
+func (LogModule) Score() float64 {
+	return 0.85
+}
+
+// Classify returns the default classifier's confidence that input is a log.
+func (LogModule) Classify(input string) float64 {
+	return DefaultClassifier().Confidence("log", input)
+}
+
+// templateLogLine replaces variable tokens with typed placeholders.
+func templateLogLine(line string) (string, []string) {
+	var (
+		sb     strings.Builder
+		values []string
+		last   int
+	)
+	for _, loc := range logTokenRe.FindAllStringSubmatchIndex(line, -1) {
+		kind := ""
+		for i, name := range logTokenKinds {
+			if loc[2+2*i] >= 0 {
+				kind = name
+				break
+			}
+		}
+		start, end := loc[0], loc[1]
+		if kind == "n" && start > 0 && isLogWordByte(line[start-1]) {
+			continue
+		}
+		if kind == "hex" && !strings.HasPrefix(line[start:end], "0x") && !strings.ContainsAny(line[start:end], "abcdef") {
+			kind = "n"
+		}
+		sb.WriteString(line[last:start])
+		sb.WriteString("<" + kind + ">")
+		values = append(values, line[start:end])
+		last = end
+	}
+	sb.WriteString(line[last:])
+	return sb.String(), values
+}
+
+func isLogWordByte(b byte) bool {
+	return b == '_' || b == '.' || b == '-' || (b >= 'a' && b <= 'z') || (b >= 'A' && b <= 'Z') || (b >= '0' && b <= '9')
+}
+
+func classifyLogLines(raw []string) []logLine {
+	lines := make([]logLine, len(raw))
+	for i, text := range raw {
+		line := logLine{text: text}
+		if !logPlaceholder.MatchString(text) {
+			line.template, line.values = templateLogLine(text)
+		} else {
+			line.template = text
+		}
+		continuation := i > 0 && text != "" && (text[0] == ' ' || text[0] == '\t')
+		if continuation {
+			line.severe = lines[i-1].severe
+			line.debug = lines[i-1].debug
+		} else {
+			line.severe = logSevereRe.MatchString(text)
+			line.debug = !line.severe && logDebugRe.MatchString(text)
+		}
+		lines[i] = line
+	}
+	return lines
+}
+
+// foldLogTemplates writes the values that every line of a template shares
+// back into the template, so that its instances carry only the values that
+// vary. Templates whose values never vary are left alone.
+func foldLogTemplates(lines []logLine) {
+	shared := map[string][]string{}
+	for _, line := range lines {
+		if line.severe || len(line.values) == 0 {
+			continue
+		}
+		values, ok := shared[line.template]
+		if !ok {
+			shared[line.template] = append([]string(nil), line.values...)
+			continue
+		}
+		for i := range values {
+			if values[i] != line.values[i] {
+				// Placeholder values are never empty, so "" marks one that varies.
+				values[i] = ""
+			}
+		}
+	}
+	folded := map[string]string{}
+	for template, values := range shared {
+		if !containsString(values, "") {
+			continue
+		}
+		var (
+			sb   strings.Builder
+			last int
+		)
+		for i, hole := range logPlaceholder.FindAllStringIndex(template, -1) {
+			sb.WriteString(template[last:hole[0]])
+			if values[i] != "" {
+				sb.WriteString(values[i])
+			} else {
+				sb.WriteString(template[hole[0]:hole[1]])
+			}
+			last = hole[1]
+		}
+		sb.WriteString(template[last:])
+		folded[template] = sb.String()
+	}
+	for i, line := range lines {
+		template, ok := folded[line.template]
+		if !ok || line.severe {
+			continue
+		}
+		values := shared[line.template]
+		var varying []string
+		for j, value := range line.values {
+			if values[j] == "" {
+				varying = append(varying, value)
+			}
+		}
+		lines[i].template, lines[i].values = template, varying
+	}
+}
+
+type logEncoder struct {
+	level    Level
+	out      []string
+	ids      map[string]int
+	uses     map[string]int
+	values   int
+	merged   int
+	dropped  int
+	reorders int
+}
+
+func (e *logEncoder) encodeLossless(lines []logLine) {
+	templated := logTemplatesWorthIt(lines, e.uses)
+	for i := 0; i < len(lines); {
+		count := 1
+		for i+count < len(lines) && lines[i+count].text == lines[i].text {
+			count++
+		}
+		line := lines[i]
+		if !line.severe && templated[line.template] {
+			id := e.templateID(line.template)
+			e.out = append(e.out, logInstance(id, count, line.values))
+		} else {
+			e.out = append(e.out, logRepeat(line.text, count))
+		}
+		i += count
+	}
+}
+
+// logTemplatesWorthIt returns the templates used more than once whose
+// definition and instances take fewer tokens than the lines they replace.
+// Lines that share little besides their placeholders, such as access logs
+// with many short fields, are cheaper written out.
+func logTemplatesWorthIt(lines []logLine, uses map[string]int) map[string]bool {
+	var (
+		tokenizer = BPETokenizer{}
+		raw       = map[string]int{}
+		templated = map[string]int{}
+	)
+	for i := 0; i < len(lines); {
+		count := 1
+		for i+count < len(lines) && lines[i+count].text == lines[i].text {
+			count++
+		}
+		line := lines[i]
+		if !line.severe && uses[line.template] > 1 {
+			if _, ok := templated[line.template]; !ok {
+				templated[line.template] = tokenizer.Count(fmt.Sprintf("@T%d %s", len(templated), line.template))
+			}
+			raw[line.template] += tokenizer.Count(logRepeat(line.text, count))
+			templated[line.template] += tokenizer.Count(logInstance(len(templated), count, line.values))
+		}
+		i += count
+	}
+	worth := map[string]bool{}
+	for template, cost := range templated {
+		if cost < raw[template] {
+			worth[template] = true
+		}
+	}
+	return worth
+}
+
+func logInstance(id, count int, values []string) string {
+	return fmt.Sprintf("@%d%s %s", id, logCount(count), strings.Join(values, "|"))
+}
+
+// encodeRuns drops placeholder values and collapses runs of the same
+// template. When global is set, debug lines are dropped and each template is
+// emitted once with its total count.
+func (e *logEncoder) encodeRuns(lines []logLine, global bool) {
+	totals := map[string]int{}
+	if global {
+		for _, line := range lines {
+			if !line.severe && !line.debug {
+				totals[line.template]++
+			}
+		}
+	}
+	emitted := map[string]bool{}
+	for i := 0; i < len(lines); {
+		line := lines[i]
+		if global && line.debug {
+			e.dropped++
+			i++
+			continue
+		}
+		if line.severe {
+			count := 1
+			for i+count < len(lines) && lines[i+count].text == line.text {
+				count++
+			}
+			e.out = append(e.out, logRepeat(line.text, count))
+			e.merged += count - 1
+			i += count
+			continue
+		}
+		run := 1
+		for i+run < len(lines) && !lines[i+run].severe && !lines[i+run].debug && lines[i+run].template == line.template {
+			run++
+		}
+		for _, l := range lines[i : i+run] {
+			e.values += len(l.values)
+		}
+		i += run
+		count := run
+		if global {
+			if emitted[line.template] {
+				e.reorders += run
+				continue
+			}
+			emitted[line.template] = true
+			count = totals[line.template]
+		}
+		e.merged += count - 1
+		if len(line.values) == 0 && !global {
+			e.out = append(e.out, logRepeat(line.template, count))
+		} else {
+			e.out = append(e.out, fmt.Sprintf("@%d%s", e.templateID(line.template), logCount(count)))
+		}
+	}
+	if e.dropped > 0 {
+		e.out = append(e.out, fmt.Sprintf("@DROP %d debug", e.dropped))
+	}
+}
+
+func (e *logEncoder) templateID(template string) int {
+	if id, ok := e.ids[template]; ok {
+		return id
+	}
+	id := len(e.ids)
+	e.ids[template] = id
+	e.out = append(e.out, fmt.Sprintf("@T%d %s", id, template))
+	return id
+}
+
+func (e *logEncoder) finish() string {
+	return formatHeader(logHeader, e.level.String()) + "\n" + strings.Join(e.out, "\n")
+}
+
+func (e *logEncoder) losses() []Loss {
+	var losses []Loss
+	if e.values > 0 {
+		losses = append(losses, Loss{Kind: "values", Count: e.values, Detail: "timestamps, ids and numbers replaced by placeholders"})
+	}
+	if e.merged > 0 {
+		losses = append(losses, Loss{Kind: "lines", Count: e.merged, Detail: "repeated lines merged into counts"})
+	}
+	if e.dropped > 0 {
+		losses = append(losses, Loss{Kind: "debug", Count: e.dropped, Detail: "debug and trace lines dropped"})
+	}
+	if e.reorders > 0 {
+		losses = append(losses, Loss{Kind: "order", Count: e.reorders, Detail: "lines folded into an earlier template"})
+	}
+	return losses
+}
+
+func logCount(count int) string {
+	if count == 1 {
+		return ""
+	}
+	return "*" + strconv.Itoa(count)
+}
+
+func logRepeat(text string, count int) string {
+	if count == 1 {
+		return escapeLogLine(text)
+	}
+	return fmt.Sprintf("@*%d %s", count, escapeLogLine(text))
+}
+
+func appendLogRun(out []string, text string, count int, level Level) []string {
+	if level != LevelLossless {
+		if count > 1 {
+			text += fmt.Sprintf(" [x%d]", count)
+		}
+		return append(out, text)
+	}
+	for i := 0; i < count; i++ {
+		out = append(out, text)
+	}
+	return out
+}
+
+func fillLogTemplate(template, joined string) (string, error) {
+	values := strings.Split(joined, "|")
+	holes := logPlaceholder.FindAllStringIndex(template, -1)
+	if len(holes) != len(values) {
+		return "", fmt.Errorf("%w: log template expects %d values, got %d", ErrInvalidIR, len(holes), len(values))
+	}
+	var (
+		sb   strings.Builder
+		last int
+	)
+	for i, hole := range holes {
+		sb.WriteString(template[last:hole[0]])
+		sb.WriteString(values[i])
+		last = hole[1]
+	}
+	sb.WriteString(template[last:])
+	return sb.String(), nil
+}
+
+func escapeLogLine(line string) string {
+	if strings.HasPrefix(line, "@") || strings.HasPrefix(line, `\`) {
+		return `\` + line
+	}
+	return line
+}
+
+func unescapeLogLine(line string) string {
+	return strings.TrimPrefix(line, `\`)
+}
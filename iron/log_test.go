@@ -0,0 +1,144 @@
+package iron
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func readLogFixture(t *testing.T) string {
+	t.Helper()
+	data, err := os.ReadFile("testdata/log/service.log")
+	if err != nil {
+		t.Fatalf("read fixture: %v", err)
+	}
+	return strings.TrimSuffix(string(data), "\n")
+}
+
+func TestLogModule_Lossless_RoundTrip(t *testing.T) {
+	input := readLogFixture(t)
+	module := LogModule{}
+	if !module.Detect(input) {
+		t.Fatalf("Detect() = false, want true")
+	}
+	encoded, losses, err := module.EncodeWithLoss(input)
+	if err != nil {
+		t.Fatalf("EncodeWithLoss() error = %v", err)
+	}
+	if len(losses) != 0 {
+		t.Fatalf("EncodeWithLoss() losses = %+v, want none", losses)
+	}
+	if len(encoded) >= len(input) {
+		t.Fatalf("IR length = %d, want < input length %d", len(encoded), len(input))
+	}
+	decoded, err := module.Decode(encoded)
+	if err != nil {
+		t.Fatalf("Decode() error = %v\nIR:\n%s", err, encoded)
+	}
+	if decoded != input {
+		t.Fatalf("Decode() mismatch\ngot:\n%s\nwant:\n%s\nIR:\n%s", decoded, input, encoded)
+	}
+}
+
+func TestLogModule_Lossless_FoldsSharedValues(t *testing.T) {
+	input := strings.Join([]string{
+		`10.0.0.12 - - [11/Feb/2026:08:17:05 +0000] "GET /api/health HTTP/1.1" 200 17 "-" "kube-probe/1.29"`,
+		`10.0.0.12 - - [11/Feb/2026:08:17:15 +0000] "GET /api/health HTTP/1.1" 200 17 "-" "kube-probe/1.29"`,
+		`10.0.0.9 - - [11/Feb/2026:08:17:31 +0000] "GET /api/reports/7 HTTP/1.1" 500 231 "-" "Mozilla/5.0"`,
+	}, "\n")
+	encoded, err := LogModule{}.Encode(input)
+	if err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+	if !strings.Contains(encoded, `@T0 10.0.0.12 - - [11/Feb/2026:<ts> +0000] "GET /api/health HTTP/1.1" 200 17`) || !strings.Contains(encoded, "\n@0 08:17:15\n") {
+		t.Fatalf("Encode() =\n%s\nwant the shared values in the template", encoded)
+	}
+	if decoded, err := (LogModule{}).Decode(encoded); err != nil || decoded != input {
+		t.Fatalf("Decode() = %q, %v", decoded, err)
+	}
+}
+
+func TestLogModule_Balanced_KeepsSevereLines(t *testing.T) {
+	input := readLogFixture(t)
+	encoded, losses, err := LogModule{Level: LevelBalanced}.EncodeWithLoss(input)
+	if err != nil {
+		t.Fatalf("EncodeWithLoss() error = %v", err)
+	}
+	for _, want := range []string{
+		"@LOG[balanced]",
+		"@T0 <ts> INFO  http: GET /api/users/<n> status=<n> took <n>ms request_id=<uuid>",
+		"2026-03-01T10:00:01.002Z WARN  db: slow query took 1503ms table=users",
+		"2026-03-01T10:00:02.500Z ERROR http: GET /api/orders/7 status=500 upstream 10.0.3.17:5432 connection refused",
+		"\tmain.handleOrders(0xc000123456)",
+		"@T3 web_1  | Listening on port <n>",
+	} {
+		if !strings.Contains(encoded, want) {
+			t.Fatalf("EncodeWithLoss() missing %q\nIR:\n%s", want, encoded)
+		}
+	}
+	if lossCount(losses, "values") == 0 || lossCount(losses, "lines") == 0 {
+		t.Fatalf("EncodeWithLoss() losses = %+v, want values and lines", losses)
+	}
+
+	decoded, err := LogModule{}.Decode(encoded)
+	if err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	if !strings.Contains(decoded, "<ts> INFO  http: GET /api/users/<n> status=<n> took <n>ms request_id=<uuid> [x3]") {
+		t.Fatalf("Decode() = %s, want collapsed template with count", decoded)
+	}
+}
+
+func TestLogModule_Aggressive_DropsDebug(t *testing.T) {
+	input := readLogFixture(t)
+	encoded, losses, err := LogModule{Level: LevelAggressive}.EncodeWithLoss(input)
+	if err != nil {
+		t.Fatalf("EncodeWithLoss() error = %v", err)
+	}
+	if strings.Contains(encoded, "cache:") {
+		t.Fatalf("EncodeWithLoss() kept debug lines\nIR:\n%s", encoded)
+	}
+	if got := lossCount(losses, "debug"); got != 2 {
+		t.Fatalf("debug loss count = %d, want 2", got)
+	}
+	if !strings.Contains(encoded, "connection refused") {
+		t.Fatalf("EncodeWithLoss() dropped error line\nIR:\n%s", encoded)
+	}
+	balanced, _ := LogModule{Level: LevelBalanced}.Encode(input)
+	if len(encoded) >= len(balanced) {
+		t.Fatalf("aggressive length = %d, want < balanced length %d", len(encoded), len(balanced))
+	}
+}
+
+func TestLogModule_Detect(t *testing.T) {
+	module := LogModule{}
+	for _, input := range []string{
+		"Analyze this repository, summarize it and suggest improvements.",
+		"first line\nsecond line",
+		"Dear team,\nthe release went fine.\nThanks for the help!\nBest regards",
+	} {
+		if module.Detect(input) {
+			t.Errorf("Detect(%q) = true, want false", input)
+		}
+	}
+}
+
+func TestEngine_ProcessDetailed_ReportsLosses(t *testing.T) {
+	engine := New(WithModule(LogModule{Level: LevelBalanced}))
+	result, err := engine.ProcessDetailed(readLogFixture(t))
+	if err != nil {
+		t.Fatalf("ProcessDetailed() error = %v", err)
+	}
+	if result.Module != "IR-LOG" || len(result.Losses) == 0 {
+		t.Fatalf("ProcessDetailed() module = %q losses = %+v, want IR-LOG with losses", result.Module, result.Losses)
+	}
+}
+
+func lossCount(losses []Loss, kind string) int {
+	for _, loss := range losses {
+		if loss.Kind == kind {
+			return loss.Count
+		}
+	}
+	return 0
+}
@@ -0,0 +1,292 @@
+package iron
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"agentic/internal/ir"
+)
+
+// MetaModule writes the agent's ir.Response, a reply and a control packet,
+// as compact control lines:
+//
+//	@META A:act_now R:low C:.9 T:list_add(list=groceries, item=milk)
+//	I:add milk to the groceries list
+//	M:Added milk.
+//
+// The single-token fields A (action), R (risk), C (confidence), and N:1
+// (need process) and the repeatable T (tool call, in IR-PIPE call syntax)
+// share a line; I (intent), W (when), and M (reply) run to the end of their
+// line, and consecutive M lines form a multi-line reply. DecodeMeta reads
+// the lines strictly, so a model can answer in this form instead of JSON.
+type MetaModule struct{}
+
+const metaHeader = "@META"
+
+// MetaInstructions asks a model to answer in the compact control syntax
+// instead of ir.Response JSON.
+const MetaInstructions = `Reply format: instead of JSON, answer with control lines. The first line is "@META A:<action> R:<risk> C:<confidence 0..1>" (action act_now|schedule|ask|defer, risk none|low|medium|high) followed by " T:tool(arg=value, ...)" for each tool and " N:1" if you need to continue; then "I:<intent>" and, when scheduling, "W:<cron or duration>" on their own lines; then the reply as "M:<line>" lines, at most two and without markdown. Quote values containing ,()=| as JSON strings. Example:
+@META A:act_now R:low C:.9 T:list_add(list=groceries, item=milk)
+I:add milk to groceries
+M:Added milk to groceries.`
+
+func (MetaModule) Name() string {
+	return "IR-META"
+}
+
+// Detect reports whether the input is ir.Response JSON with a packet.
+func (MetaModule) Detect(input string) bool {
+	trimmed := strings.TrimSpace(input)
+	if !strings.HasPrefix(trimmed, "{") || !strings.Contains(trimmed, `"ir"`) {
+		return false
+	}
+	fields, err := toolArgs(json.RawMessage(trimmed))
+	if err != nil {
+		return false
+	}
+	for _, field := range fields {
+		switch field.key {
+		case "reply", "needProcees", "needProcess", "ir":
+		default:
+			return false
+		}
+	}
+	resp, err := decodeMetaJSON(trimmed)
+	return err == nil && resp.IR != nil
+}
+
+// Encode writes ir.Response JSON as control lines.
+func (MetaModule) Encode(input string) (string, error) {
+	resp, err := decodeMetaJSON(input)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalidIR, err)
+	}
+	return EncodeMeta(resp)
+}
+
+// EncodeContext encodes unless ctx is done.
+func (m MetaModule) EncodeContext(ctx context.Context, input string) (string, []Loss, error) {
+	if err := ctx.Err(); err != nil {
+		return "", nil, err
+	}
+	encoded, err := m.Encode(input)
+	return encoded, nil, err
+}
+
+// DecodeContext decodes unless ctx is done.
+func (m MetaModule) DecodeContext(ctx context.Context, ir string) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	return m.Decode(ir)
+}
+
+// Decode reads control lines strictly and returns ir.Response JSON.
+func (MetaModule) Decode(output string) (string, error) {
+	resp, err := DecodeMeta(output)
+	if err != nil {
+		return "", err
+	}
+	data, err := json.Marshal(resp)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+func (MetaModule) Score() float64 {
+	return 0.95
+}
+
+// EncodeMeta writes resp as control lines.
+func EncodeMeta(resp ir.Response) (string, error) {
+	first := []string{metaHeader}
+	var lines []string
+	if p := resp.IR; p != nil {
+		first = append(first, "A:"+p.Action)
+		if p.Risk != "" {
+			first = append(first, "R:"+p.Risk)
+		}
+		if p.Confidence != 0 {
+			first = append(first, "C:"+formatMetaConfidence(p.Confidence))
+		}
+		for _, tool := range p.Tools {
+			call, err := formatToolCall(tool.Name, tool.Args, toolPositional[tool.Name])
+			if err != nil {
+				return "", fmt.Errorf("%w: %v", ErrInvalidIR, err)
+			}
+			first = append(first, "T:"+call)
+		}
+		if strings.ContainsAny(p.Intent+p.When, "\r\n") {
+			return "", fmt.Errorf("%w: intent or when spans lines", ErrInvalidIR)
+		}
+		if p.Intent != "" {
+			lines = append(lines, "I:"+p.Intent)
+		}
+		if p.When != "" {
+			lines = append(lines, "W:"+p.When)
+		}
+	}
+	if resp.NeedProcess {
+		first = append(first, "N:1")
+	}
+	if resp.Reply != "" {
+		for _, line := range strings.Split(resp.Reply, "\n") {
+			lines = append(lines, "M:"+line)
+		}
+	}
+	return strings.Join(append([]string{strings.Join(first, " ")}, lines...), "\n"), nil
+}
+
+// DecodeMeta reads control lines into an ir.Response. Unknown or repeated
+// fields, malformed values, and packets that fail ir.Packet.Validate are
+// errors wrapping ErrInvalidIR.
+func DecodeMeta(text string) (ir.Response, error) {
+	var (
+		resp  ir.Response
+		p     ir.Packet
+		seen  = map[byte]bool{}
+		last  byte
+		reply []string
+	)
+	lines := strings.Split(strings.TrimSpace(text), "\n")
+	first := strings.TrimSpace(lines[0])
+	if first != metaHeader && !strings.HasPrefix(first, metaHeader+" ") {
+		return ir.Response{}, fmt.Errorf("%w: missing %s header", ErrInvalidIR, metaHeader)
+	}
+	lines[0] = strings.TrimPrefix(first, metaHeader)
+	for i, line := range lines {
+		line = strings.TrimSpace(line)
+		for line != "" {
+			if len(line) < 2 || line[1] != ':' {
+				return ir.Response{}, fmt.Errorf("%w: line %d: want KEY:value at %q", ErrInvalidIR, i+1, line)
+			}
+			key, rest := line[0], line[2:]
+			if key != 'T' && key != 'M' && seen[key] {
+				return ir.Response{}, fmt.Errorf("%w: line %d: repeated %c", ErrInvalidIR, i+1, key)
+			}
+			if key == 'M' && seen['M'] && last != 'M' {
+				return ir.Response{}, fmt.Errorf("%w: line %d: reply lines must be consecutive", ErrInvalidIR, i+1)
+			}
+			seen[key], last = true, key
+			value := rest
+			switch key {
+			case 'I', 'W', 'M':
+				line = ""
+			case 'T':
+				end := metaCallEnd(rest)
+				value, line = rest[:end], strings.TrimSpace(rest[end:])
+			default:
+				value, line, _ = strings.Cut(rest, " ")
+				line = strings.TrimSpace(line)
+			}
+			if err := decodeMetaField(key, value, &resp, &p, &reply); err != nil {
+				return ir.Response{}, fmt.Errorf("%w: line %d: %v", ErrInvalidIR, i+1, err)
+			}
+		}
+	}
+	resp.Reply = strings.Join(reply, "\n")
+	if seen['A'] || seen['R'] || seen['C'] || seen['T'] || seen['I'] || seen['W'] {
+		if !seen['A'] {
+			return ir.Response{}, fmt.Errorf("%w: packet has no action", ErrInvalidIR)
+		}
+		if err := p.Validate(); err != nil {
+			return ir.Response{}, fmt.Errorf("%w: %v", ErrInvalidIR, err)
+		}
+		resp.IR = &p
+	}
+	return resp, nil
+}
+
+func decodeMetaField(key byte, value string, resp *ir.Response, p *ir.Packet, reply *[]string) error {
+	switch key {
+	case 'A':
+		p.Action = value
+	case 'R':
+		p.Risk = value
+	case 'C':
+		confidence, err := strconv.ParseFloat(value, 64)
+		if err != nil || confidence < 0 || confidence > 1 {
+			return fmt.Errorf("confidence %q is not a number from 0 to 1", value)
+		}
+		p.Confidence = confidence
+	case 'N':
+		if value != "0" && value != "1" {
+			return fmt.Errorf("N must be 0 or 1, not %q", value)
+		}
+		resp.NeedProcess = value == "1"
+	case 'T':
+		name, args, err := parseToolCall(value, toolPositional[pipeCallName(value)])
+		if err != nil {
+			return err
+		}
+		p.Tools = append(p.Tools, ir.ToolRequest{Name: name, Args: args})
+	case 'I':
+		p.Intent = strings.TrimSpace(value)
+	case 'W':
+		p.When = strings.TrimSpace(value)
+	case 'M':
+		*reply = append(*reply, strings.TrimSpace(value))
+	default:
+		return fmt.Errorf("unknown field %c", key)
+	}
+	return nil
+}
+
+// metaCallEnd returns the length of the tool call at the start of s: its
+// name, and its arguments when a parenthesis follows.
+func metaCallEnd(s string) int {
+	end := 0
+	for end < len(s) && s[end] != ' ' && s[end] != '(' {
+		end++
+	}
+	if end < len(s) && s[end] == '(' {
+		if close := closingIndex(s, end); close >= 0 {
+			return close + 1
+		}
+		return len(s)
+	}
+	return end
+}
+
+// decodeMetaJSON reads ir.Response JSON, rejecting unknown fields.
+func decodeMetaJSON(input string) (ir.Response, error) {
+	var resp ir.Response
+	if err := json.Unmarshal([]byte(input), &resp); err != nil {
+		return resp, err
+	}
+	if resp.IR != nil {
+		dec := json.NewDecoder(bytes.NewReader(metaPacketJSON(input)))
+		dec.DisallowUnknownFields()
+		var p ir.Packet
+		if err := dec.Decode(&p); err != nil {
+			return resp, err
+		}
+		if err := p.Validate(); err != nil {
+			return resp, err
+		}
+	}
+	return resp, nil
+}
+
+// metaPacketJSON returns the "ir" field of ir.Response JSON.
+func metaPacketJSON(input string) []byte {
+	fields, _ := toolArgs(json.RawMessage(strings.TrimSpace(input)))
+	for _, field := range fields {
+		if field.key == "ir" {
+			return field.value
+		}
+	}
+	return []byte("null")
+}
+
+// formatMetaConfidence writes a confidence without its leading zero, as in
+// ".9".
+func formatMetaConfidence(confidence float64) string {
+	text := strconv.FormatFloat(confidence, 'f', -1, 64)
+	return strings.TrimPrefix(text, "0")
+}
@@ -0,0 +1,106 @@
+package iron
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"agentic/internal/ir"
+)
+
+func TestMetaModule_Encode_WritesControlLines(t *testing.T) {
+	input := `{"reply":"Added milk.\nAnything else?","needProcees":true,"ir":{"action":"act_now","intent":"add milk to groceries","risk":"low","tools":[{"name":"list_add","args":{"list":"groceries","item":"milk"}},{"name":"shell_exec","args":{"cmd":"df -h"}}],"confidence":0.9}}`
+	module := MetaModule{}
+	if !module.Detect(input) {
+		t.Fatal("Detect() = false")
+	}
+	got, err := module.Encode(input)
+	if err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+	want := strings.Join([]string{
+		"@META A:act_now R:low C:.9 T:list_add(list=groceries, item=milk) T:shell_exec(df -h) N:1",
+		"I:add milk to groceries",
+		"M:Added milk.",
+		"M:Anything else?",
+	}, "\n")
+	if got != want {
+		t.Fatalf("Encode() =\n%s\nwant:\n%s", got, want)
+	}
+
+	decoded, err := module.Decode(got)
+	if err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	var before, after ir.Response
+	_ = json.Unmarshal([]byte(input), &before)
+	if err := json.Unmarshal([]byte(decoded), &after); err != nil {
+		t.Fatalf("Decode() = %s: %v", decoded, err)
+	}
+	if !reflect.DeepEqual(before, after) {
+		t.Fatalf("Decode() = %+v, want %+v", after, before)
+	}
+}
+
+func TestDecodeMeta_ReadsModelReplies(t *testing.T) {
+	resp, err := DecodeMeta("@META A:schedule R:none C:1 T:schedule(spec=1h, message=\"stretch, drink water\", target=42)\nW:1h\nM:Reminder set.")
+	if err != nil {
+		t.Fatalf("DecodeMeta() error = %v", err)
+	}
+	if resp.Reply != "Reminder set." || resp.IR == nil || resp.IR.Action != ir.ActionSchedule || resp.IR.When != "1h" || resp.IR.Confidence != 1 {
+		t.Fatalf("DecodeMeta() = %+v %+v", resp, resp.IR)
+	}
+	if len(resp.IR.Tools) != 1 || string(resp.IR.Tools[0].Args) != `{"spec":"1h","message":"stretch, drink water","target":42}` {
+		t.Fatalf("DecodeMeta() tools = %+v", resp.IR.Tools)
+	}
+
+	resp, err = DecodeMeta("@META\nM:Hello!")
+	if err != nil || resp.IR != nil || resp.Reply != "Hello!" {
+		t.Fatalf("DecodeMeta() = %+v, %v; want a reply without packet", resp, err)
+	}
+
+	result, err := NewDefault().Decode(context.Background(), "@META A:ask R:none\nM:Which list?")
+	if err != nil || result.Module != "IR-META" || !strings.Contains(result.Output, `"action":"ask"`) {
+		t.Fatalf("Engine.Decode() = %+v, %v", result, err)
+	}
+}
+
+func TestDecodeMeta_RejectsMalformedReplies(t *testing.T) {
+	for _, text := range []string{
+		`{"reply":"hi"}`,
+		"@METAX A:ask",
+		"@META A:launch",
+		"@META A:ask R:extreme",
+		"@META A:ask C:1.5",
+		"@META A:ask C:high",
+		"@META A:ask A:defer",
+		"@META R:low",
+		"@META A:ask X:1",
+		"@META A:ask N:yes",
+		"@META A:ask T:list_add(list=x",
+		"@META A:ask T:list_add(x, y)",
+		"@META A:ask\nM:one\nI:intent\nM:two",
+		"@META A:ask\njust text",
+	} {
+		if _, err := DecodeMeta(text); err == nil {
+			t.Errorf("DecodeMeta(%q) error = nil", text)
+		}
+	}
+}
+
+func TestMetaModule_Detect(t *testing.T) {
+	cases := map[string]bool{
+		`{"reply":"ok","ir":{"action":"ask","risk":"none","confidence":1}}`: true,
+		`{"reply":"ok","ir":null}`:                       false,
+		`{"reply":"ok","ir":{"action":"fly"}}`:           false,
+		`{"reply":"ok","ir":{"action":"ask","extra":1}}`: false,
+		`{"id":1,"ir":{"action":"ask"}}`:                 false,
+	}
+	for input, want := range cases {
+		if got := (MetaModule{}).Detect(input); got != want {
+			t.Errorf("Detect(%s) = %t, want %t", input, got, want)
+		}
+	}
+}
@@ -1,5 +1,10 @@
 package iron
 
+import (
+	"context"
+	"fmt"
+)
+
 // IRModule defines the contract for domain-specific encoding/decoding.
 type IRModule interface {
 	Name() string
@@ -8,3 +13,81 @@ type IRModule interface {
 	Decode(output string) (string, error)
 	Score() float64
 }
+
+// Level controls how much information a lossy module may discard.
+type Level int
+
+const (
+	// LevelLossless keeps everything needed to restore the input exactly.
+	LevelLossless Level = iota
+	// LevelBalanced drops variable values but keeps the shape of the input.
+	LevelBalanced
+	// LevelAggressive also drops or merges routine content.
+	LevelAggressive
+)
+
+func (l Level) String() string {
+	switch l {
+	case LevelLossless:
+		return "lossless"
+	case LevelBalanced:
+		return "balanced"
+	case LevelAggressive:
+		return "aggressive"
+	default:
+		return "unknown"
+	}
+}
+
+// MarshalText encodes the level by name.
+func (l Level) MarshalText() ([]byte, error) {
+	return []byte(l.String()), nil
+}
+
+// UnmarshalText decodes a level name.
+func (l *Level) UnmarshalText(text []byte) error {
+	level, ok := ParseLevel(string(text))
+	if !ok {
+		return fmt.Errorf("unknown level %q", text)
+	}
+	*l = level
+	return nil
+}
+
+// ParseLevel converts a level name back into a Level.
+func ParseLevel(name string) (Level, bool) {
+	for _, level := range []Level{LevelLossless, LevelBalanced, LevelAggressive} {
+		if level.String() == name {
+			return level, true
+		}
+	}
+	return 0, false
+}
+
+// Loss describes information discarded by a lossy encoding.
+type Loss struct {
+	Kind   string `json:"kind"`
+	Count  int    `json:"count"`
+	Detail string `json:"detail"`
+}
+
+// LossReporter is implemented by modules that can describe what an encoding dropped.
+type LossReporter interface {
+	EncodeWithLoss(input string) (string, []Loss, error)
+}
+
+// ContextModule is an IRModule that can be cancelled and reads per-call
+// Hints from its context. The engine prefers these methods when present.
+type ContextModule interface {
+	IRModule
+	EncodeContext(ctx context.Context, input string) (string, []Loss, error)
+	DecodeContext(ctx context.Context, ir string) (string, error)
+}
+
+// ContextDetector is implemented by modules whose Detect and Score can be
+// cancelled, such as those served by another process. The engine prefers
+// these methods when present.
+type ContextDetector interface {
+	DetectContext(ctx context.Context, input string) bool
+	ScoreContext(ctx context.Context) float64
+}
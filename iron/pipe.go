@@ -0,0 +1,256 @@
+package iron
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// PipeModule writes workflows, such as scheduled jobs and multi-tool
+// packets, as a one-line pipeline:
+//
+//	@PIPE{name=disk, cron=0 8 * * *} shell_exec(df -h) |> llm(summarize) |> send(target=42)
+//
+// The input is a JSON object whose "tools" array holds {"name","args"}
+// tool requests. The tools run left to right, "llm(prompt)" feeds their
+// output to the model, and "send(...)" carries the "adapter" and "target"
+// of the result. Any other fields go in the braces of the header. Decode
+// restores the same JSON, so a model can also answer with a pipeline.
+type PipeModule struct {
+	// Positional maps a tool name to the argument written without a key,
+	// as "cmd" in "shell_exec(df -h)". Nil uses the built-in tools'.
+	Positional map[string]string
+}
+
+const (
+	pipeHeader = "@PIPE"
+	pipeSep    = " |> "
+	pipeLLM    = "llm"
+	pipeSend   = "send"
+)
+
+// pipeSendKeys are the top-level fields written as "send" arguments.
+var pipeSendKeys = []string{"adapter", "target"}
+
+func (PipeModule) Name() string {
+	return "IR-PIPE"
+}
+
+// Detect reports whether the input is a JSON workflow with at least two
+// steps: several tools, or tools and a prompt.
+func (m PipeModule) Detect(input string) bool {
+	trimmed := strings.TrimSpace(input)
+	if !strings.HasPrefix(trimmed, "{") || !strings.Contains(trimmed, `"tools"`) {
+		return false
+	}
+	flow, err := parsePipeWorkflow([]byte(trimmed))
+	if err != nil {
+		return false
+	}
+	steps := len(flow.tools)
+	if len(flow.prompt) > 0 {
+		steps++
+	}
+	return steps >= 2
+}
+
+// Encode writes the workflow as a pipeline.
+func (m PipeModule) Encode(input string) (string, error) {
+	flow, err := parsePipeWorkflow([]byte(strings.TrimSpace(input)))
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalidIR, err)
+	}
+	header := pipeHeader
+	if len(flow.fields) > 0 {
+		header += "{" + formatToolArgs(flow.fields) + "}"
+	}
+	var steps []string
+	for _, tool := range flow.tools {
+		step, err := formatToolCall(tool.Name, tool.Args, m.positional(tool.Name))
+		if err != nil {
+			return "", fmt.Errorf("%w: %v", ErrInvalidIR, err)
+		}
+		steps = append(steps, step)
+	}
+	if len(flow.prompt) > 0 {
+		steps = append(steps, pipeLLM+"("+formatToolValue(flow.prompt)+")")
+	}
+	if len(flow.send) > 0 {
+		steps = append(steps, pipeSend+"("+formatToolArgs(flow.send)+")")
+	}
+	return header + " " + strings.Join(steps, pipeSep), nil
+}
+
+// EncodeContext encodes unless ctx is done.
+func (m PipeModule) EncodeContext(ctx context.Context, input string) (string, []Loss, error) {
+	if err := ctx.Err(); err != nil {
+		return "", nil, err
+	}
+	encoded, err := m.Encode(input)
+	return encoded, nil, err
+}
+
+// DecodeContext decodes unless ctx is done.
+func (m PipeModule) DecodeContext(ctx context.Context, ir string) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	return m.Decode(ir)
+}
+
+// Decode restores the workflow JSON. Tools must come before "llm", and
+// "send" last; a bare "send" only marks that the result is delivered.
+func (m PipeModule) Decode(output string) (string, error) {
+	line := strings.TrimSpace(output)
+	if !strings.HasPrefix(line, pipeHeader) {
+		return "", fmt.Errorf("%w: missing %s header", ErrInvalidIR, pipeHeader)
+	}
+	line = line[len(pipeHeader):]
+	var flow pipeWorkflow
+	if strings.HasPrefix(line, "{") {
+		end := closingIndex(line, 0)
+		if end < 0 {
+			return "", fmt.Errorf("%w: unclosed %s header", ErrInvalidIR, pipeHeader)
+		}
+		fields, err := parseToolArgs(line[1:end], "")
+		if err != nil {
+			return "", fmt.Errorf("%w: header: %v", ErrInvalidIR, err)
+		}
+		flow.fields = fields
+		line = line[end+1:]
+	}
+	for i, text := range splitTopLevel(line, "|>") {
+		if strings.TrimSpace(text) == "" {
+			return "", fmt.Errorf("%w: step %d is empty", ErrInvalidIR, i+1)
+		}
+		name, args, err := parseToolCall(text, m.positional(pipeCallName(text)))
+		if err != nil {
+			return "", fmt.Errorf("%w: step %d: %v", ErrInvalidIR, i+1, err)
+		}
+		if flow.sent {
+			return "", fmt.Errorf("%w: step %d follows %s", ErrInvalidIR, i+1, pipeSend)
+		}
+		switch name {
+		case pipeLLM:
+			fields, _ := toolArgs(args)
+			if len(flow.prompt) > 0 || len(fields) != 1 || fields[0].key != "prompt" {
+				return "", fmt.Errorf("%w: step %d: want one llm(prompt)", ErrInvalidIR, i+1)
+			}
+			flow.prompt = fields[0].value
+		case pipeSend:
+			fields, _ := toolArgs(args)
+			for _, field := range fields {
+				if field.key != "adapter" && field.key != "target" {
+					return "", fmt.Errorf("%w: step %d: unknown %s argument %q", ErrInvalidIR, i+1, pipeSend, field.key)
+				}
+			}
+			flow.send, flow.sent = fields, true
+		default:
+			if len(flow.prompt) > 0 {
+				return "", fmt.Errorf("%w: step %d: tool %s follows %s", ErrInvalidIR, i+1, name, pipeLLM)
+			}
+			flow.tools = append(flow.tools, pipeTool{Name: name, Args: args})
+		}
+	}
+	return flow.marshal(), nil
+}
+
+func (PipeModule) Score() float64 {
+	return 0.95
+}
+
+func (m PipeModule) positional(name string) string {
+	switch {
+	case name == pipeLLM:
+		return "prompt"
+	case m.Positional == nil:
+		return toolPositional[name]
+	}
+	return m.Positional[name]
+}
+
+// pipeCallName returns the name of a step, before its arguments.
+func pipeCallName(step string) string {
+	name, _, _ := strings.Cut(strings.TrimSpace(step), "(")
+	return strings.TrimSpace(name)
+}
+
+// pipeTool has the JSON shape of the agent's tool requests.
+type pipeTool struct {
+	Name string          `json:"name"`
+	Args json.RawMessage `json:"args"`
+}
+
+// pipeWorkflow is a workflow object split into its parts, each keeping
+// its document order.
+type pipeWorkflow struct {
+	fields []toolArg
+	tools  []pipeTool
+	prompt json.RawMessage
+	send   []toolArg
+	sent   bool
+}
+
+func parsePipeWorkflow(data []byte) (pipeWorkflow, error) {
+	var flow pipeWorkflow
+	fields, err := toolArgs(data)
+	if err != nil {
+		return flow, err
+	}
+	hasTools := false
+	for _, field := range fields {
+		switch field.key {
+		case "tools":
+			dec := json.NewDecoder(bytes.NewReader(field.value))
+			dec.DisallowUnknownFields()
+			if err := dec.Decode(&flow.tools); err != nil {
+				return flow, fmt.Errorf("tools: %w", err)
+			}
+			for _, tool := range flow.tools {
+				if !toolNameRe.MatchString(tool.Name) || tool.Name == pipeLLM || tool.Name == pipeSend {
+					return flow, fmt.Errorf("invalid tool name %q", tool.Name)
+				}
+				if _, err := toolArgs(tool.Args); err != nil {
+					return flow, fmt.Errorf("tool %s: %w", tool.Name, err)
+				}
+			}
+			hasTools = true
+		case "prompt":
+			var prompt string
+			if err := json.Unmarshal(field.value, &prompt); err != nil {
+				return flow, fmt.Errorf("prompt must be a string")
+			}
+			if prompt != "" {
+				flow.prompt = field.value
+			}
+		case "adapter", "target":
+			flow.send = append(flow.send, field)
+		default:
+			flow.fields = append(flow.fields, field)
+		}
+	}
+	if !hasTools || len(flow.tools) == 0 {
+		return flow, fmt.Errorf("no tools")
+	}
+	return flow, nil
+}
+
+// marshal writes the workflow with its fields first, then tools, prompt,
+// adapter, and target.
+func (f pipeWorkflow) marshal() string {
+	fields := append([]toolArg(nil), f.fields...)
+	fields = append(fields, toolArg{key: "tools", value: marshalToolJSON(f.tools)})
+	if len(f.prompt) > 0 {
+		fields = append(fields, toolArg{key: "prompt", value: f.prompt})
+	}
+	for _, key := range pipeSendKeys {
+		for _, field := range f.send {
+			if field.key == key {
+				fields = append(fields, field)
+			}
+		}
+	}
+	return string(marshalToolArgs(fields))
+}
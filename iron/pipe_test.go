@@ -0,0 +1,134 @@
+package iron
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPipeModule_Encode_WritesPipeline(t *testing.T) {
+	input := `{
+  "name": "disk",
+  "cron": "0 8 * * *",
+  "tools": [
+    {"name": "shell_exec", "args": {"cmd": "df -h"}},
+    {"name": "list_add", "args": {"list": "ops", "item": "check disk, then clean", "priority": 2}},
+    {"name": "notes_show", "args": {}}
+  ],
+  "prompt": "Summarize disk usage",
+  "adapter": "telegram",
+  "target": "42"
+}`
+	module := PipeModule{}
+	if !module.Detect(input) {
+		t.Fatal("Detect() = false")
+	}
+	ir, err := module.Encode(input)
+	if err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+	want := `@PIPE{name=disk, cron=0 8 * * *} shell_exec(df -h) |> list_add(list=ops, item="check disk, then clean", priority=2) |> notes_show |> llm(Summarize disk usage) |> send(adapter=telegram, target="42")`
+	if ir != want {
+		t.Fatalf("Encode() =\n%s\nwant:\n%s", ir, want)
+	}
+
+	decoded, err := module.Decode(ir)
+	if err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	var got, original map[string]any
+	if err := json.Unmarshal([]byte(decoded), &got); err != nil {
+		t.Fatalf("Decode() = %s: %v", decoded, err)
+	}
+	_ = json.Unmarshal([]byte(input), &original)
+	if !reflect.DeepEqual(got, original) {
+		t.Fatalf("Decode() = %s", decoded)
+	}
+}
+
+func TestPipeModule_Decode_ReadsModelPipelines(t *testing.T) {
+	cases := map[string]string{
+		`@PIPE shell_exec(df -h) |> llm(summ) |> send`:                              `{"tools":[{"name":"shell_exec","args":{"cmd":"df -h"}}],"prompt":"summ"}`,
+		`@PIPE http_fetch("https://x.io/?a=1")|>notes_append(content=ok)`:           `{"tools":[{"name":"http_fetch","args":{"url":"https://x.io/?a=1"}},{"name":"notes_append","args":{"content":"ok"}}]}`,
+		`@PIPE{action=act_now, confidence=0.9} code_exec(language=go, args=["-v"])`: `{"action":"act_now","confidence":0.9,"tools":[{"name":"code_exec","args":{"language":"go","args":["-v"]}}]}`,
+	}
+	for ir, want := range cases {
+		got, err := PipeModule{}.Decode(ir)
+		if err != nil {
+			t.Fatalf("Decode(%q) error = %v", ir, err)
+		}
+		if got != want {
+			t.Errorf("Decode(%q) = %s, want %s", ir, got, want)
+		}
+	}
+}
+
+func TestPipeModule_Decode_RejectsMalformedPipelines(t *testing.T) {
+	for _, ir := range []string{
+		`@DATA x`,
+		`@PIPE llm(a) |> shell_exec(ls)`,
+		`@PIPE send |> shell_exec(ls)`,
+		`@PIPE shell_exec(ls |> llm(a)`,
+		`@PIPE list_add(ops, milk)`,
+		`@PIPE shell_exec(ls) |> |> llm(a)`,
+		`@PIPE shell_exec(ls) |> send(channel=x)`,
+		`@PIPE{name=x shell_exec(ls)`,
+	} {
+		if _, err := (PipeModule{}).Decode(ir); err == nil {
+			t.Errorf("Decode(%q) error = nil", ir)
+		}
+	}
+}
+
+func TestPipeModule_Encode_QuotesAmbiguousValues(t *testing.T) {
+	input := `{"tools":[{"name":"shell_exec","args":{"cmd":"ps aux | grep \"go\"","timeout_sec":30}},{"name":"list_add","args":{"list":"123","item":"true"}}]}`
+	ir, err := PipeModule{}.Encode(input)
+	if err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+	if !strings.Contains(ir, `cmd="ps aux | grep \"go\""`) || !strings.Contains(ir, `list="123", item="true"`) {
+		t.Fatalf("Encode() = %s", ir)
+	}
+	decoded, err := PipeModule{}.Decode(ir)
+	if err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	if decoded != input {
+		t.Fatalf("Decode() = %s, want %s", decoded, input)
+	}
+}
+
+func TestPipeModule_Encode_KeepsNullAndMarkup(t *testing.T) {
+	input := `{"tools":[{"name":"shell_exec","args":{"cmd":"df -h > /tmp/df.txt","timeout_sec":null}},{"name":"list_add","args":{"list":"null","item":"a<b & c"}}]}`
+	ir, err := PipeModule{}.Encode(input)
+	if err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+	if !strings.Contains(ir, `shell_exec(cmd=df -h > /tmp/df.txt, timeout_sec=null)`) || !strings.Contains(ir, `list_add(list="null", item=a<b & c)`) {
+		t.Fatalf("Encode() = %s", ir)
+	}
+	decoded, err := PipeModule{}.Decode(ir)
+	if err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	if decoded != input {
+		t.Fatalf("Decode() = %s, want %s", decoded, input)
+	}
+}
+
+func TestPipeModule_Detect(t *testing.T) {
+	cases := map[string]bool{
+		`{"tools":[{"name":"a"},{"name":"b"}]}`:            true,
+		`{"tools":[{"name":"a","args":{}}],"prompt":"x"}`:  true,
+		`{"tools":[{"name":"a","args":{}}]}`:               false,
+		`{"tools":[{"name":"a","args":[1]}],"prompt":"x"}`: false,
+		`{"tools":"a","prompt":"x"}`:                       false,
+		`{"items":[1,2]}`:                                  false,
+	}
+	for input, want := range cases {
+		if got := (PipeModule{}).Detect(input); got != want {
+			t.Errorf("Detect(%s) = %t, want %t", input, got, want)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package iron
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// ModuleStats aggregates the results produced by one module.
+type ModuleStats struct {
+	Module      string
+	Calls       int
+	CacheHits   int
+	InputTokens int
+	IRTokens    int
+	EncodeTime  time.Duration
+	DecodeTime  time.Duration
+}
+
+// Ratio returns IR tokens divided by input tokens across all calls.
+func (s ModuleStats) Ratio() float64 {
+	if s.InputTokens == 0 {
+		return 0
+	}
+	return float64(s.IRTokens) / float64(s.InputTokens)
+}
+
+// Savings returns the fraction of input tokens saved across all calls.
+func (s ModuleStats) Savings() float64 {
+	if s.InputTokens == 0 {
+		return 0
+	}
+	return 1 - s.Ratio()
+}
+
+func (s *ModuleStats) add(other ModuleStats) {
+	s.Calls += other.Calls
+	s.CacheHits += other.CacheHits
+	s.InputTokens += other.InputTokens
+	s.IRTokens += other.IRTokens
+	s.EncodeTime += other.EncodeTime
+	s.DecodeTime += other.DecodeTime
+}
+
+// Profiler keeps per-module statistics across engine calls. It is safe for
+// concurrent use.
+type Profiler struct {
+	mu    sync.Mutex
+	stats map[string]*ModuleStats
+}
+
+// NewProfiler creates an empty Profiler.
+func NewProfiler() *Profiler {
+	return &Profiler{stats: make(map[string]*ModuleStats)}
+}
+
+// Record adds a result to its module's statistics. Cache hits count tokens
+// but not durations, since nothing was encoded.
+func (p *Profiler) Record(result Result) {
+	entry := ModuleStats{
+		Calls:       1,
+		InputTokens: result.InputTokens,
+		IRTokens:    result.IRTokens,
+	}
+	if result.Cached {
+		entry.CacheHits = 1
+	} else {
+		entry.EncodeTime = result.EncodeDuration
+		entry.DecodeTime = result.DecodeDuration
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	stats, ok := p.stats[result.Module]
+	if !ok {
+		stats = &ModuleStats{Module: result.Module}
+		p.stats[result.Module] = stats
+	}
+	stats.add(entry)
+}
+
+// Stats returns a snapshot of the per-module statistics sorted by module name.
+func (p *Profiler) Stats() []ModuleStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	stats := make([]ModuleStats, 0, len(p.stats))
+	for _, entry := range p.stats {
+		stats = append(stats, *entry)
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		return stats[i].Module < stats[j].Module
+	})
+	return stats
+}
+
+// Total returns the statistics summed over all modules.
+func (p *Profiler) Total() ModuleStats {
+	var total ModuleStats
+	for _, entry := range p.Stats() {
+		total.add(entry)
+	}
+	return total
+}
+
+// Reset clears all statistics.
+func (p *Profiler) Reset() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.stats = make(map[string]*ModuleStats)
+}
@@ -0,0 +1,41 @@
+package iron
+
+import "testing"
+
+func TestProfiler_Record_AggregatesPerModule(t *testing.T) {
+	profiler := NewProfiler()
+	engine := New(
+		WithModule(testModule{name: "mod", score: 0.5, detect: true, encoded: "x"}),
+		WithTokenizer(CharWordTokenizer{}),
+		WithCache(NewMemoryCache()),
+		WithProfiler(profiler),
+	)
+	for _, input := range []string{"alpha beta gamma", "delta epsilon", "alpha beta gamma"} {
+		if _, err := engine.ProcessDetailed(input); err != nil {
+			t.Fatalf("ProcessDetailed() error = %v", err)
+		}
+	}
+
+	stats := profiler.Stats()
+	if len(stats) != 1 || stats[0].Module != "mod" {
+		t.Fatalf("Stats() = %+v, want one entry for mod", stats)
+	}
+	got := stats[0]
+	if got.Calls != 3 || got.CacheHits != 1 {
+		t.Fatalf("Calls = %d, CacheHits = %d, want 3 and 1", got.Calls, got.CacheHits)
+	}
+	if got.InputTokens != 12 || got.IRTokens != 3 {
+		t.Fatalf("tokens = %d/%d, want 12/3", got.InputTokens, got.IRTokens)
+	}
+	if got.Savings() <= 0.7 {
+		t.Fatalf("Savings() = %v, want > 0.7", got.Savings())
+	}
+	if total := profiler.Total(); total.Calls != 3 {
+		t.Fatalf("Total().Calls = %d, want 3", total.Calls)
+	}
+
+	profiler.Reset()
+	if stats := profiler.Stats(); len(stats) != 0 {
+		t.Fatalf("Stats() after Reset = %+v, want empty", stats)
+	}
+}
@@ -0,0 +1,208 @@
+package iron
+
+import (
+	"fmt"
+	"math/big"
+	"regexp"
+	"strings"
+)
+
+// RedactionRule matches one kind of sensitive value.
+type RedactionRule struct {
+	// Kind names the value and its placeholders, such as "email" for <EMAIL_1>.
+	Kind    string
+	Pattern *regexp.Regexp
+	// Valid, if set, rejects matches such as numbers with bad check digits.
+	Valid func(match string) bool
+}
+
+// Redaction records one value replaced by a placeholder.
+type Redaction struct {
+	Kind        string
+	Placeholder string
+	Value       string
+}
+
+// Redactor replaces secrets and personal data with placeholders before
+// encoding, so that IR sent to a model never carries the original values.
+type Redactor struct {
+	Rules []RedactionRule
+}
+
+var (
+	jwtRe    = regexp.MustCompile(`\beyJ[A-Za-z0-9_-]+\.eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+`)
+	apiKeyRe = regexp.MustCompile(`\b(?:sk-[A-Za-z0-9_-]{20,}|gh[pousr]_[A-Za-z0-9]{36,}|github_pat_\w{22,}|glpat-[\w-]{20,}|xox[abprs]-[A-Za-z0-9-]{10,}|AKIA[0-9A-Z]{16}|AIza[0-9A-Za-z_-]{35}|\d{8,10}:AA[A-Za-z0-9_-]{33})`)
+	emailRe  = regexp.MustCompile(`\b[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}\b`)
+	ibanRe   = regexp.MustCompile(`\b[A-Z]{2}\d{2}(?: ?[A-Z0-9]{4}){2,7}(?: ?[A-Z0-9]{1,3})?\b`)
+	cpfRe    = regexp.MustCompile(`\b\d{3}\.?\d{3}\.?\d{3}-?\d{2}\b`)
+	// ipRe takes in a word or a "build"/"version" label right before an
+	// IPv4 address, so that validIP can skip versions such as v1.2.3.4.
+	ipRe = regexp.MustCompile(`\b(?:[A-Za-z]+|(?i:build|version|ver|release|rev)[ :=]+)?(?:(?:25[0-5]|2[0-4]\d|1?\d?\d)\.){3}(?:25[0-5]|2[0-4]\d|1?\d?\d)\b|\b(?:[0-9a-fA-F]{1,4}:){7}[0-9a-fA-F]{1,4}\b`)
+	// phoneRe requires a leading "+", an area code in parentheses, or a
+	// separator, so that bare runs of digits such as order ids are kept.
+	phoneRe = regexp.MustCompile(`\+\d{1,3}[ .-]?\(?\d{2,3}\)?[ .-]?\d{4,5}[ .-]?\d{4}\b|\(\d{2,3}\)[ .-]?\d{4,5}[ .-]?\d{4}\b|\b\d{2,3}(?:[ .-]\d{4,5}[ .-]?|[ .-]?\d{4,5}[ .-])\d{4}\b`)
+	// redactPlaceholderRe matches a placeholder at the start of a string.
+	redactPlaceholderRe = regexp.MustCompile(`^<[A-Z][A-Z_]*_\d+>`)
+)
+
+// DefaultRedactionRules returns rules for API keys, JWTs, emails, IBANs,
+// CPFs, IP addresses, and phone numbers. Earlier rules win on overlap.
+func DefaultRedactionRules() []RedactionRule {
+	return []RedactionRule{
+		{Kind: "jwt", Pattern: jwtRe},
+		{Kind: "api_key", Pattern: apiKeyRe},
+		{Kind: "email", Pattern: emailRe},
+		{Kind: "iban", Pattern: ibanRe, Valid: validIBAN},
+		{Kind: "cpf", Pattern: cpfRe, Valid: validCPF},
+		{Kind: "ip", Pattern: ipRe, Valid: validIP},
+		{Kind: "phone", Pattern: phoneRe},
+	}
+}
+
+// RedactionRulesFor returns the default rules of the given kinds.
+func RedactionRulesFor(kinds ...string) ([]RedactionRule, error) {
+	defaults := DefaultRedactionRules()
+	var rules []RedactionRule
+	for _, kind := range kinds {
+		found := false
+		for _, rule := range defaults {
+			if rule.Kind == kind {
+				rules = append(rules, rule)
+				found = true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown redaction kind %q", kind)
+		}
+	}
+	return rules, nil
+}
+
+// NewRedactor creates a Redactor with the given rules, or the default rules
+// when none are given.
+func NewRedactor(rules ...RedactionRule) *Redactor {
+	if len(rules) == 0 {
+		rules = DefaultRedactionRules()
+	}
+	return &Redactor{Rules: rules}
+}
+
+// Redact replaces every match with a placeholder numbered per kind in order
+// of appearance. Repeated values share a placeholder.
+func (r *Redactor) Redact(input string) (string, []Redaction) {
+	return r.RedactMore(input, nil)
+}
+
+// RedactMore is Redact continuing from earlier redactions, so that several
+// texts sent together share one placeholder mapping. It returns the earlier
+// redactions followed by the new ones.
+func (r *Redactor) RedactMore(input string, redactions []Redaction) (string, []Redaction) {
+	seen := map[string]string{}
+	counts := map[string]int{}
+	for _, redaction := range redactions {
+		seen[redaction.Kind+"\x00"+redaction.Value] = redaction.Placeholder
+		counts[redaction.Kind]++
+	}
+	out := input
+	for _, rule := range r.Rules {
+		if rule.Pattern == nil {
+			continue
+		}
+		out = rule.Pattern.ReplaceAllStringFunc(out, func(match string) string {
+			if rule.Valid != nil && !rule.Valid(match) {
+				return match
+			}
+			key := rule.Kind + "\x00" + match
+			if placeholder, ok := seen[key]; ok {
+				return placeholder
+			}
+			counts[rule.Kind]++
+			placeholder := fmt.Sprintf("<%s_%d>", strings.ToUpper(rule.Kind), counts[rule.Kind])
+			seen[key] = placeholder
+			redactions = append(redactions, Redaction{Kind: rule.Kind, Placeholder: placeholder, Value: match})
+			return placeholder
+		})
+	}
+	return out, redactions
+}
+
+// Restore replaces the placeholders in text with their original values.
+func Restore(text string, redactions []Redaction) string {
+	if len(redactions) == 0 {
+		return text
+	}
+	pairs := make([]string, 0, 2*len(redactions))
+	for _, redaction := range redactions {
+		pairs = append(pairs, redaction.Placeholder, redaction.Value)
+	}
+	return strings.NewReplacer(pairs...).Replace(text)
+}
+
+// restoreResult puts the original values back into the input and output,
+// leaving the IR redacted.
+func restoreResult(result Result, redactions []Redaction) Result {
+	if len(redactions) == 0 {
+		return result
+	}
+	result.Input = Restore(result.Input, redactions)
+	result.Output = Restore(result.Output, redactions)
+	result.Redactions = redactions
+	return result
+}
+
+// validIBAN checks the ISO 13616 mod-97 checksum.
+func validIBAN(match string) bool {
+	iban := strings.ReplaceAll(match, " ", "")
+	if len(iban) < 15 || len(iban) > 34 {
+		return false
+	}
+	var digits strings.Builder
+	for _, r := range iban[4:] + iban[:4] {
+		switch {
+		case r >= '0' && r <= '9':
+			digits.WriteRune(r)
+		case r >= 'A' && r <= 'Z':
+			fmt.Fprintf(&digits, "%d", r-'A'+10)
+		default:
+			return false
+		}
+	}
+	n, ok := new(big.Int).SetString(digits.String(), 10)
+	return ok && new(big.Int).Mod(n, big.NewInt(97)).Int64() == 1
+}
+
+// validCPF checks both Brazilian CPF check digits.
+func validCPF(match string) bool {
+	var digits []int
+	for _, r := range match {
+		if r >= '0' && r <= '9' {
+			digits = append(digits, int(r-'0'))
+		}
+	}
+	if len(digits) != 11 {
+		return false
+	}
+	same := true
+	for _, d := range digits[1:] {
+		same = same && d == digits[0]
+	}
+	if same {
+		return false
+	}
+	for check := 9; check <= 10; check++ {
+		sum := 0
+		for i := 0; i < check; i++ {
+			sum += digits[i] * (check + 1 - i)
+		}
+		if (sum*10)%11%10 != digits[check] {
+			return false
+		}
+	}
+	return true
+}
+
+// validIP rejects IPv4 matches that ipRe took with a word or version label
+// in front, such as "v1.2.3.4" and "build 1.2.3.4".
+func validIP(match string) bool {
+	return !strings.Contains(match, ".") || match[0] >= '0' && match[0] <= '9'
+}
@@ -1,5 +1,7 @@
 package iron
 
+import "time"
+
 // Result captures the encoded and decoded representations.
 type Result struct {
 	Module string
@@ -8,4 +10,54 @@ type Result struct {
 	Output string
 	Score  float64
 	Cached bool
+	// Approximate marks a cache hit for a similar, not identical, input;
+	// Similarity is the estimated similarity of the two inputs.
+	Approximate bool
+	Similarity  float64
+	Losses      []Loss
+	// Confidence is the chosen module's confidence for this input; Ties
+	// lists the modules that ranked equal to it.
+	Confidence float64
+	Ties       []string
+	// Rejected lists higher-ranked modules whose output failed validation.
+	Rejected []Rejection
+	// Escalations lists the lossier attempts made to meet a token budget,
+	// ending with the one returned when it fits. OverBudget marks a
+	// BestEffort result that still exceeds the budget.
+	Escalations []Escalation
+	OverBudget  bool
+	// Segments lists the module used for each region of a segmented input.
+	Segments []SegmentResult
+	// Redactions lists the values replaced by placeholders in the IR.
+	Redactions []Redaction
+
+	// InputTokens and IRTokens are counted by the engine's Tokenizer.
+	InputTokens int
+	IRTokens    int
+	// Ratio is IRTokens divided by InputTokens.
+	Ratio          float64
+	EncodeDuration time.Duration
+	DecodeDuration time.Duration
+}
+
+// Rejection records why a module's output was not used.
+type Rejection struct {
+	Module    string `json:"module"`
+	Validator string `json:"validator"`
+	Reason    string `json:"reason"`
+}
+
+// Escalation is one lossier encoding tried to meet a token budget.
+type Escalation struct {
+	Module   string `json:"module"`
+	Level    Level  `json:"level"`
+	IRTokens int    `json:"ir_tokens"`
+}
+
+// Savings returns the fraction of input tokens saved by the IR.
+func (r Result) Savings() float64 {
+	if r.InputTokens == 0 {
+		return 0
+	}
+	return 1 - r.Ratio
 }
@@ -0,0 +1,254 @@
+package iron
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Segment kinds produced by SegmentInput.
+const (
+	SegmentProse = "prose"
+	SegmentCode  = "code"
+	SegmentJSON  = "json"
+	SegmentLog   = "log"
+)
+
+const (
+	segmentModule = "IR-SEG"
+	segmentHeader = "@SEG"
+	// segmentRaw marks a region kept as plain text.
+	segmentRaw = "-"
+	// minLogSegment matches the line minimum of LogModule.Detect.
+	minLogSegment = 3
+)
+
+var (
+	segmentHeaderRe = regexp.MustCompile(`^@SEG\[(\w+),([^,\]]+),(\d+)\]$`)
+	// segmentLogPrefixRe matches a "service | " prefix, as docker compose
+	// writes, and segmentLogLevelRe a level at the start of a line.
+	segmentLogPrefixRe = regexp.MustCompile(`^\S+\s+\|\s+`)
+	segmentLogLevelRe  = regexp.MustCompile(`(?i)^\[?(?:INFO|WARN|WARNING|ERROR|ERR|DEBUG|DBG|TRACE|FATAL|PANIC|CRIT|NOTICE)\b|^level=\w+`)
+)
+
+// segmentDomains ranks the module matching a region's kind first.
+var segmentDomains = map[string]string{
+	SegmentCode: "code",
+	SegmentJSON: "data",
+	SegmentLog:  "log",
+}
+
+// Segment is a typed, line-aligned region of an input.
+type Segment struct {
+	Kind string
+	Text string
+}
+
+// SegmentResult reports how one region of a segmented input was encoded.
+// Module is empty when the region was kept as plain text.
+type SegmentResult struct {
+	Kind        string `json:"kind"`
+	Module      string `json:"module,omitempty"`
+	InputTokens int    `json:"input_tokens"`
+	IRTokens    int    `json:"ir_tokens"`
+}
+
+// SegmentInput splits input into the bodies of fenced code blocks, JSON
+// objects and arrays, runs of at least three log lines, and prose for
+// everything else, including the fences themselves. Joining the segment
+// texts with newlines restores the input.
+func SegmentInput(input string) []Segment {
+	lines := strings.Split(input, "\n")
+	var (
+		segments []Segment
+		prose    []string
+	)
+	emit := func(kind string, body []string) {
+		if len(prose) > 0 {
+			segments = append(segments, Segment{Kind: SegmentProse, Text: strings.Join(prose, "\n")})
+			prose = nil
+		}
+		if len(body) > 0 {
+			segments = append(segments, Segment{Kind: kind, Text: strings.Join(body, "\n")})
+		}
+	}
+	for i := 0; i < len(lines); {
+		if end := closingFence(lines, i); end > i+1 {
+			prose = append(prose, lines[i])
+			emit(SegmentCode, lines[i+1:end])
+			prose = append(prose, lines[end])
+			i = end + 1
+			continue
+		}
+		if n := jsonSegmentLines(lines[i:]); n > 0 {
+			emit(SegmentJSON, lines[i:i+n])
+			i += n
+			continue
+		}
+		if n := logSegmentLines(lines[i:]); n >= minLogSegment {
+			emit(SegmentLog, lines[i:i+n])
+			i += n
+			continue
+		}
+		prose = append(prose, lines[i])
+		i++
+	}
+	emit(SegmentProse, nil)
+	return segments
+}
+
+// closingFence returns the index of the fence closing the one at lines[i],
+// or -1 when lines[i] does not open a fenced block.
+func closingFence(lines []string, i int) int {
+	if !strings.HasPrefix(strings.TrimSpace(lines[i]), "```") {
+		return -1
+	}
+	for j := i + 1; j < len(lines); j++ {
+		if strings.TrimSpace(lines[j]) == "```" {
+			return j
+		}
+	}
+	return -1
+}
+
+// jsonSegmentLines returns how many lines the JSON object or array starting
+// at lines[0] spans, or 0 when none ends cleanly at a line break.
+func jsonSegmentLines(lines []string) int {
+	trimmed := strings.TrimSpace(lines[0])
+	if !strings.HasPrefix(trimmed, "{") && !strings.HasPrefix(trimmed, "[") {
+		return 0
+	}
+	text := strings.Join(lines, "\n")
+	decoder := json.NewDecoder(strings.NewReader(text))
+	var value json.RawMessage
+	if err := decoder.Decode(&value); err != nil {
+		return 0
+	}
+	end := int(decoder.InputOffset())
+	rest, _, _ := strings.Cut(text[end:], "\n")
+	if strings.TrimSpace(rest) != "" {
+		return 0
+	}
+	return strings.Count(text[:end], "\n") + 1
+}
+
+// logSegmentLines counts the leading lines that start with a log level or
+// timestamp, so that prose merely mentioning "error" is not a log.
+func logSegmentLines(lines []string) int {
+	for i, line := range lines {
+		if !isLogLineStart(line) {
+			return i
+		}
+	}
+	return len(lines)
+}
+
+func isLogLineStart(line string) bool {
+	line = strings.TrimSpace(line)
+	line = line[len(segmentLogPrefixRe.FindString(line)):]
+	if line == "" {
+		return false
+	}
+	if segmentLogLevelRe.MatchString(line) {
+		return true
+	}
+	template, _ := templateLogLine(line)
+	return strings.HasPrefix(strings.TrimPrefix(template, "["), "<ts>")
+}
+
+// processSegments encodes each region with its best module and joins them
+// under "@SEG[kind,module,lines]" markers. Regions that no module shrinks
+// are kept as plain text; it reports false when that is every region, as
+// the markers would then only add to the input.
+func (e *Engine) processSegments(ctx context.Context, input string, segments []Segment, call callOptions) (Result, []Rejection, bool, error) {
+	var (
+		parts    []string
+		outputs  []string
+		rejected []Rejection
+		combined = Result{Module: segmentModule, Input: input}
+		scores   float64
+		encoded  int
+	)
+	for _, segment := range segments {
+		segmentCall := callOptions{hints: call.hints}
+		if segmentCall.hints.Domain == "" {
+			segmentCall.hints.Domain = segmentDomains[segment.Kind]
+		}
+		modules, _, err := e.candidates(ctx, segment.Text, segmentCall)
+		if err != nil {
+			return Result{}, nil, false, err
+		}
+		result, segmentRejected, err := e.selectModule(ctx, modules, segment.Text)
+		if err != nil {
+			return Result{}, nil, false, err
+		}
+		rejected = append(rejected, segmentRejected...)
+
+		info := SegmentResult{Kind: segment.Kind, Module: result.Module, InputTokens: result.InputTokens, IRTokens: result.IRTokens}
+		name, ir, output := result.Module, result.IR, result.Output
+		if name == "" || name == (PassthroughModule{}).Name() || result.IRTokens >= result.InputTokens {
+			info.Module, info.IRTokens = "", info.InputTokens
+			name, ir, output = segmentRaw, segment.Text, segment.Text
+		} else {
+			combined.Losses = append(combined.Losses, result.Losses...)
+			scores += result.Score
+			encoded++
+		}
+		parts = append(parts, fmt.Sprintf("%s[%s,%s,%d]\n%s", segmentHeader, segment.Kind, name, strings.Count(ir, "\n")+1, ir))
+		outputs = append(outputs, output)
+		combined.Segments = append(combined.Segments, info)
+		combined.EncodeDuration += result.EncodeDuration
+		combined.DecodeDuration += result.DecodeDuration
+	}
+	if encoded == 0 {
+		return Result{}, nil, false, nil
+	}
+	combined.IR = strings.Join(parts, "\n")
+	combined.Output = strings.Join(outputs, "\n")
+	combined.Score = scores / float64(len(segments))
+	e.countTokens(&combined, combined.IR)
+	return combined, rejected, true, nil
+}
+
+// decodeSegments decodes each region of a segmented IR with the module
+// named in its marker.
+func (e *Engine) decodeSegments(ctx context.Context, ir string) (Result, error) {
+	start := time.Now()
+	lines := strings.Split(ir, "\n")
+	result := Result{Module: segmentModule, IR: ir}
+	var outputs []string
+	for i := 0; i < len(lines); {
+		match := segmentHeaderRe.FindStringSubmatch(lines[i])
+		if match == nil {
+			return Result{}, fmt.Errorf("%w: line %d: expected %s marker", ErrInvalidIR, i+1, segmentHeader)
+		}
+		kind, name := match[1], match[2]
+		count, err := strconv.Atoi(match[3])
+		if err != nil || count < 1 || i+1+count > len(lines) {
+			return Result{}, fmt.Errorf("%w: line %d: bad segment length %s", ErrInvalidIR, i+1, match[3])
+		}
+		body := strings.Join(lines[i+1:i+1+count], "\n")
+		i += 1 + count
+
+		info := SegmentResult{Kind: kind}
+		if name != segmentRaw {
+			module, ok := e.module(name)
+			if !ok {
+				return Result{}, fmt.Errorf("%w: segment module %s", ErrUnknownModule, name)
+			}
+			if body, err = decodeModule(ctx, module, body); err != nil {
+				return Result{}, err
+			}
+			info.Module = name
+		}
+		outputs = append(outputs, body)
+		result.Segments = append(result.Segments, info)
+	}
+	result.Output = strings.Join(outputs, "\n")
+	result.DecodeDuration = time.Since(start)
+	return result, nil
+}
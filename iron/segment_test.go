@@ -0,0 +1,121 @@
+package iron
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func mixedInput() string {
+	var logs []string
+	for i := 0; i < 20; i++ {
+		logs = append(logs, fmt.Sprintf("2024-05-01T10:00:%02dZ INFO request served path=/api/jobs status=200 took=%dms", i, 100+i))
+	}
+	return strings.Join([]string{
+		"Why does the job fail? Config below.",
+		`{"retries": 3, "timeout": "5s", "hosts": ["db1", "db2"]}`,
+		"The log says:",
+		strings.Join(logs, "\n"),
+		"And the handler:",
+		"```go",
+		"// handle runs the job and retries until it succeeds.",
+		"// It never gives up, which is the bug.",
+		"func handle() error {",
+		"\tfor {",
+		"\t\t// retry forever",
+		"\t\tif err := run(); err == nil {",
+		"\t\t\treturn nil",
+		"\t\t}",
+		"\t}",
+		"}",
+		"```",
+	}, "\n")
+}
+
+func TestSegmentInput_Kinds(t *testing.T) {
+	segments := SegmentInput(mixedInput())
+	var kinds []string
+	var texts []string
+	for _, segment := range segments {
+		kinds = append(kinds, segment.Kind)
+		texts = append(texts, segment.Text)
+	}
+	want := "prose json prose log prose code prose"
+	if got := strings.Join(kinds, " "); got != want {
+		t.Fatalf("SegmentInput() kinds = %q, want %q", got, want)
+	}
+	if strings.Join(texts, "\n") != mixedInput() {
+		t.Fatal("SegmentInput() texts do not rejoin to the input")
+	}
+	if !strings.HasPrefix(segments[5].Text, "// handle runs") || !strings.HasSuffix(segments[5].Text, "\t}\n}") {
+		t.Fatalf("SegmentInput() code = %q", segments[5].Text)
+	}
+}
+
+func TestSegmentInput_MultiLineJSONAndUnclosedFence(t *testing.T) {
+	input := "see:\n{\n  \"a\": 1\n} trailing\n[1, 2]\n```\nnot closed"
+	segments := SegmentInput(input)
+	if len(segments) != 3 || segments[1].Kind != SegmentJSON || segments[1].Text != "[1, 2]" {
+		t.Fatalf("SegmentInput() = %+v", segments)
+	}
+}
+
+func TestEngine_ProcessContext_Segmentation(t *testing.T) {
+	engine := NewDefault(WithSegmentation())
+	input := mixedInput()
+	result, err := engine.ProcessContext(context.Background(), input)
+	if err != nil {
+		t.Fatalf("ProcessContext() error = %v", err)
+	}
+	if result.Module != segmentModule || !strings.HasPrefix(result.IR, "@SEG[prose,-,1]\n") {
+		t.Fatalf("ProcessContext() module = %q, IR:\n%s", result.Module, result.IR)
+	}
+	modules := map[string]string{}
+	for _, segment := range result.Segments {
+		if segment.Module != "" {
+			modules[segment.Kind] = segment.Module
+		}
+	}
+	if modules[SegmentJSON] != "IR-DATA" || modules[SegmentLog] != "IR-LOG" || modules[SegmentCode] != "IR-CODE" {
+		t.Fatalf("ProcessContext() segments = %+v", result.Segments)
+	}
+
+	decoded, err := engine.Decode(context.Background(), result.IR)
+	if err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	if decoded.Output != result.Output || len(decoded.Segments) != len(result.Segments) {
+		t.Fatalf("Decode() = %q, want %q", decoded.Output, result.Output)
+	}
+}
+
+func TestEngine_Decode_SegmentErrors(t *testing.T) {
+	engine := NewDefault()
+	for _, ir := range []string{"@SEG[prose,-,3]\nshort", "@SEG[log,IR-NOPE,1]\nx", "@SEG[prose,-,1]\nok\nstray"} {
+		if _, err := engine.Decode(context.Background(), ir); err == nil {
+			t.Fatalf("Decode(%q) error = nil", ir)
+		}
+	}
+}
+
+func TestSegmentInput_ProseMentioningLevels(t *testing.T) {
+	input := "The build printed an error at the end.\nI could not find more info in the docs.\nPlease notice the warning above.\n" +
+		"web-1 | 2024-05-01T10:00:00Z started\n[warn] disk almost full\nlevel=info msg=ready"
+	segments := SegmentInput(input)
+	if len(segments) != 2 || segments[0].Kind != SegmentProse || segments[1].Kind != SegmentLog {
+		t.Fatalf("SegmentInput() = %+v, want prose then log", segments)
+	}
+}
+
+func TestEngine_ProcessContext_SegmentsFallBackWhenNoneEncoded(t *testing.T) {
+	engine := NewDefault(WithSegmentation())
+	input := "Is this config right?\n{\"a\": 1}\nThanks."
+	result, err := engine.ProcessContext(context.Background(), input)
+	if err != nil {
+		t.Fatalf("ProcessContext() error = %v", err)
+	}
+	if result.Module == segmentModule || strings.Contains(result.IR, segmentHeader) || result.IRTokens > result.InputTokens {
+		t.Fatalf("ProcessContext() module = %q, IR:\n%s", result.Module, result.IR)
+	}
+}
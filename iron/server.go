@@ -0,0 +1,293 @@
+package iron
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+)
+
+const (
+	defaultMaxRequestBytes = 1 << 20
+	defaultMaxBatch        = 64
+)
+
+// Server exposes an Engine over HTTP:
+//
+//	POST /v1/encode      input -> IR
+//	POST /v1/process     input -> IR and decoded output
+//	POST /v1/decode      IR -> output
+//	GET  /v1/modules     registered modules and their scores
+//	GET  /v1/cache/stats cache statistics, when the cache reports them
+//
+// POST bodies carry either "input" or a batch of "inputs"; each batch item
+// succeeds or fails on its own.
+type Server struct {
+	Engine *Engine
+	// MaxRequestBytes limits request bodies; zero means 1 MiB.
+	MaxRequestBytes int64
+	// MaxBatch limits the inputs of one request; zero means 64.
+	MaxBatch int
+}
+
+type serverRequest struct {
+	Input   *string       `json:"input"`
+	Inputs  []string      `json:"inputs"`
+	Options serverOptions `json:"options"`
+}
+
+type serverOptions struct {
+	Level      string `json:"level"`
+	Module     string `json:"module"`
+	Budget     int    `json:"budget"`
+	BestEffort bool   `json:"best_effort"`
+	SkipCache  bool   `json:"skip_cache"`
+	Domain     string `json:"domain"`
+}
+
+type serverResponse struct {
+	OK      bool           `json:"ok"`
+	Result  *serverResult  `json:"result,omitempty"`
+	Results []serverResult `json:"results,omitempty"`
+	Error   string         `json:"error,omitempty"`
+}
+
+// serverResult is the wire form of a Result. Input and redacted values are
+// left out so that secrets do not echo back.
+type serverResult struct {
+	Module      string          `json:"module,omitempty"`
+	IR          string          `json:"ir,omitempty"`
+	Output      string          `json:"output,omitempty"`
+	Score       float64         `json:"score"`
+	Confidence  float64         `json:"confidence,omitempty"`
+	Ties        []string        `json:"ties,omitempty"`
+	Cached      bool            `json:"cached,omitempty"`
+	Approximate bool            `json:"approximate,omitempty"`
+	Similarity  float64         `json:"similarity,omitempty"`
+	InputTokens int             `json:"input_tokens"`
+	IRTokens    int             `json:"ir_tokens"`
+	Ratio       float64         `json:"ratio"`
+	Savings     float64         `json:"savings"`
+	Encode      time.Duration   `json:"encode_ns"`
+	Decode      time.Duration   `json:"decode_ns"`
+	Losses      []Loss          `json:"losses,omitempty"`
+	Rejected    []Rejection     `json:"rejected,omitempty"`
+	Escalations []Escalation    `json:"escalations,omitempty"`
+	OverBudget  bool            `json:"over_budget,omitempty"`
+	Segments    []SegmentResult `json:"segments,omitempty"`
+	Redacted    []string        `json:"redacted,omitempty"`
+	Error       string          `json:"error,omitempty"`
+}
+
+type serverModule struct {
+	Name  string  `json:"name"`
+	Score float64 `json:"score"`
+}
+
+// Routes returns the handler serving the /v1 endpoints.
+func (s *Server) Routes() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/encode", s.handleRun(s.encode))
+	mux.HandleFunc("/v1/process", s.handleRun(s.process))
+	mux.HandleFunc("/v1/decode", s.handleRun(s.decode))
+	mux.HandleFunc("/v1/modules", s.handleModules)
+	mux.HandleFunc("/v1/cache/stats", s.handleCacheStats)
+	return mux
+}
+
+func (s *Server) handleModules(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	modules := []serverModule{}
+	for _, module := range s.Engine.Modules() {
+		modules = append(modules, serverModule{Name: module.Name(), Score: scoreModule(r.Context(), module)})
+	}
+	_ = json.NewEncoder(w).Encode(map[string][]serverModule{"modules": modules})
+}
+
+func (s *Server) handleCacheStats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	stats, ok := s.Engine.CacheStats()
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		_ = json.NewEncoder(w).Encode(serverResponse{Error: "cache does not report stats"})
+		return
+	}
+	_ = json.NewEncoder(w).Encode(stats)
+}
+
+type serverOp func(ctx context.Context, input string, options []CallOption) (serverResult, error)
+
+func (s *Server) handleRun(op serverOp) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, s.maxRequestBytes())
+		var req serverRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			status := http.StatusBadRequest
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				status = http.StatusRequestEntityTooLarge
+			}
+			writeServerError(w, status, err.Error())
+			return
+		}
+		options, err := req.Options.callOptions()
+		if err != nil {
+			writeServerError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		switch {
+		case req.Input != nil && req.Inputs != nil:
+			writeServerError(w, http.StatusBadRequest, "set either input or inputs")
+		case req.Input != nil:
+			result, err := op(r.Context(), *req.Input, options)
+			if err != nil {
+				writeServerError(w, serverStatus(err), err.Error())
+				return
+			}
+			_ = json.NewEncoder(w).Encode(serverResponse{OK: true, Result: &result})
+		case len(req.Inputs) > s.maxBatch():
+			writeServerError(w, http.StatusRequestEntityTooLarge, "batch too large")
+		case len(req.Inputs) > 0:
+			resp := serverResponse{OK: true, Results: make([]serverResult, len(req.Inputs))}
+			for i, input := range req.Inputs {
+				result, err := op(r.Context(), input, options)
+				if err != nil {
+					result = serverResult{Error: err.Error()}
+					resp.OK = false
+				}
+				resp.Results[i] = result
+			}
+			_ = json.NewEncoder(w).Encode(resp)
+		default:
+			writeServerError(w, http.StatusBadRequest, "missing input")
+		}
+	}
+}
+
+func (s *Server) encode(ctx context.Context, input string, options []CallOption) (serverResult, error) {
+	result, err := s.Engine.ProcessContext(ctx, input, options...)
+	if err != nil {
+		return serverResult{}, err
+	}
+	out := newServerResult(result)
+	out.Output = ""
+	return out, nil
+}
+
+func (s *Server) process(ctx context.Context, input string, options []CallOption) (serverResult, error) {
+	result, err := s.Engine.ProcessContext(ctx, input, options...)
+	if err != nil {
+		return serverResult{}, err
+	}
+	return newServerResult(result), nil
+}
+
+func (s *Server) decode(ctx context.Context, ir string, options []CallOption) (serverResult, error) {
+	result, err := s.Engine.Decode(ctx, ir, options...)
+	if err != nil {
+		return serverResult{}, err
+	}
+	out := newServerResult(result)
+	out.IR = ""
+	return out, nil
+}
+
+func (o serverOptions) callOptions() ([]CallOption, error) {
+	var call []CallOption
+	if o.Level != "" {
+		var level Level
+		if err := level.UnmarshalText([]byte(o.Level)); err != nil {
+			return nil, err
+		}
+		call = append(call, AtLevel(level))
+	}
+	if o.Module != "" {
+		call = append(call, ForceModule(o.Module))
+	}
+	if o.Budget > 0 {
+		call = append(call, TokenBudget(o.Budget))
+	}
+	if o.BestEffort {
+		call = append(call, BestEffort())
+	}
+	if o.SkipCache {
+		call = append(call, SkipCache())
+	}
+	if o.Domain != "" {
+		call = append(call, DomainHint(o.Domain))
+	}
+	return call, nil
+}
+
+func newServerResult(result Result) serverResult {
+	out := serverResult{
+		Module:      result.Module,
+		IR:          result.IR,
+		Output:      result.Output,
+		Score:       result.Score,
+		Confidence:  result.Confidence,
+		Ties:        result.Ties,
+		Cached:      result.Cached,
+		Approximate: result.Approximate,
+		Similarity:  result.Similarity,
+		InputTokens: result.InputTokens,
+		IRTokens:    result.IRTokens,
+		Ratio:       result.Ratio,
+		Savings:     result.Savings(),
+		Encode:      result.EncodeDuration,
+		Decode:      result.DecodeDuration,
+		Losses:      result.Losses,
+		Rejected:    result.Rejected,
+		Escalations: result.Escalations,
+		OverBudget:  result.OverBudget,
+		Segments:    result.Segments,
+	}
+	for _, redaction := range result.Redactions {
+		out.Redacted = append(out.Redacted, redaction.Kind)
+	}
+	return out
+}
+
+// serverStatus maps engine errors to HTTP status codes.
+func serverStatus(err error) int {
+	switch {
+	case errors.Is(err, ErrUnknownModule):
+		return http.StatusBadRequest
+	case errors.Is(err, ErrInvalidIR), errors.Is(err, ErrTokenBudget), errors.Is(err, ErrValidation):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func writeServerError(w http.ResponseWriter, status int, message string) {
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(serverResponse{Error: message})
+}
+
+func (s *Server) maxRequestBytes() int64 {
+	if s.MaxRequestBytes > 0 {
+		return s.MaxRequestBytes
+	}
+	return defaultMaxRequestBytes
+}
+
+func (s *Server) maxBatch() int {
+	if s.MaxBatch > 0 {
+		return s.MaxBatch
+	}
+	return defaultMaxBatch
+}
@@ -0,0 +1,131 @@
+package iron
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func serve(t *testing.T, server *Server, method, path, body string) (*httptest.ResponseRecorder, serverResponse) {
+	t.Helper()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	rec := httptest.NewRecorder()
+	server.Routes().ServeHTTP(rec, req)
+	var resp serverResponse
+	if rec.Code != http.StatusMethodNotAllowed && strings.HasPrefix(path, "/v1/") && method == http.MethodPost {
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("Unmarshal() error = %v, body %q", err, rec.Body.String())
+		}
+	}
+	return rec, resp
+}
+
+func TestServer_Process_ReturnsResultMetadata(t *testing.T) {
+	server := &Server{Engine: NewDefault()}
+	rec, resp := serve(t, server, http.MethodPost, "/v1/process", `{"input":"Analyze this repository, summarize it and suggest improvements."}`)
+	if rec.Code != http.StatusOK || !resp.OK || resp.Result == nil {
+		t.Fatalf("status = %d, response = %+v", rec.Code, resp)
+	}
+	if resp.Result.Module != "IR-TASK" || !strings.HasPrefix(resp.Result.IR, "@TASK") || resp.Result.Output == "" {
+		t.Fatalf("result = %+v", resp.Result)
+	}
+	if resp.Result.InputTokens == 0 || resp.Result.IRTokens == 0 || resp.Result.Ratio == 0 {
+		t.Fatalf("token stats = %+v", resp.Result)
+	}
+}
+
+func TestServer_Encode_BatchReportsErrorsPerItem(t *testing.T) {
+	server := &Server{Engine: NewDefault()}
+	rec, resp := serve(t, server, http.MethodPost, "/v1/encode", `{"inputs":["Analyze the repo and summarize it.","plain words"],"options":{"module":"IR-TASK"}}`)
+	if rec.Code != http.StatusOK || len(resp.Results) != 2 {
+		t.Fatalf("status = %d, response = %+v", rec.Code, resp)
+	}
+	if resp.OK || resp.Results[0].Error != "" || resp.Results[1].Error == "" {
+		t.Fatalf("results = %+v", resp.Results)
+	}
+	if resp.Results[0].Output != "" {
+		t.Fatalf("encode output = %q, want empty", resp.Results[0].Output)
+	}
+}
+
+func TestServer_Decode_RoundTrips(t *testing.T) {
+	server := &Server{Engine: NewDefault()}
+	_, encoded := serve(t, server, http.MethodPost, "/v1/encode", `{"input":"Analyze this repository and summarize it."}`)
+	body, _ := json.Marshal(serverRequest{Input: &encoded.Result.IR})
+	rec, resp := serve(t, server, http.MethodPost, "/v1/decode", string(body))
+	if rec.Code != http.StatusOK || resp.Result == nil || resp.Result.Output == "" {
+		t.Fatalf("status = %d, response = %+v", rec.Code, resp)
+	}
+
+	rec, _ = serve(t, server, http.MethodPost, "/v1/decode", `{"input":"@NOPE{x}"}`)
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("invalid IR status = %d, want %d", rec.Code, http.StatusUnprocessableEntity)
+	}
+
+	_, passed := serve(t, server, http.MethodPost, "/v1/encode", `{"input":"hi there"}`)
+	if passed.Result == nil || passed.Result.Module != "IR-PASS" {
+		t.Fatalf("encode response = %+v, want IR-PASS", passed)
+	}
+	body, _ = json.Marshal(serverRequest{Input: &passed.Result.IR})
+	rec, resp = serve(t, server, http.MethodPost, "/v1/decode", string(body))
+	if rec.Code != http.StatusOK || resp.Result == nil || resp.Result.Output != "hi there" {
+		t.Fatalf("passthrough decode status = %d, response = %+v", rec.Code, resp)
+	}
+
+	rec, resp = serve(t, server, http.MethodPost, "/v1/decode", `{"input":"@NOPE{x}","options":{"module":"IR-PASS"}}`)
+	if rec.Code != http.StatusOK || resp.Result == nil || resp.Result.Module != "IR-PASS" || resp.Result.Output != "@NOPE{x}" {
+		t.Fatalf("forced decode status = %d, response = %+v", rec.Code, resp)
+	}
+}
+
+func TestServer_Routes_LimitsRequests(t *testing.T) {
+	server := &Server{Engine: NewDefault(), MaxRequestBytes: 32, MaxBatch: 1}
+	rec, _ := serve(t, server, http.MethodPost, "/v1/encode", `{"input":"`+strings.Repeat("x", 64)+`"}`)
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("body status = %d, want %d", rec.Code, http.StatusRequestEntityTooLarge)
+	}
+	rec, _ = serve(t, server, http.MethodPost, "/v1/encode", `{"inputs":["a","b"]}`)
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("batch status = %d, want %d", rec.Code, http.StatusRequestEntityTooLarge)
+	}
+	rec, _ = serve(t, &Server{Engine: NewDefault()}, http.MethodPost, "/v1/encode", `{"input":"x","options":{"module":"IR-NONE"}}`)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("unknown module status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+	rec, _ = serve(t, server, http.MethodGet, "/v1/encode", "")
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("GET status = %d, want %d", rec.Code, http.StatusMethodNotAllowed)
+	}
+}
+
+func TestServer_Modules_ListsModulesAndCacheStats(t *testing.T) {
+	server := &Server{Engine: NewDefault(WithCache(NewLRUCache(8, 0)))}
+	rec, _ := serve(t, server, http.MethodGet, "/v1/modules", "")
+	var modules struct {
+		Modules []serverModule `json:"modules"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &modules); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if len(modules.Modules) != 9 || modules.Modules[1].Name != "IR-TASK" {
+		t.Fatalf("modules = %+v", modules.Modules)
+	}
+
+	serve(t, server, http.MethodPost, "/v1/encode", `{"input":"Analyze the repo and summarize it."}`)
+	serve(t, server, http.MethodPost, "/v1/encode", `{"input":"Analyze the repo and summarize it."}`)
+	rec, _ = serve(t, server, http.MethodGet, "/v1/cache/stats", "")
+	var stats CacheStats
+	if err := json.Unmarshal(rec.Body.Bytes(), &stats); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if stats.Hits != 1 || stats.Entries != 1 {
+		t.Fatalf("stats = %+v", stats)
+	}
+
+	rec, _ = serve(t, &Server{Engine: NewDefault()}, http.MethodGet, "/v1/cache/stats", "")
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("no cache status = %d, want %d", rec.Code, http.StatusNotFound)
+	}
+}
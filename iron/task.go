@@ -0,0 +1,560 @@
+package iron
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"agentic/iron/irfmt"
+)
+
+// TaskModule compiles imperative multi-step instructions (English or
+// Portuguese) into a single @TASK line:
+//
+//	@TASK[list] ANALYZE auth service|FIND where tokens are validated|WRITE tests
+//
+// Each step is an opcode and its object; the arguments carry the output
+// formats, and the language when the objects do not reveal it.
+type TaskModule struct{}
+
+// ErrNoTaskSteps is returned when an input has no recognizable task verbs.
+var ErrNoTaskSteps = errors.New("input contains no task steps")
+
+const (
+	langEN = "en"
+	langPT = "pt"
+
+	taskDirective = "TASK"
+)
+
+type taskVerb struct {
+	op string
+	en []string
+	pt []string
+}
+
+// taskVerbs maps canonical opcodes to the surface forms that introduce a step.
+// The first form of each language is used when decoding.
+var taskVerbs = []taskVerb{
+	{op: "ANALYZE", en: []string{"analyze", "analyse", "examine", "inspect"}, pt: []string{"analise", "analisar", "analisa", "examinar"}},
+	{op: "SUM", en: []string{"summarize", "summarise"}, pt: []string{"resuma", "resumir"}},
+	{op: "SUGG", en: []string{"suggest", "propose", "recommend"}, pt: []string{"sugira", "sugerir", "sugere", "proponha", "recomende"}},
+	{op: "LIST", en: []string{"list", "enumerate"}, pt: []string{"liste", "listar"}},
+	{op: "CREATE", en: []string{"create"}, pt: []string{"crie", "criar"}},
+	{op: "FIX", en: []string{"fix", "repair"}, pt: []string{"corrija", "corrigir", "conserte", "consertar"}},
+	{op: "REVIEW", en: []string{"review"}, pt: []string{"revise", "revisar", "revisa"}},
+	{op: "EXPLAIN", en: []string{"explain", "describe"}, pt: []string{"explique", "explicar", "explica", "descreva", "descrever"}},
+	{op: "WRITE", en: []string{"write", "draft"}, pt: []string{"escreva", "escrever", "redija"}},
+	{op: "REFACTOR", en: []string{"refactor"}, pt: []string{"refatore", "refatorar"}},
+	{op: "TEST", en: []string{"test"}, pt: []string{"teste", "testar"}},
+	{op: "CMP", en: []string{"compare", "contrast"}, pt: []string{"compare", "comparar"}},
+	{op: "TRANSL", en: []string{"translate"}, pt: []string{"traduza", "traduzir"}},
+	{op: "FIND", en: []string{"find", "search", "locate"}, pt: []string{"encontre", "encontrar", "busque", "buscar", "procure", "procurar"}},
+	{op: "GEN", en: []string{"generate", "produce"}, pt: []string{"gere", "gerar", "produza"}},
+	{op: "CHECK", en: []string{"check", "verify", "validate"}, pt: []string{"verifique", "verificar", "valide", "validar"}},
+	{op: "UPD", en: []string{"update", "upgrade"}, pt: []string{"atualize", "atualizar"}},
+	{op: "DEL", en: []string{"delete", "remove"}, pt: []string{"remova", "remover", "apague", "apagar", "exclua", "excluir"}},
+	{op: "IMPROVE", en: []string{"improve"}, pt: []string{"melhore", "melhorar"}},
+	{op: "OPT", en: []string{"optimize", "optimise"}, pt: []string{"otimize", "otimizar"}},
+	{op: "DOC", en: []string{"document"}, pt: []string{"documente", "documentar"}},
+	{op: "RUN", en: []string{"run", "execute"}, pt: []string{"rode", "rodar", "execute", "executar"}},
+	{op: "BUILD", en: []string{"build", "compile"}, pt: []string{"compile", "compilar"}},
+	{op: "INSTALL", en: []string{"install"}, pt: []string{"instale", "instalar"}},
+	{op: "RESTART", en: []string{"restart", "reboot"}, pt: []string{"reinicie", "reiniciar"}},
+	{op: "START", en: []string{"start"}, pt: []string{"inicie", "iniciar"}},
+	{op: "STOP", en: []string{"stop"}, pt: []string{"pare", "parar"}},
+	{op: "DEPLOY", en: []string{"deploy"}, pt: []string{"implante", "implantar"}},
+	{op: "SEND", en: []string{"send"}, pt: []string{"envie", "enviar", "mande", "mandar"}},
+	{op: "SAVE", en: []string{"save", "store"}, pt: []string{"salve", "salvar", "guarde", "guardar"}},
+	{op: "READ", en: []string{"read", "open"}, pt: []string{"leia", "ler", "abra", "abrir"}},
+	{op: "PLAN", en: []string{"plan", "outline"}, pt: []string{"planeje", "planejar"}},
+	{op: "EXTRACT", en: []string{"extract"}, pt: []string{"extraia", "extrair"}},
+	{op: "CLASSIFY", en: []string{"classify", "categorize"}, pt: []string{"classifique", "classificar"}},
+	{op: "MONITOR", en: []string{"monitor"}, pt: []string{"monitore", "monitorar"}},
+	{op: "CLEAN", en: []string{"clean"}, pt: []string{"limpe", "limpar"}},
+	{op: "FORMAT", en: []string{"format"}, pt: []string{"formate", "formatar"}},
+	{op: "CONVERT", en: []string{"convert"}, pt: []string{"converta", "converter"}},
+	{op: "ORGANIZE", en: []string{"organize", "organise"}, pt: []string{"organize", "organizar"}},
+}
+
+type taskOutput struct {
+	tag string
+	en  string
+	pt  string
+	re  *regexp.Regexp
+}
+
+// taskOutputs are output-format modifiers recognized anywhere in a step.
+var taskOutputs = []taskOutput{
+	{tag: "list", en: "as a list", pt: "em lista", re: regexp.MustCompile(`(?i)\s*\b(?:(?:in|as)\s+(?:a\s+)?(?:bullet\s+points|bullets|list)|(?:em|como)\s+(?:uma\s+)?(?:lista|tópicos|topicos))\b`)},
+	{tag: "json", en: "as JSON", pt: "em JSON", re: regexp.MustCompile(`(?i)\s*\b(?:in|as|em|como)\s+json\b`)},
+	{tag: "table", en: "as a table", pt: "em tabela", re: regexp.MustCompile(`(?i)\s*\b(?:(?:in|as)\s+a\s+table|(?:em|como)\s+(?:uma\s+)?tabela)\b`)},
+	{tag: "md", en: "in Markdown", pt: "em Markdown", re: regexp.MustCompile(`(?i)\s*\b(?:in|as|em|como)\s+markdown\b`)},
+	{tag: "short", en: "briefly", pt: "brevemente", re: regexp.MustCompile(`(?i)\s*\b(?:briefly|in\s+short|brevemente|resumidamente|de\s+forma\s+breve)\b`)},
+	{tag: "tech", en: "in technical terms", pt: "em termos técnicos", re: regexp.MustCompile(`(?i)\s*\b(?:in\s+technical\s+terms|technically|em\s+termos\s+t[ée]cnicos|tecnicamente)\b`)},
+}
+
+var (
+	taskVerbIndex    = buildTaskVerbIndex()
+	taskOpIndex      = buildTaskOpIndex()
+	taskSeparatorRe  = regexp.MustCompile(`(?i)\s*(?:[,;\n]|\.(?:\s+|$)|\s+(?:and\s+then|and|then|e\s+depois|depois|em\s+seguida|e\s+então|e)\s+)\s*`)
+	taskListMarkerRe = regexp.MustCompile(`(?m)^\s*(?:\d+[.)]|[-*•])\s+`)
+	taskFillers      = []string{
+		"please", "then", "also", "finally", "first", "next", "and", "can you", "could you",
+		"por favor", "depois", "então", "também", "finalmente", "primeiro", "em seguida", "e", "você pode", "voce pode", "pode",
+	}
+	taskDeterminers = map[string]bool{
+		"the": true, "this": true, "that": true, "these": true, "those": true, "a": true, "an": true,
+		"my": true, "our": true, "your": true, "its": true,
+		"o": true, "os": true, "as": true, "este": true, "esta": true, "esse": true, "essa": true,
+		"estes": true, "estas": true, "esses": true, "essas": true, "meu": true, "minha": true,
+		"nosso": true, "nossa": true, "um": true, "uma": true,
+	}
+	taskPronouns = map[string]bool{
+		"it": true, "them": true, "this": true, "that": true,
+		"isso": true, "isto": true, "ele": true, "ela": true, "eles": true, "elas": true, "tudo": true,
+	}
+	taskLangMarkers = map[string]string{
+		"the": langEN, "of": langEN, "to": langEN, "it": langEN, "this": langEN, "and": langEN,
+		"then": langEN, "please": langEN, "with": langEN, "for": langEN, "my": langEN,
+		"o": langPT, "os": langPT, "um": langPT, "uma": langPT, "de": langPT, "do": langPT, "da": langPT,
+		"dos": langPT, "das": langPT, "em": langPT, "no": langPT, "na": langPT, "para": langPT,
+		"com": langPT, "e": langPT, "isso": langPT, "depois": langPT, "por": langPT, "favor": langPT,
+	}
+)
+
+func buildTaskVerbIndex() map[string]string {
+	index := make(map[string]string)
+	for _, verb := range taskVerbs {
+		for _, form := range verb.en {
+			index[form] = verb.op
+		}
+		for _, form := range verb.pt {
+			index[form] = verb.op
+		}
+	}
+	return index
+}
+
+func buildTaskOpIndex() map[string]taskVerb {
+	index := make(map[string]taskVerb, len(taskVerbs))
+	for _, verb := range taskVerbs {
+		index[verb.op] = verb
+	}
+	return index
+}
+
+type taskStep struct {
+	op     string
+	object string
+}
+
+type taskPlan struct {
+	lang    string
+	steps   []taskStep
+	outputs []string
+}
+
+func (TaskModule) Name() string {
+	return "IR-TASK"
+}
+
+// Detect reports whether the input is an imperative instruction with at least two steps.
+func (TaskModule) Detect(input string) bool {
+	if len(input) > 1200 || strings.Contains(input, "```") {
+		return false
+	}
+	trimmed := strings.TrimSpace(input)
+	if trimmed == "" || strings.ContainsAny(trimmed[:1], "{[<@") {
+		return false
+	}
+	plan, ok := parseTask(trimmed)
+	return ok && len(plan.steps) >= 2
+}
+
+// Encode compiles the instruction into IR.
+func (m TaskModule) Encode(input string) (string, error) {
+	encoded, _, err := m.EncodeWithLoss(input)
+	return encoded, err
+}
+
+// EncodeWithLoss compiles the instruction and reports what Decode cannot
+// restore: list markers, and words such as fillers, determiners, and verb
+// synonyms that the canonical instruction rewrites.
+func (TaskModule) EncodeWithLoss(input string) (string, []Loss, error) {
+	input = strings.TrimSpace(input)
+	plan, ok := parseTask(input)
+	if !ok {
+		return "", nil, ErrNoTaskSteps
+	}
+	var losses []Loss
+	if markers := len(taskListMarkerRe.FindAllString(input, -1)); markers > 0 {
+		losses = append(losses, Loss{Kind: "markers", Count: markers, Detail: "list markers dropped"})
+	}
+	if words := taskRewrittenWords(taskListMarkerRe.ReplaceAllString(input, ""), plan.render()); words > 0 {
+		losses = append(losses, Loss{Kind: "wording", Count: words, Detail: "fillers, determiners and synonyms rewritten"})
+	}
+	return plan.encode(), losses, nil
+}
+
+// Decode expands the IR back into a canonical instruction.
+func (TaskModule) Decode(output string) (string, error) {
+	plan, err := parseTaskIR(output)
+	if err != nil {
+		return "", err
+	}
+	return plan.render(), nil
+}
+
+func (TaskModule) Score() float64 {
+	return 0.8
+}
+
+// Classify returns the default classifier's confidence that input is a task.
+func (TaskModule) Classify(input string) float64 {
+	return DefaultClassifier().Confidence("task", input)
+}
+
+func parseTask(input string) (taskPlan, bool) {
+	text := taskListMarkerRe.ReplaceAllString(input, "")
+	pieces, seps := splitTaskPieces(text)
+
+	var plan taskPlan
+	for i, piece := range pieces {
+		body := stripTaskFillers(piece)
+		if body == "" {
+			continue
+		}
+		op, rest := splitTaskVerb(body)
+		if op != "" {
+			plan.steps = append(plan.steps, taskStep{op: op, object: rest})
+			continue
+		}
+		if len(plan.steps) == 0 {
+			return taskPlan{}, false
+		}
+		last := &plan.steps[len(plan.steps)-1]
+		last.object = strings.TrimSpace(last.object + seps[i] + piece)
+	}
+	if len(plan.steps) == 0 {
+		return taskPlan{}, false
+	}
+
+	for i := range plan.steps {
+		object := plan.steps[i].object
+		for _, out := range taskOutputs {
+			if out.re.MatchString(object) {
+				object = out.re.ReplaceAllString(object, "")
+				plan.outputs = appendUnique(plan.outputs, out.tag)
+			}
+		}
+		plan.steps[i].object = cleanTaskObject(object)
+	}
+	plan.lang = detectTaskLang(input)
+	return plan, true
+}
+
+// splitTaskPieces splits text on step separators, returning each piece and
+// the separator text that preceded it.
+func splitTaskPieces(text string) ([]string, []string) {
+	var (
+		pieces []string
+		seps   []string
+		prev   string
+		start  int
+	)
+	for _, loc := range taskSeparatorRe.FindAllStringIndex(text, -1) {
+		if piece := strings.TrimSpace(text[start:loc[0]]); piece != "" {
+			pieces = append(pieces, piece)
+			seps = append(seps, prev)
+			prev = text[loc[0]:loc[1]]
+		} else if len(pieces) > 0 {
+			prev += text[loc[0]:loc[1]]
+		}
+		start = loc[1]
+	}
+	if piece := strings.TrimSpace(text[start:]); piece != "" {
+		pieces = append(pieces, piece)
+		seps = append(seps, prev)
+	}
+	return pieces, seps
+}
+
+func stripTaskFillers(piece string) string {
+	for {
+		lower := strings.ToLower(piece)
+		stripped := false
+		for _, filler := range taskFillers {
+			if lower == filler {
+				return ""
+			}
+			if strings.HasPrefix(lower, filler+" ") || strings.HasPrefix(lower, filler+",") {
+				piece = strings.TrimLeft(piece[len(filler):], " ,")
+				stripped = true
+				break
+			}
+		}
+		if !stripped {
+			return piece
+		}
+	}
+}
+
+func splitTaskVerb(piece string) (string, string) {
+	word, rest, _ := strings.Cut(piece, " ")
+	word = strings.ToLower(strings.TrimRight(word, ",.:;!"))
+	op, ok := taskVerbIndex[word]
+	if !ok {
+		return "", ""
+	}
+	return op, strings.TrimSpace(rest)
+}
+
+func cleanTaskObject(object string) string {
+	object = strings.Trim(strings.TrimSpace(object), ".,;:!? ")
+	for {
+		word, rest, found := strings.Cut(object, " ")
+		if !found || !taskDeterminers[strings.ToLower(word)] || strings.HasPrefix(rest, "que ") {
+			break
+		}
+		object = strings.TrimSpace(rest)
+	}
+	if taskPronouns[strings.ToLower(object)] {
+		return ""
+	}
+	return strings.Join(strings.Fields(strings.NewReplacer("|", "/", "{", "(", "}", ")").Replace(object)), " ")
+}
+
+// taskRewrittenWords counts the words of input that output lacks.
+func taskRewrittenWords(input, output string) int {
+	kept := map[string]int{}
+	for _, word := range taskWords(output) {
+		kept[word]++
+	}
+	missing := 0
+	for _, word := range taskWords(input) {
+		if kept[word] > 0 {
+			kept[word]--
+		} else {
+			missing++
+		}
+	}
+	return missing
+}
+
+func taskWords(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+func detectTaskLang(input string) string {
+	var en, pt int
+	for _, r := range input {
+		if strings.ContainsRune("ãõçáéíóúâêô", unicode.ToLower(r)) {
+			pt++
+		}
+	}
+	for _, word := range strings.FieldsFunc(strings.ToLower(input), func(r rune) bool {
+		return !unicode.IsLetter(r)
+	}) {
+		switch taskLangMarkers[word] {
+		case langEN:
+			en++
+		case langPT:
+			pt++
+		}
+		if op, ok := taskVerbIndex[word]; ok {
+			verb := taskOpIndex[op]
+			inEN, inPT := containsString(verb.en, word), containsString(verb.pt, word)
+			if inEN && !inPT {
+				en++
+			} else if inPT && !inEN {
+				pt++
+			}
+		}
+	}
+	if pt > en {
+		return langPT
+	}
+	return langEN
+}
+
+// encode writes the plan as a single directive, each step's opcode followed
+// by its object. The language is an argument only when the objects do not
+// reveal it, and output formats follow it.
+func (p taskPlan) encode() string {
+	steps := make([]string, len(p.steps))
+	for i, step := range p.steps {
+		steps[i] = step.op
+		if step.object != "" {
+			steps[i] += " " + step.object
+		}
+	}
+	var args []string
+	if detectTaskLang(p.objectText()) != p.lang {
+		args = append(args, p.lang)
+	}
+	args = append(args, p.outputs...)
+	directive := &irfmt.Directive{Name: taskDirective, Text: strings.Join(steps, "|")}
+	for _, arg := range args {
+		directive.Args = append(directive.Args, irfmt.Arg{Value: arg})
+	}
+	return irfmt.Print(&irfmt.Document{Nodes: []irfmt.Node{directive}})
+}
+
+// objectText joins the step objects, from which Decode infers the language.
+func (p taskPlan) objectText() string {
+	objects := make([]string, 0, len(p.steps))
+	for _, step := range p.steps {
+		if step.object != "" {
+			objects = append(objects, step.object)
+		}
+	}
+	return strings.Join(objects, " ")
+}
+
+func (p taskPlan) render() string {
+	clauses := make([]string, len(p.steps))
+	for i, step := range p.steps {
+		clause := taskSurface(step.op, p.lang)
+		switch {
+		case step.object != "":
+			clause += " " + step.object
+		case i > 0 && p.lang == langEN:
+			clause += " it"
+		}
+		clauses[i] = clause
+	}
+
+	conj := " and "
+	if p.lang == langPT {
+		conj = " e "
+	}
+	text := clauses[len(clauses)-1]
+	if len(clauses) > 1 {
+		text = strings.Join(clauses[:len(clauses)-1], ", ") + conj + text
+	}
+	for _, tag := range p.outputs {
+		for _, out := range taskOutputs {
+			if out.tag != tag {
+				continue
+			}
+			if p.lang == langPT {
+				text += " " + out.pt
+			} else {
+				text += " " + out.en
+			}
+		}
+	}
+	return capitalizeFirst(text) + "."
+}
+
+func taskSurface(op, lang string) string {
+	verb, ok := taskOpIndex[op]
+	if !ok {
+		return strings.ToLower(op)
+	}
+	if lang == langPT && len(verb.pt) > 0 {
+		return verb.pt[0]
+	}
+	return verb.en[0]
+}
+
+func parseTaskIR(ir string) (taskPlan, error) {
+	doc, err := irfmt.Parse(strings.TrimSpace(ir))
+	if err != nil {
+		return taskPlan{}, fmt.Errorf("%w: %v", ErrInvalidIR, err)
+	}
+	if len(doc.Nodes) != 1 {
+		return taskPlan{}, fmt.Errorf("%w: want one @%s line, got %d lines", ErrInvalidIR, taskDirective, len(doc.Nodes))
+	}
+	directive, ok := doc.Nodes[0].(*irfmt.Directive)
+	if !ok || directive.Name != taskDirective || directive.Block != nil {
+		return taskPlan{}, fmt.Errorf("%w: want @%s, got %q", ErrInvalidIR, taskDirective, irfmt.Print(doc))
+	}
+	if strings.TrimSpace(directive.Text) == "" {
+		return taskPlan{}, ErrNoTaskSteps
+	}
+	var plan taskPlan
+	for _, step := range strings.Split(directive.Text, "|") {
+		op, object, _ := strings.Cut(strings.TrimSpace(step), " ")
+		if op == "" {
+			return taskPlan{}, fmt.Errorf("%w: empty step in %q", ErrInvalidIR, directive.Text)
+		}
+		plan.steps = append(plan.steps, taskStep{op: op, object: strings.TrimSpace(object)})
+	}
+	plan.lang = detectTaskLang(plan.objectText())
+	for _, arg := range directive.Args {
+		switch {
+		case arg.Key != "":
+			return taskPlan{}, fmt.Errorf("%w: unknown task argument %q", ErrInvalidIR, arg.Key)
+		case arg.Value == langEN || arg.Value == langPT:
+			plan.lang = arg.Value
+		case isTaskOutput(arg.Value):
+			plan.outputs = appendUnique(plan.outputs, arg.Value)
+		default:
+			return taskPlan{}, fmt.Errorf("%w: unknown task argument %q", ErrInvalidIR, arg.Value)
+		}
+	}
+	return plan, nil
+}
+
+// formatHeader prints the "@NAME[args]" first line of a multi-line IR.
+func formatHeader(name string, args ...string) string {
+	directive := &irfmt.Directive{Name: strings.TrimPrefix(name, "@")}
+	for _, arg := range args {
+		directive.Args = append(directive.Args, irfmt.Arg{Value: arg})
+	}
+	return irfmt.Print(&irfmt.Document{Nodes: []irfmt.Node{directive}})
+}
+
+// parseHeader parses a line printed by formatHeader and returns its
+// arguments.
+func parseHeader(line, name string) ([]string, error) {
+	doc, err := irfmt.Parse(strings.TrimSpace(line))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s header: %v", ErrInvalidIR, name, err)
+	}
+	directive := doc.Directive(strings.TrimPrefix(name, "@"))
+	if len(doc.Nodes) != 1 || directive == nil || directive.Block != nil || directive.Text != "" {
+		return nil, fmt.Errorf("%w: missing %s header", ErrInvalidIR, name)
+	}
+	return directive.Positional(), nil
+}
+
+func isTaskOutput(tag string) bool {
+	for _, out := range taskOutputs {
+		if out.tag == tag {
+			return true
+		}
+	}
+	return false
+}
+
+func appendUnique(values []string, value string) []string {
+	if containsString(values, value) {
+		return values
+	}
+	return append(values, value)
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func capitalizeFirst(text string) string {
+	r, size := utf8.DecodeRuneInString(text)
+	if r == utf8.RuneError {
+		return text
+	}
+	return string(unicode.ToUpper(r)) + text[size:]
+}
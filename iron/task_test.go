@@ -0,0 +1,104 @@
+package iron
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTaskModule_Golden(t *testing.T) {
+	module := TaskModule{}
+	engine := New(WithModule(module))
+	for _, g := range loadGoldenDir(t, "task") {
+		t.Run(g.name(), func(t *testing.T) {
+			input := g.get("input")
+			if !module.Detect(input) {
+				t.Fatalf("Detect(%q) = false, want true", input)
+			}
+
+			encoded, err := module.Encode(input)
+			if err != nil {
+				t.Fatalf("Encode() error = %v", err)
+			}
+			g.check(t, "ir", encoded)
+
+			decoded, err := module.Decode(encoded)
+			if err != nil {
+				t.Fatalf("Decode() error = %v", err)
+			}
+			g.check(t, "output", decoded)
+			g.save(t)
+
+			reencoded, err := module.Encode(decoded)
+			if err != nil {
+				t.Fatalf("Encode(decoded) error = %v", err)
+			}
+			if reencoded != encoded {
+				t.Fatalf("round trip IR = %q, want %q", reencoded, encoded)
+			}
+
+			result, err := engine.ProcessDetailed(input)
+			if err != nil {
+				t.Fatalf("ProcessDetailed() error = %v", err)
+			}
+			if result.IRTokens >= result.InputTokens {
+				t.Fatalf("IR tokens = %d, want < input tokens %d", result.IRTokens, result.InputTokens)
+			}
+		})
+	}
+}
+
+func TestTaskModule_Detect_RejectsNonTasks(t *testing.T) {
+	module := TaskModule{}
+	inputs := []string{
+		"random text",
+		"Analyze this repository.",
+		"The build failed and the tests are red.",
+		`{"analyze": true, "summarize": false}`,
+		"```go\nfunc main() {}\n```",
+	}
+	for _, input := range inputs {
+		if module.Detect(input) {
+			t.Errorf("Detect(%q) = true, want false", input)
+		}
+	}
+}
+
+func TestTaskModule_EncodeWithLoss_ReportsRewrites(t *testing.T) {
+	_, losses, err := TaskModule{}.EncodeWithLoss("1. Please read the config file\n2. Restart the service")
+	if err != nil {
+		t.Fatalf("EncodeWithLoss() error = %v", err)
+	}
+	want := []Loss{
+		{Kind: "markers", Count: 2, Detail: "list markers dropped"},
+		{Kind: "wording", Count: 3, Detail: "fillers, determiners and synonyms rewritten"},
+	}
+	if !reflect.DeepEqual(losses, want) {
+		t.Fatalf("EncodeWithLoss() losses = %+v, want %+v", losses, want)
+	}
+}
+
+func TestTaskModule_Decode_RejectsMalformedIR(t *testing.T) {
+	module := TaskModule{}
+	for _, ir := range []string{
+		"not a directive",
+		"@TASK",
+		"@TASK SUM report\nnot a step",
+		"@TASK SUM report||SUGG",
+		"@TASK[fancy] SUM report",
+	} {
+		if _, err := module.Decode(ir); err == nil {
+			t.Errorf("Decode(%q) error = nil, want error", ir)
+		}
+	}
+}
+
+func TestEngine_Process_UsesTaskModule(t *testing.T) {
+	engine := New(WithModule(TaskModule{}))
+	result, err := engine.ProcessDetailed("Analyze this repository, summarize it and suggest improvements.")
+	if err != nil {
+		t.Fatalf("ProcessDetailed() error = %v", err)
+	}
+	if result.Module != "IR-TASK" {
+		t.Fatalf("ProcessDetailed() module = %q, want %q", result.Module, "IR-TASK")
+	}
+}
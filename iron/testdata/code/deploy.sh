@@ -0,0 +1,23 @@
+#!/bin/bash
+# Deploy the service.
+set -euo pipefail
+source ./env.sh
+
+deploy() {
+    local target="$1" # where to go
+    echo "deploying to $target # now"
+    if [ -z "$target" ]; then
+        exit 1
+    fi
+}
+
+case "$1" in
+    prod)
+        deploy prod
+        ;;
+    *) echo "usage" ;;
+esac
+
+cat <<EOT
+  keep   # this
+EOT
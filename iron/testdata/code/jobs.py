@@ -0,0 +1,25 @@
+#!/usr/bin/env python3
+# Job runner.
+import os
+from typing import List
+
+
+class Runner:
+    """Runs jobs.
+
+    # this is not a comment
+    """
+
+    def __init__(self, jobs: List[str]):
+        self.jobs = jobs  # queued jobs
+
+    def run(self):
+        for job in self.jobs:
+            if job.startswith("#"):
+                continue
+            print(job,
+                  os.getpid())
+
+    @staticmethod
+    def _hidden():
+        return 1
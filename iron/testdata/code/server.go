@@ -0,0 +1,31 @@
+// Package server exposes a tiny HTTP API.
+package server
+
+import (
+	"fmt"
+	"net/http"
+)
+
+//go:generate stringer -type=Mode
+
+// Mode selects how requests are handled.
+type Mode int
+
+const usage = `usage:
+    server [flags]   // not a comment
+`
+
+// Handler answers every request.
+func Handler(w http.ResponseWriter, r *http.Request) {
+	// greet the caller
+	name := r.URL.Query().Get("name")
+	if name == "" {
+		name = "world" /* default */
+	}
+
+	fmt.Fprintf(w, "hello, %s\n", name)
+}
+
+func helper(x, y int) int {
+	return x + -y
+}
@@ -0,0 +1,183 @@
+package iron
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Tokenizer counts the tokens a model would spend on a text.
+type Tokenizer interface {
+	Name() string
+	Count(text string) int
+}
+
+// BPETokenizer estimates token counts offline the way byte-pair encoders
+// split text: common words and subwords cost one token, rare words are
+// split into fragments, digits are grouped in threes, and whitespace is
+// merged into the following word.
+type BPETokenizer struct{}
+
+// CharWordTokenizer is a cheap fallback that takes the larger of one token
+// per four characters and one token per word.
+type CharWordTokenizer struct{}
+
+// bpeVocab holds frequent words and subwords that encoders keep whole.
+var bpeVocab = wordSet(`
+hello world the be to of and a in that have i it for not on with he as you do at this but his by from they we say her
+she or an will my one all would there their what so up out if about who get which go me when make can like
+time no just him know take people into year your good some could them see other than then now look only
+come its over think also back after use two how our work first well way even new want because any these
+give day most us is are was were has had been being did does done said made should must may might shall
+each more very much many such here where why while both few own same through before between under again
+further once during above below off down those every still never always often already yet until since
+please list show find check run fix add remove delete update create read write open close start stop
+restart build test deploy install return error errors warn warning info debug message value values
+file files name names type types data user users server client request response status code line lines
+function func method class object string number int bool true false null nil none self def import from
+package main print println printf format json yaml http https www com org api url path config port host
+time date id key keys item items table row rows column columns query result results input output text
+summary summarize analyze review improve improvements suggest repository repo project issue issues bug
+bugs change changes commit branch merge pull push docker kubernetes linux disk memory cpu usage log logs
+service services system process task tasks tool tools model models token tokens prompt context agent
+para com que não uma por mais como mas foi ele ela isso este esta seu sua são tem ser ter você fazer
+`)
+
+// bpeSubwords are common affixes used to split words missing from bpeVocab.
+var bpeSubwords = wordSet(`
+ing ers ies tion tions sion ment ments ness able ible ial ive ous ful less est ist ism ize ise ization
+ity ure ance ence ical pre pro con com dis over under inter trans sub super auto ção ções mente dade ando endo
+`)
+
+func wordSet(words string) map[string]bool {
+	set := make(map[string]bool)
+	for _, word := range strings.Fields(words) {
+		set[word] = true
+	}
+	return set
+}
+
+func (BPETokenizer) Name() string {
+	return "bpe-estimate"
+}
+
+// Count estimates the token count of the text.
+func (BPETokenizer) Count(text string) int {
+	var tokens int
+	for i := 0; i < len(text); {
+		r, size := utf8.DecodeRuneInString(text[i:])
+		j := i + size
+		switch {
+		case unicode.IsLetter(r):
+			for j < len(text) {
+				next, n := utf8.DecodeRuneInString(text[j:])
+				if !unicode.IsLetter(next) {
+					break
+				}
+				j += n
+			}
+			tokens += bpeWordTokens(text[i:j])
+		case unicode.IsDigit(r):
+			for j < len(text) && text[j] >= '0' && text[j] <= '9' {
+				j++
+			}
+			tokens += (j - i + 2) / 3
+		case r == ' ':
+			for j < len(text) && text[j] == ' ' {
+				j++
+			}
+			// A single space is merged into the following word.
+			if j-i > 1 || j == len(text) {
+				tokens++
+			}
+		case unicode.IsSpace(r):
+			for j < len(text) {
+				next, n := utf8.DecodeRuneInString(text[j:])
+				if next == ' ' || !unicode.IsSpace(next) {
+					break
+				}
+				j += n
+			}
+			tokens++
+		default:
+			for j < len(text) {
+				next, n := utf8.DecodeRuneInString(text[j:])
+				if unicode.IsLetter(next) || unicode.IsDigit(next) || unicode.IsSpace(next) {
+					break
+				}
+				j += n
+			}
+			tokens += (utf8.RuneCountInString(text[i:j]) + 1) / 2
+		}
+		i = j
+	}
+	return tokens
+}
+
+// bpeWordTokens splits a letter run at case changes and scripts, then
+// estimates each part.
+func bpeWordTokens(word string) int {
+	var (
+		tokens int
+		start  int
+		prev   rune
+	)
+	flush := func(end int) {
+		if end > start {
+			tokens += bpePartTokens(word[start:end])
+		}
+		start = end
+	}
+	for i, r := range word {
+		switch {
+		case r >= utf8.RuneSelf && !isLatinLetter(r):
+			// Non-Latin scripts cost roughly a token per rune.
+			flush(i)
+			tokens++
+			start = i + utf8.RuneLen(r)
+		case i > 0 && unicode.IsUpper(r) && unicode.IsLower(prev):
+			flush(i)
+		}
+		prev = r
+	}
+	flush(len(word))
+	return tokens
+}
+
+func isLatinLetter(r rune) bool {
+	return unicode.Is(unicode.Latin, r)
+}
+
+// bpePartTokens peels known prefixes and suffixes off a word; the
+// remainder costs a token per four letters. Short words are assumed whole.
+func bpePartTokens(part string) int {
+	lower := strings.ToLower(part)
+	n := utf8.RuneCountInString(lower)
+	if n <= 5 || bpeVocab[lower] {
+		return 1
+	}
+	for j := len(lower) - 1; j >= 3; j-- {
+		if bpeVocab[lower[:j]] || bpeSubwords[lower[:j]] {
+			return 1 + bpePartTokens(lower[j:])
+		}
+	}
+	for i := 1; i < len(lower)-2; i++ {
+		if bpeVocab[lower[i:]] || bpeSubwords[lower[i:]] {
+			return bpePartTokens(lower[:i]) + 1
+		}
+	}
+	return (n + 3) / 4
+}
+
+func (CharWordTokenizer) Name() string {
+	return "char-word"
+}
+
+// Count returns max(ceil(runes/4), words).
+func (CharWordTokenizer) Count(text string) int {
+	chars := (utf8.RuneCountInString(text) + 3) / 4
+	if words := len(strings.Fields(text)); words > chars {
+		return words
+	}
+	return chars
+}
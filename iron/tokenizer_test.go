@@ -0,0 +1,69 @@
+package iron
+
+import "testing"
+
+func TestBPETokenizer_Count(t *testing.T) {
+	tests := []struct {
+		text string
+		want int
+	}{
+		{text: "", want: 0},
+		{text: "Hello, world!", want: 4},
+		{text: "The quick brown fox jumps over the lazy dog.", want: 10},
+		{text: "1234567", want: 3},
+		{text: "internationalization", want: 4},
+		{text: "日本語", want: 3},
+	}
+	for _, tt := range tests {
+		if got := (BPETokenizer{}).Count(tt.text); got != tt.want {
+			t.Fatalf("Count(%q) = %d, want %d", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestBPETokenizer_Count_Monotonic(t *testing.T) {
+	short := "Analyze repository and summarize it."
+	long := short + " Then suggest improvements for the deployment pipeline."
+	if a, b := (BPETokenizer{}).Count(short), (BPETokenizer{}).Count(long); a >= b {
+		t.Fatalf("Count(short) = %d, Count(long) = %d, want short < long", a, b)
+	}
+}
+
+func TestCharWordTokenizer_Count(t *testing.T) {
+	tests := []struct {
+		text string
+		want int
+	}{
+		{text: "", want: 0},
+		{text: "abcdefgh", want: 2},
+		{text: "a b c d e", want: 5},
+	}
+	for _, tt := range tests {
+		if got := (CharWordTokenizer{}).Count(tt.text); got != tt.want {
+			t.Fatalf("Count(%q) = %d, want %d", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestEngine_ProcessDetailed_CountsTokens(t *testing.T) {
+	engine := New(
+		WithModule(testModule{name: "short", score: 0.5, detect: true, encoded: "x y"}),
+		WithTokenizer(CharWordTokenizer{}),
+	)
+	result, err := engine.ProcessDetailed("one two three four five six")
+	if err != nil {
+		t.Fatalf("ProcessDetailed() error = %v", err)
+	}
+	if result.InputTokens != 7 || result.IRTokens != 2 {
+		t.Fatalf("tokens = %d/%d, want 7/2", result.InputTokens, result.IRTokens)
+	}
+	if want := 2.0 / 7.0; result.Ratio != want {
+		t.Fatalf("Ratio = %v, want %v", result.Ratio, want)
+	}
+	if result.Savings() <= 0.7 {
+		t.Fatalf("Savings() = %v, want > 0.7", result.Savings())
+	}
+	if result.EncodeDuration <= 0 || result.DecodeDuration <= 0 {
+		t.Fatalf("durations = %v/%v, want > 0", result.EncodeDuration, result.DecodeDuration)
+	}
+}
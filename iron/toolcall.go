@@ -0,0 +1,275 @@
+package iron
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Tool calls are written "name(key=value, ...)", or "name(value)" when the
+// only argument is the tool's positional one. String values are bare unless
+// they contain syntax or read as JSON, in which case they are JSON strings;
+// other values are compact JSON. JSON null stays null, so the string "null"
+// is quoted. Nothing is escaped for HTML, so "a > b" stays readable.
+
+const toolCallUnsafe = ",()[]{}=|\"\\\n\r\t"
+
+var toolNameRe = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.\-]*$`)
+
+// toolPositional holds the positional arguments of the agent's tools.
+var toolPositional = map[string]string{
+	"shell_exec":   "cmd",
+	"http_fetch":   "url",
+	"notes_append": "content",
+	"list_show":    "list",
+}
+
+// toolArg is one argument of a tool call, in call order.
+type toolArg struct {
+	key   string
+	value json.RawMessage
+}
+
+// formatToolCall writes a tool call; args must be a JSON object or null.
+func formatToolCall(name string, args json.RawMessage, positional string) (string, error) {
+	fields, err := toolArgs(args)
+	if err != nil {
+		return "", fmt.Errorf("tool %s: %w", name, err)
+	}
+	if len(fields) == 0 {
+		return name, nil
+	}
+	if len(fields) == 1 && fields[0].key == positional {
+		return name + "(" + formatToolValue(fields[0].value) + ")", nil
+	}
+	return name + "(" + formatToolArgs(fields) + ")", nil
+}
+
+// formatToolArgs writes fields as "key=value, ...".
+func formatToolArgs(fields []toolArg) string {
+	parts := make([]string, len(fields))
+	for i, field := range fields {
+		parts[i] = formatToolKey(field.key) + "=" + formatToolValue(field.value)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// parseToolCall reads a tool call written by formatToolCall and returns the
+// name and the arguments as a JSON object.
+func parseToolCall(text, positional string) (string, json.RawMessage, error) {
+	text = strings.TrimSpace(text)
+	name, rest, hasArgs := strings.Cut(text, "(")
+	name = strings.TrimSpace(name)
+	if !toolNameRe.MatchString(name) {
+		return "", nil, fmt.Errorf("invalid tool name %q", name)
+	}
+	if !hasArgs {
+		return name, json.RawMessage("{}"), nil
+	}
+	if !strings.HasSuffix(rest, ")") || closingIndex(text, strings.IndexByte(text, '(')) != len(text)-1 {
+		return "", nil, fmt.Errorf("tool %s: unbalanced parentheses", name)
+	}
+	fields, err := parseToolArgs(rest[:len(rest)-1], positional)
+	if err != nil {
+		return "", nil, fmt.Errorf("tool %s: %w", name, err)
+	}
+	return name, marshalToolArgs(fields), nil
+}
+
+// parseToolArgs reads "key=value, ..." or a single positional value.
+func parseToolArgs(text, positional string) ([]toolArg, error) {
+	if strings.TrimSpace(text) == "" {
+		return nil, nil
+	}
+	items := splitTopLevel(text, ",")
+	var fields []toolArg
+	for _, item := range items {
+		key, value, ok := cutTopLevel(item, "=")
+		if !ok {
+			if len(items) > 1 || positional == "" {
+				return nil, fmt.Errorf("argument %q has no key", strings.TrimSpace(item))
+			}
+			key, value = positional, item
+		}
+		key = parseToolValueString(key)
+		if key == "" {
+			return nil, fmt.Errorf("empty argument key")
+		}
+		fields = append(fields, toolArg{key: key, value: parseToolValue(value)})
+	}
+	return fields, nil
+}
+
+// toolArgs returns the fields of a JSON object in document order.
+func toolArgs(args json.RawMessage) ([]toolArg, error) {
+	trimmed := bytes.TrimSpace(args)
+	if len(trimmed) == 0 || string(trimmed) == "null" {
+		return nil, nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(trimmed))
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+		return nil, fmt.Errorf("args must be a JSON object")
+	}
+	var fields []toolArg
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		var value json.RawMessage
+		if err := dec.Decode(&value); err != nil {
+			return nil, err
+		}
+		fields = append(fields, toolArg{key: tok.(string), value: value})
+	}
+	if _, err := dec.Token(); err != nil {
+		return nil, err
+	}
+	return fields, nil
+}
+
+// marshalToolArgs writes fields as a JSON object in order.
+func marshalToolArgs(fields []toolArg) json.RawMessage {
+	var b bytes.Buffer
+	b.WriteByte('{')
+	for i, field := range fields {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.Write(marshalToolJSON(field.key))
+		b.WriteByte(':')
+		b.Write(field.value)
+	}
+	b.WriteByte('}')
+	return b.Bytes()
+}
+
+func formatToolKey(key string) string {
+	if toolNameRe.MatchString(key) {
+		return key
+	}
+	return string(marshalToolJSON(key))
+}
+
+func formatToolValue(value json.RawMessage) string {
+	var s *string
+	if err := json.Unmarshal(value, &s); err == nil && s != nil {
+		if isBareToolValue(*s) {
+			return *s
+		}
+		return string(marshalToolJSON(*s))
+	}
+	var b bytes.Buffer
+	if err := json.Compact(&b, value); err != nil {
+		return string(value)
+	}
+	return b.String()
+}
+
+// isBareToolValue reports whether s can be written without quotes and read
+// back as the same string.
+func isBareToolValue(s string) bool {
+	return s != "" && s == strings.TrimSpace(s) && !strings.ContainsAny(s, toolCallUnsafe) && !json.Valid([]byte(s))
+}
+
+// parseToolValue reads a bare string or a JSON value.
+func parseToolValue(text string) json.RawMessage {
+	text = strings.TrimSpace(text)
+	if json.Valid([]byte(text)) {
+		var b bytes.Buffer
+		_ = json.Compact(&b, []byte(text))
+		return b.Bytes()
+	}
+	return marshalToolJSON(text)
+}
+
+// marshalToolJSON is json.Marshal without HTML escaping.
+func marshalToolJSON(v any) []byte {
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return nil
+	}
+	return bytes.TrimSuffix(b.Bytes(), []byte("\n"))
+}
+
+// parseToolValueString reads a bare or quoted string.
+func parseToolValueString(text string) string {
+	text = strings.TrimSpace(text)
+	var s string
+	if strings.HasPrefix(text, `"`) && json.Unmarshal([]byte(text), &s) == nil {
+		return s
+	}
+	return text
+}
+
+// splitTopLevel splits s on sep outside of JSON strings and brackets.
+func splitTopLevel(s, sep string) []string {
+	var parts []string
+	for {
+		before, after, ok := cutTopLevel(s, sep)
+		parts = append(parts, before)
+		if !ok {
+			return parts
+		}
+		s = after
+	}
+}
+
+// cutTopLevel is strings.Cut for the first sep outside of JSON strings and
+// brackets.
+func cutTopLevel(s, sep string) (string, string, bool) {
+	depth := 0
+	inString := false
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case inString:
+			if c == '\\' {
+				i++
+			} else if c == '"' {
+				inString = false
+			}
+		case c == '"':
+			inString = true
+		case c == '(' || c == '[' || c == '{':
+			depth++
+		case c == ')' || c == ']' || c == '}':
+			depth--
+		case depth == 0 && strings.HasPrefix(s[i:], sep):
+			return s[:i], s[i+len(sep):], true
+		}
+	}
+	return s, "", false
+}
+
+// closingIndex returns the index of the bracket that closes the one at
+// open, or -1.
+func closingIndex(s string, open int) int {
+	depth := 0
+	inString := false
+	for i := open; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case inString:
+			if c == '\\' {
+				i++
+			} else if c == '"' {
+				inString = false
+			}
+		case c == '"':
+			inString = true
+		case c == '(' || c == '[' || c == '{':
+			depth++
+		case c == ')' || c == ']' || c == '}':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
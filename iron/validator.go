@@ -0,0 +1,235 @@
+package iron
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"math/big"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// Validator checks that a decoded output preserves the normalized input.
+type Validator interface {
+	Name() string
+	Validate(input, output string) error
+}
+
+// ErrValidation is wrapped by the errors validators return.
+var ErrValidation = errors.New("validation failed")
+
+// ExactValidator requires the output to equal the input.
+type ExactValidator struct{}
+
+func (ExactValidator) Name() string {
+	return "exact"
+}
+
+// Validate reports the first differing line.
+func (ExactValidator) Validate(input, output string) error {
+	if input == output {
+		return nil
+	}
+	inLines, outLines := strings.Split(input, "\n"), strings.Split(output, "\n")
+	for i := 0; i < len(inLines) || i < len(outLines); i++ {
+		if i >= len(inLines) || i >= len(outLines) || inLines[i] != outLines[i] {
+			return fmt.Errorf("%w: output differs from input at line %d", ErrValidation, i+1)
+		}
+	}
+	return fmt.Errorf("%w: output differs from input", ErrValidation)
+}
+
+// JSONValidator requires JSON inputs to decode to semantically equal JSON:
+// key order, whitespace, and number spelling are ignored. Non-JSON inputs
+// pass.
+type JSONValidator struct{}
+
+func (JSONValidator) Name() string {
+	return "json"
+}
+
+func (JSONValidator) Validate(input, output string) error {
+	want, err := decodeJSONValue(input)
+	if err != nil {
+		return nil
+	}
+	got, err := decodeJSONValue(output)
+	if err != nil {
+		return fmt.Errorf("%w: output is not valid JSON: %v", ErrValidation, err)
+	}
+	if path, ok := jsonSemanticEqual(want, got, "$"); !ok {
+		return fmt.Errorf("%w: JSON differs at %s", ErrValidation, path)
+	}
+	return nil
+}
+
+func decodeJSONValue(text string) (any, error) {
+	decoder := json.NewDecoder(strings.NewReader(text))
+	decoder.UseNumber()
+	var value any
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+	if _, err := decoder.Token(); err != io.EOF {
+		return nil, errors.New("trailing data after JSON value")
+	}
+	return value, nil
+}
+
+// jsonSemanticEqual compares decoded JSON values and returns the path of the
+// first difference.
+func jsonSemanticEqual(a, b any, path string) (string, bool) {
+	switch av := a.(type) {
+	case map[string]any:
+		bv, ok := b.(map[string]any)
+		if !ok || len(av) != len(bv) {
+			return path, false
+		}
+		for key, value := range av {
+			other, ok := bv[key]
+			if !ok {
+				return path + "." + key, false
+			}
+			if diff, ok := jsonSemanticEqual(value, other, path+"."+key); !ok {
+				return diff, false
+			}
+		}
+		return "", true
+	case []any:
+		bv, ok := b.([]any)
+		if !ok || len(av) != len(bv) {
+			return path, false
+		}
+		for i := range av {
+			if diff, ok := jsonSemanticEqual(av[i], bv[i], fmt.Sprintf("%s[%d]", path, i)); !ok {
+				return diff, false
+			}
+		}
+		return "", true
+	case json.Number:
+		bv, ok := b.(json.Number)
+		if !ok {
+			return path, false
+		}
+		x, okA := new(big.Rat).SetString(av.String())
+		y, okB := new(big.Rat).SetString(bv.String())
+		if !okA || !okB {
+			return path, av == bv
+		}
+		return path, x.Cmp(y) == 0
+	default:
+		return path, a == b
+	}
+}
+
+// KeyTermValidator requires the output to mention a share of the input's key
+// terms: numbers and words of four or more letters that are not stopwords.
+// Words sharing a five-letter prefix count as the same term.
+type KeyTermValidator struct {
+	// MinCoverage is the required share of key terms; zero means 0.8.
+	MinCoverage float64
+}
+
+func (KeyTermValidator) Name() string {
+	return "key-terms"
+}
+
+func (v KeyTermValidator) Validate(input, output string) error {
+	minCoverage := v.MinCoverage
+	if minCoverage <= 0 {
+		minCoverage = 0.8
+	}
+	coverage, missing := KeyTermCoverage(input, output)
+	if coverage < minCoverage {
+		return fmt.Errorf("%w: key-term coverage %.2f below %.2f, missing %s",
+			ErrValidation, coverage, minCoverage, strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// KeyTermCoverage returns the share of the input's key terms that the output
+// mentions, and the missing terms. An input without key terms is covered.
+// Markup is not content: the terms of an HTML input come from StripHTML.
+func KeyTermCoverage(input, output string) (float64, []string) {
+	if (WebModule{}).Detect(input) {
+		input = StripHTML(input)
+	}
+	terms := keyTerms(input)
+	if len(terms) == 0 {
+		return 1, nil
+	}
+	present := make(map[string]bool)
+	for _, term := range keyTerms(output) {
+		present[term] = true
+		present[termStem(term)] = true
+	}
+	var missing []string
+	for _, term := range terms {
+		if !present[term] && !present[termStem(term)] {
+			missing = append(missing, term)
+		}
+	}
+	return float64(len(terms)-len(missing)) / float64(len(terms)), missing
+}
+
+var (
+	htmlHiddenRe = regexp.MustCompile(`(?is)<!--.*?-->|<head\b.*?</head\s*>|<script\b.*?</script\s*>|<style\b.*?</style\s*>`)
+	htmlBreakRe  = regexp.MustCompile(`(?i)</?(?:address|article|aside|blockquote|br|dd|div|dl|dt|figcaption|figure|footer|form|h[1-6]|header|hr|li|main|nav|ol|p|pre|section|table|td|th|title|tr|ul)\b[^>]*>`)
+	htmlTagRe    = regexp.MustCompile(`<[!/?]?[A-Za-z][^>]*>`)
+)
+
+// StripHTML returns the visible text of an HTML document: comments, the
+// head, scripts, and styles are removed, block elements break lines, other tags are dropped,
+// and entities are unescaped. It shares no code with WebModule, so that it
+// can be the reference WebModule's output is checked against.
+func StripHTML(input string) string {
+	text := htmlHiddenRe.ReplaceAllString(input, "")
+	text = htmlBreakRe.ReplaceAllString(text, "\n")
+	text = htmlTagRe.ReplaceAllStringFunc(text, func(tag string) string {
+		if redactPlaceholderRe.MatchString(tag) {
+			return tag
+		}
+		return ""
+	})
+	var lines []string
+	for _, line := range strings.Split(html.UnescapeString(text), "\n") {
+		if line = strings.Join(strings.Fields(line), " "); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// keyTerms returns the distinct lowercase key terms of the text in order.
+func keyTerms(text string) []string {
+	var (
+		terms []string
+		seen  = make(map[string]bool)
+	)
+	for _, word := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		hasDigit := strings.IndexFunc(word, unicode.IsDigit) >= 0
+		if !hasDigit && (len([]rune(word)) < 4 || proseStopwords[word]) {
+			continue
+		}
+		if !seen[word] {
+			seen[word] = true
+			terms = append(terms, word)
+		}
+	}
+	return terms
+}
+
+// termStem is the word's first five letters, marked so it cannot collide
+// with a whole term. Numbers are never stemmed.
+func termStem(term string) string {
+	runes := []rune(term)
+	if len(runes) <= 5 || strings.IndexFunc(term, unicode.IsDigit) >= 0 {
+		return term
+	}
+	return string(runes[:5]) + "~"
+}
@@ -0,0 +1,111 @@
+package iron
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestExactValidator_Validate(t *testing.T) {
+	if err := (ExactValidator{}).Validate("a\nb", "a\nb"); err != nil {
+		t.Fatalf("Validate() error = %v, want nil", err)
+	}
+	err := ExactValidator{}.Validate("a\nb\nc", "a\nB\nc")
+	if !errors.Is(err, ErrValidation) || !strings.Contains(err.Error(), "line 2") {
+		t.Fatalf("Validate() error = %v, want line 2 difference", err)
+	}
+}
+
+func TestJSONValidator_Validate(t *testing.T) {
+	tests := []struct {
+		input   string
+		output  string
+		wantErr string
+	}{
+		{input: `{"a":1,"b":[true,null]}`, output: "{\n  \"b\": [true, null],\n  \"a\": 1.0\n}"},
+		{input: "not json", output: "anything"},
+		{input: `{"a":{"b":[1,2]}}`, output: `{"a":{"b":[1,3]}}`, wantErr: "JSON differs at $.a.b[1]"},
+		{input: `{"a":1}`, output: `{"a":1}x`, wantErr: "output is not valid JSON"},
+		{input: `{"id":12345678901234567890}`, output: `{"id":12345678901234567891}`, wantErr: "JSON differs at $.id"},
+	}
+	for _, tt := range tests {
+		err := JSONValidator{}.Validate(tt.input, tt.output)
+		if tt.wantErr == "" {
+			if err != nil {
+				t.Fatalf("Validate(%q) error = %v, want nil", tt.input, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Fatalf("Validate(%q) error = %v, want %q", tt.input, err, tt.wantErr)
+		}
+	}
+}
+
+func TestKeyTermValidator_Validate(t *testing.T) {
+	input := "Analyze this repository, summarize it and suggest improvements."
+	if err := (KeyTermValidator{}).Validate(input, "Analyze repository, summarize it and suggest improvements."); err != nil {
+		t.Fatalf("Validate() error = %v, want nil", err)
+	}
+	if err := (KeyTermValidator{}).Validate("Restart server 10.0.0.1 on port 8080", "Restart server on port 8081"); err == nil {
+		t.Fatalf("Validate() error = nil, want missing numbers")
+	}
+	err := KeyTermValidator{MinCoverage: 1}.Validate(input, "Analyze repository and summarize it.")
+	if err == nil || !strings.Contains(err.Error(), "missing suggest, improvements") {
+		t.Fatalf("Validate() error = %v, want missing suggest, improvements", err)
+	}
+}
+
+func TestStripHTML(t *testing.T) {
+	input := `<html><head><title>Pools</title><style>p{}</style></head><body>` +
+		`<!-- draft --><h1>Pools &amp; queues</h1><script>track()</script>` +
+		`<p>Mail <EMAIL_1> about <b>sizing</b>.<br>Thanks</p></body></html>`
+	want := "Pools & queues\nMail <EMAIL_1> about sizing.\nThanks"
+	if got := StripHTML(input); got != want {
+		t.Fatalf("StripHTML() = %q, want %q", got, want)
+	}
+}
+
+func TestEngine_ProcessDetailed_SkipsRejectedModule(t *testing.T) {
+	engine := New(
+		WithModule(testModule{name: "lossy", score: 0.9, detect: true}),
+		WithModule(echoModule{name: "echo", score: 0.5}),
+		WithValidator(ExactValidator{}),
+	)
+	result, err := engine.ProcessDetailed("hello")
+	if err != nil {
+		t.Fatalf("ProcessDetailed() error = %v", err)
+	}
+	if result.Module != "echo" || result.Output != "hello" {
+		t.Fatalf("ProcessDetailed() = %q/%q, want echo/hello", result.Module, result.Output)
+	}
+	if len(result.Rejected) != 1 || result.Rejected[0].Module != "lossy" || result.Rejected[0].Validator != "exact" {
+		t.Fatalf("Rejected = %+v, want lossy rejected by exact", result.Rejected)
+	}
+}
+
+func TestEngine_ProcessDetailed_FallsBackToPassthrough(t *testing.T) {
+	engine := New(
+		WithModule(testModule{name: "lossy", score: 0.9, detect: true}),
+		WithValidator(ExactValidator{}),
+	)
+	result, err := engine.ProcessDetailed("hello")
+	if err != nil {
+		t.Fatalf("ProcessDetailed() error = %v", err)
+	}
+	if result.Module != "IR-PASS" || len(result.Rejected) != 1 {
+		t.Fatalf("ProcessDetailed() module = %q rejected = %+v, want IR-PASS after one rejection", result.Module, result.Rejected)
+	}
+}
+
+// echoModule encodes and decodes without changes.
+type echoModule struct {
+	name  string
+	score float64
+}
+
+func (m echoModule) Name() string                       { return m.name }
+func (echoModule) Detect(string) bool                   { return true }
+func (echoModule) Encode(input string) (string, error)  { return input, nil }
+func (echoModule) Decode(output string) (string, error) { return output, nil }
+func (m echoModule) Score() float64                     { return m.score }
@@ -0,0 +1,585 @@
+package iron
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// WebModule turns fetched HTML pages into compact IR that decodes to
+// Markdown.
+//
+// Scripts, styles, forms, and hidden elements are always dropped. At
+// LevelLossless every other visible block is kept; higher levels keep only
+// the main content found by a readability pass that scores blocks by text
+// length, commas, and link density. LevelAggressive also drops link targets
+// and images.
+//
+// The IR has one block per line under a "@WEB" header: "T" title, "H1".."H6"
+// headings, "P" paragraphs, "L<depth>" and "O<depth>" list items, "Q"
+// quotes, "C" code lines, and "R" table rows with "|"-separated cells.
+// Links read "[text][n]", with "@n url" definitions at the end.
+type WebModule struct {
+	Level Level
+}
+
+const webHeader = "@WEB"
+
+var (
+	// webSkipTags never hold readable content.
+	webSkipTags = wordSet(`head script style noscript template svg canvas iframe object embed form
+		input button select textarea option`)
+	// webBoilerplateTags are page chrome dropped by the readability pass.
+	webBoilerplateTags = wordSet(`nav aside footer header menu`)
+	webHTMLTagRe       = regexp.MustCompile(`(?i)<(?:!doctype html|html|head|body|div|p|a|span|h[1-6]|ul|ol|li|table|article|section|main|nav|br|img)\b`)
+	webNegativeRe      = regexp.MustCompile(`(?i)\b(?:nav|navbar|menu|footer|sidebar|comment|comments|share|social|cookie|banner|ad|ads|advert|promo|breadcrumbs?|subscribe|newsletter|related|popup|modal|masthead|widget)\b`)
+	webPositiveRe      = regexp.MustCompile(`(?i)\b(?:article|content|main|post|entry|text|body|story|blog)\b`)
+	webBlockRe         = regexp.MustCompile(`^(T|H[1-6]|P|[LO]\d+|Q|C|R) ?(.*)$`)
+	webLinkDefRe       = regexp.MustCompile(`^@(\d+) (.*)$`)
+)
+
+func (WebModule) Name() string {
+	return "IR-WEB"
+}
+
+// Detect reports whether the input is an HTML page or fragment.
+func (WebModule) Detect(input string) bool {
+	trimmed := strings.TrimSpace(input)
+	if !strings.HasPrefix(trimmed, "<") {
+		return false
+	}
+	return len(webHTMLTagRe.FindAllStringIndex(trimmed, 3)) >= 2
+}
+
+// Encode converts the page at the module's level.
+func (m WebModule) Encode(input string) (string, error) {
+	encoded, _, err := m.EncodeWithLoss(input)
+	return encoded, err
+}
+
+// EncodeWithLoss converts the page and reports what was dropped.
+func (m WebModule) EncodeWithLoss(input string) (string, []Loss, error) {
+	doc := parseHTML(input)
+	enc := &webEncoder{level: m.Level, links: map[string]int{}, stats: map[string]int{}}
+	countWebSkipped(doc, enc.stats)
+
+	root := doc.find("body")
+	if root == nil {
+		root = doc
+	}
+	if m.Level > LevelLossless {
+		root = enc.readable(root)
+	}
+	title := ""
+	if node := doc.find("title"); node != nil {
+		title = node.innerText()
+	}
+	enc.blocks(root)
+	if title != "" && !enc.hasHeading(title) {
+		enc.lines = append([]string{"T " + title}, enc.lines...)
+	}
+	if len(enc.lines) == 0 {
+		return "", nil, fmt.Errorf("%w: page has no readable content", ErrInvalidIR)
+	}
+	out := append([]string{webHeader}, enc.lines...)
+	for i, url := range enc.urls {
+		out = append(out, fmt.Sprintf("@%d %s", i+1, url))
+	}
+	return strings.Join(out, "\n"), enc.losses(), nil
+}
+
+// EncodeContext encodes at the level hinted in ctx, if any.
+func (m WebModule) EncodeContext(ctx context.Context, input string) (string, []Loss, error) {
+	if err := ctx.Err(); err != nil {
+		return "", nil, err
+	}
+	m.Level = HintsFromContext(ctx).LevelOr(m.Level)
+	return m.EncodeWithLoss(input)
+}
+
+// DecodeContext decodes unless ctx is done.
+func (m WebModule) DecodeContext(ctx context.Context, ir string) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	return m.Decode(ir)
+}
+
+// Decode renders the IR as Markdown, with links as reference definitions.
+func (WebModule) Decode(output string) (string, error) {
+	lines := strings.Split(output, "\n")
+	if strings.TrimSpace(lines[0]) != webHeader {
+		return "", fmt.Errorf("%w: missing %s header", ErrInvalidIR, webHeader)
+	}
+	var (
+		blocks []string
+		group  []string
+		kind   string
+		defs   []string
+	)
+	flush := func() {
+		if len(group) == 0 {
+			return
+		}
+		text := strings.Join(group, "\n")
+		if kind == "C" {
+			text = "```\n" + text + "\n```"
+		}
+		blocks = append(blocks, text)
+		group = nil
+	}
+	for i, line := range lines[1:] {
+		if line == "" {
+			continue
+		}
+		if match := webLinkDefRe.FindStringSubmatch(line); match != nil {
+			defs = append(defs, fmt.Sprintf("[%s]: %s", match[1], match[2]))
+			continue
+		}
+		match := webBlockRe.FindStringSubmatch(line)
+		if match == nil {
+			return "", fmt.Errorf("%w: line %d: unknown web block %q", ErrInvalidIR, i+2, line)
+		}
+		prefix, text := match[1], match[2]
+		lineKind := prefix[:1]
+		if webBlockGroup(lineKind) != kind || !webGrouped(lineKind) {
+			flush()
+			kind = webBlockGroup(lineKind)
+		}
+		switch lineKind {
+		case "T":
+			group = append(group, "# "+text)
+		case "H":
+			level, _ := strconv.Atoi(prefix[1:])
+			group = append(group, strings.Repeat("#", level)+" "+text)
+		case "L", "O":
+			depth, _ := strconv.Atoi(prefix[1:])
+			marker := "- "
+			if lineKind == "O" {
+				marker = "1. "
+			}
+			group = append(group, strings.Repeat("   ", max(depth-1, 0))+marker+text)
+		case "Q":
+			group = append(group, "> "+text)
+		case "R":
+			group = append(group, "| "+strings.Join(splitWebCells(text), " | ")+" |")
+			if len(group) == 1 {
+				group = append(group, "|"+strings.Repeat(" --- |", len(splitWebCells(text))))
+			}
+		default:
+			group = append(group, text)
+		}
+	}
+	flush()
+	if len(defs) > 0 {
+		blocks = append(blocks, strings.Join(defs, "\n"))
+	}
+	return strings.Join(blocks, "\n\n"), nil
+}
+
+func (WebModule) Score() float64 {
+	return 0.8
+}
+
+// Classify returns the default classifier's confidence that input is a web page.
+func (WebModule) Classify(input string) float64 {
+	return DefaultClassifier().Confidence("web", input)
+}
+
+// webBlockGroup maps both list kinds to one group, so that nested lists of
+// the other kind stay in the same Markdown list.
+func webBlockGroup(kind string) string {
+	if kind == "O" {
+		return "L"
+	}
+	return kind
+}
+
+// webGrouped reports whether consecutive lines of kind form one Markdown
+// block.
+func webGrouped(kind string) bool {
+	return kind == "L" || kind == "O" || kind == "Q" || kind == "C" || kind == "R"
+}
+
+// splitWebCells splits a table row on unescaped "|".
+func splitWebCells(row string) []string {
+	var (
+		cells []string
+		sb    strings.Builder
+	)
+	for i := 0; i < len(row); i++ {
+		switch {
+		case row[i] == '\\' && i+1 < len(row) && row[i+1] == '|':
+			sb.WriteString(`\|`)
+			i++
+		case row[i] == '|':
+			cells = append(cells, sb.String())
+			sb.Reset()
+		default:
+			sb.WriteByte(row[i])
+		}
+	}
+	return append(cells, sb.String())
+}
+
+type webEncoder struct {
+	level Level
+	lines []string
+	urls  []string
+	links map[string]int
+	stats map[string]int
+}
+
+// countWebSkipped counts the scripts and styles a page carries.
+func countWebSkipped(node *htmlNode, stats map[string]int) {
+	for _, child := range node.children {
+		switch child.tag {
+		case "script", "style":
+			stats[child.tag]++
+		}
+		countWebSkipped(child, stats)
+	}
+}
+
+func (e *webEncoder) losses() []Loss {
+	var losses []Loss
+	for _, kind := range []string{"script", "style", "boilerplate", "link", "image"} {
+		if count := e.stats[kind]; count > 0 {
+			losses = append(losses, Loss{Kind: kind, Count: count, Detail: "dropped"})
+		}
+	}
+	return losses
+}
+
+// hasHeading reports whether the page title repeats a top-level heading,
+// such as "Post | Site" for the heading "Post".
+func (e *webEncoder) hasHeading(title string) bool {
+	title = strings.ToLower(title)
+	for _, line := range e.lines {
+		if strings.HasPrefix(line, "H1 ") && strings.Contains(title, strings.ToLower(line[3:])) {
+			return true
+		}
+	}
+	return false
+}
+
+// readable returns the main content under root: the best-scoring container
+// and its siblings that score close to it. Boilerplate blocks are counted
+// as dropped.
+func (e *webEncoder) readable(root *htmlNode) *htmlNode {
+	scores := map[*htmlNode]float64{}
+	var score func(*htmlNode)
+	score = func(node *htmlNode) {
+		for _, child := range node.children {
+			if child.tag == "" || webSkipTags[child.tag] || isWebBoilerplate(child) {
+				continue
+			}
+			switch child.tag {
+			case "p", "pre", "td", "li", "blockquote":
+				text := child.innerText()
+				if len(text) >= 25 {
+					points := 1 + float64(strings.Count(text, ",")) + min(float64(len(text))/100, 3)
+					if parent := child.parent; parent != nil {
+						scores[parent] += points + webTagWeight(parent)
+						if grand := parent.parent; grand != nil {
+							scores[grand] += points/2 + webTagWeight(grand)
+						}
+					}
+				}
+			}
+			score(child)
+		}
+	}
+	score(root)
+
+	var top *htmlNode
+	best := 0.0
+	for node, points := range scores {
+		points *= 1 - linkDensity(node)
+		scores[node] = points
+		if top == nil || points > best {
+			top, best = node, points
+		}
+	}
+	if top == nil {
+		top = root
+	}
+	kept := &htmlNode{tag: "div"}
+	siblings := []*htmlNode{top}
+	if top.parent != nil && top != root {
+		siblings = top.parent.children
+	}
+	for _, sibling := range siblings {
+		keep := sibling == top
+		if !keep && sibling.tag != "" && !isWebBoilerplate(sibling) {
+			text := sibling.innerText()
+			keep = scores[sibling] >= max(10, best*0.2) ||
+				sibling.tag == "p" && len(text) > 80 && linkDensity(sibling) < 0.25
+		}
+		if keep {
+			kept.children = append(kept.children, sibling)
+		}
+	}
+	e.stats["boilerplate"] += countWebBlocks(root) - countWebBlocks(kept)
+	return kept
+}
+
+// webTagWeight is the readability bonus of a container for its tag, class,
+// and id.
+func webTagWeight(node *htmlNode) float64 {
+	weight := 0.0
+	switch node.tag {
+	case "article", "main":
+		weight += 10
+	case "div", "section":
+		weight += 2
+	case "form", "ul", "ol", "dl":
+		weight -= 3
+	}
+	names := node.attrs["class"] + " " + node.attrs["id"]
+	if webPositiveRe.MatchString(names) {
+		weight += 5
+	}
+	if webNegativeRe.MatchString(names) {
+		weight -= 5
+	}
+	return weight
+}
+
+// isWebBoilerplate reports page chrome such as navigation and footers.
+func isWebBoilerplate(node *htmlNode) bool {
+	if webBoilerplateTags[node.tag] {
+		return true
+	}
+	names := node.attrs["class"] + " " + node.attrs["id"] + " " + node.attrs["role"]
+	return webNegativeRe.MatchString(names) && !webPositiveRe.MatchString(names)
+}
+
+func linkDensity(node *htmlNode) float64 {
+	text := node.innerText()
+	if text == "" {
+		return 0
+	}
+	return float64(len(node.linkText())) / float64(len(text))
+}
+
+// countWebBlocks counts the text-bearing blocks under node.
+func countWebBlocks(node *htmlNode) int {
+	count := 0
+	for _, child := range node.children {
+		switch {
+		case child.tag == "" || webSkipTags[child.tag]:
+		case child.tag == "p" || child.tag == "li" || child.tag == "pre" || child.tag == "tr" || strings.HasPrefix(child.tag, "h") && len(child.tag) == 2:
+			count++
+		default:
+			count += countWebBlocks(child)
+		}
+	}
+	return count
+}
+
+func isHidden(node *htmlNode) bool {
+	if _, ok := node.attrs["hidden"]; ok {
+		return true
+	}
+	style := strings.ReplaceAll(strings.ToLower(node.attrs["style"]), " ", "")
+	return node.attrs["aria-hidden"] == "true" || strings.Contains(style, "display:none") || strings.Contains(style, "visibility:hidden")
+}
+
+// blocks emits the IR lines of node's block content.
+func (e *webEncoder) blocks(node *htmlNode) {
+	var inline []*htmlNode
+	flush := func() {
+		if text := e.inline(inline); text != "" {
+			e.lines = append(e.lines, "P "+text)
+		}
+		inline = nil
+	}
+	for _, child := range node.children {
+		if child.tag != "" && (webSkipTags[child.tag] || isHidden(child)) {
+			continue
+		}
+		if e.level > LevelLossless && child.tag != "" && isWebBoilerplate(child) {
+			continue
+		}
+		switch child.tag {
+		case "h1", "h2", "h3", "h4", "h5", "h6":
+			flush()
+			if text := e.inline(child.children); text != "" {
+				e.lines = append(e.lines, strings.ToUpper(child.tag)+" "+text)
+			}
+		case "p", "dt", "dd", "figcaption", "caption":
+			flush()
+			if text := e.inline(child.children); text != "" {
+				e.lines = append(e.lines, "P "+text)
+			}
+		case "ul", "ol":
+			flush()
+			e.list(child, 1)
+		case "blockquote":
+			flush()
+			if text := e.inline(child.children); text != "" {
+				e.lines = append(e.lines, "Q "+text)
+			}
+		case "pre":
+			flush()
+			var sb strings.Builder
+			child.walkText(func(text string) { sb.WriteString(text) })
+			for _, line := range strings.Split(strings.Trim(sb.String(), "\n"), "\n") {
+				e.lines = append(e.lines, "C "+line)
+			}
+		case "table":
+			flush()
+			e.table(child)
+		case "br", "hr":
+			flush()
+		default:
+			if child.tag != "" && (htmlBlockTags[child.tag] || child.tag == "body" || child.tag == "html") {
+				flush()
+				e.blocks(child)
+				continue
+			}
+			inline = append(inline, child)
+		}
+	}
+	flush()
+}
+
+// list emits the items of a ul or ol, nesting sublists one level deeper.
+func (e *webEncoder) list(node *htmlNode, depth int) {
+	kind := "L"
+	if node.tag == "ol" {
+		kind = "O"
+	}
+	for _, item := range node.children {
+		if item.tag != "li" || isHidden(item) {
+			continue
+		}
+		var (
+			inline []*htmlNode
+			nested []*htmlNode
+		)
+		for _, child := range item.children {
+			if child.tag == "ul" || child.tag == "ol" {
+				nested = append(nested, child)
+			} else {
+				inline = append(inline, child)
+			}
+		}
+		if text := e.inline(inline); text != "" {
+			e.lines = append(e.lines, kind+strconv.Itoa(depth)+" "+text)
+		}
+		for _, sub := range nested {
+			e.list(sub, depth+1)
+		}
+	}
+}
+
+// table emits one "R" line per row.
+func (e *webEncoder) table(node *htmlNode) {
+	var rows func(*htmlNode)
+	rows = func(n *htmlNode) {
+		for _, child := range n.children {
+			switch child.tag {
+			case "tr":
+				var cells []string
+				for _, cell := range child.children {
+					if cell.tag == "td" || cell.tag == "th" {
+						cells = append(cells, strings.ReplaceAll(e.inline(cell.children), "|", `\|`))
+					}
+				}
+				if len(cells) > 0 {
+					e.lines = append(e.lines, "R "+strings.Join(cells, "|"))
+				}
+			case "thead", "tbody", "tfoot":
+				rows(child)
+			}
+		}
+	}
+	rows(node)
+}
+
+// inline renders text-level content with Markdown emphasis, code, and
+// reference links.
+func (e *webEncoder) inline(nodes []*htmlNode) string {
+	var sb strings.Builder
+	for _, node := range nodes {
+		sb.WriteString(e.render(node))
+	}
+	text := strings.Join(strings.Fields(sb.String()), " ")
+	// Emphasis is padded with spaces; undo the padding before punctuation.
+	for _, p := range []string{".", ",", ";", ":", "!", "?", ")"} {
+		text = strings.ReplaceAll(text, "** "+p, "**"+p)
+		text = strings.ReplaceAll(text, "* "+p, "*"+p)
+		text = strings.ReplaceAll(text, "` "+p, "`"+p)
+	}
+	return text
+}
+
+func (e *webEncoder) render(node *htmlNode) string {
+	if node.tag == "" {
+		return node.text
+	}
+	if webSkipTags[node.tag] || isHidden(node) {
+		return ""
+	}
+	switch node.tag {
+	case "a":
+		text := e.inline(node.children)
+		href := strings.TrimSpace(node.attrs["href"])
+		switch {
+		case text == "":
+			return ""
+		case href == "" || strings.HasPrefix(href, "#") || strings.HasPrefix(strings.ToLower(href), "javascript:"):
+			return text
+		case e.level == LevelAggressive:
+			e.stats["link"]++
+			return text
+		}
+		return fmt.Sprintf("[%s][%d]", text, e.link(href))
+	case "img":
+		src := strings.TrimSpace(node.attrs["src"])
+		if e.level == LevelAggressive || src == "" {
+			e.stats["image"]++
+			return ""
+		}
+		return fmt.Sprintf("![%s][%d]", strings.TrimSpace(node.attrs["alt"]), e.link(src))
+	case "strong", "b":
+		return webWrap(e.inline(node.children), "**")
+	case "em", "i":
+		return webWrap(e.inline(node.children), "*")
+	case "code", "kbd", "samp":
+		return webWrap(e.inline(node.children), "`")
+	case "br":
+		return " "
+	}
+	var sb strings.Builder
+	for _, child := range node.children {
+		sb.WriteString(e.render(child))
+	}
+	if htmlBlockTags[node.tag] {
+		sb.WriteString(" ")
+	}
+	return sb.String()
+}
+
+// webWrap marks text with a Markdown delimiter, padded so that it does not
+// run into neighbouring words.
+func webWrap(text, mark string) string {
+	if text == "" {
+		return ""
+	}
+	return " " + mark + text + mark + " "
+}
+
+// link returns the reference number of url, assigning the next one.
+func (e *webEncoder) link(url string) int {
+	if n, ok := e.links[url]; ok {
+		return n
+	}
+	e.urls = append(e.urls, url)
+	e.links[url] = len(e.urls)
+	return len(e.urls)
+}
@@ -0,0 +1,243 @@
+package iron
+
+import (
+	"html"
+	"strings"
+)
+
+// htmlNode is an element or, when tag is empty, a text node of a parsed
+// HTML document.
+type htmlNode struct {
+	tag      string
+	attrs    map[string]string
+	text     string
+	parent   *htmlNode
+	children []*htmlNode
+}
+
+var (
+	htmlVoidTags = wordSet(`area base br col embed hr img input link meta param source track wbr`)
+	// htmlRawTags hold text that is not parsed as markup.
+	htmlRawTags = wordSet(`script style textarea title`)
+	// htmlBlockTags close an open paragraph.
+	htmlBlockTags = wordSet(`address article aside blockquote dd div dl dt fieldset figure footer form
+		h1 h2 h3 h4 h5 h6 header hr li main nav ol p pre section table ul`)
+)
+
+// parseHTML builds a forgiving element tree: unknown end tags are ignored,
+// unclosed elements end with their parent, the implied ends of p, li, td,
+// th, and tr are honoured, and Redactor placeholders stay text.
+func parseHTML(input string) *htmlNode {
+	root := &htmlNode{tag: "#document"}
+	current := root
+	appendText := func(text string) {
+		if text != "" {
+			current.children = append(current.children, &htmlNode{text: html.UnescapeString(text), parent: current})
+		}
+	}
+	for i := 0; i < len(input); {
+		lt := strings.IndexByte(input[i:], '<')
+		if lt < 0 {
+			appendText(input[i:])
+			break
+		}
+		appendText(input[i : i+lt])
+		i += lt
+		rest := input[i:]
+		switch {
+		case strings.HasPrefix(rest, "<!--"):
+			end := strings.Index(rest[4:], "-->")
+			if end < 0 {
+				return root
+			}
+			i += 4 + end + 3
+		case strings.HasPrefix(rest, "<!") || strings.HasPrefix(rest, "<?"):
+			end := strings.IndexByte(rest, '>')
+			if end < 0 {
+				return root
+			}
+			i += end + 1
+		case strings.HasPrefix(rest, "</"):
+			end := strings.IndexByte(rest, '>')
+			if end < 0 {
+				return root
+			}
+			tag := strings.ToLower(strings.TrimSpace(rest[2:end]))
+			for node := current; node != root; node = node.parent {
+				if node.tag == tag {
+					current = node.parent
+					break
+				}
+			}
+			i += end + 1
+		case redactPlaceholderRe.MatchString(rest):
+			// A Redactor placeholder such as <EMAIL_1> is text, not a tag.
+			n := len(redactPlaceholderRe.FindString(rest))
+			appendText(rest[:n])
+			i += n
+		case len(rest) > 1 && isHTMLNameByte(rest[1]):
+			tag, attrs, selfClosing, n := parseHTMLTag(rest)
+			i += n
+			for current != root && htmlImpliesEnd(tag, current.tag) {
+				current = current.parent
+			}
+			node := &htmlNode{tag: tag, attrs: attrs, parent: current}
+			current.children = append(current.children, node)
+			if htmlRawTags[tag] {
+				end := strings.Index(strings.ToLower(input[i:]), "</"+tag)
+				if end < 0 {
+					end = len(input) - i
+				}
+				if text := input[i : i+end]; text != "" {
+					if tag != "script" && tag != "style" {
+						text = html.UnescapeString(text)
+					}
+					node.children = append(node.children, &htmlNode{text: text, parent: node})
+				}
+				i += end
+				if close := strings.IndexByte(input[i:], '>'); close >= 0 {
+					i += close + 1
+				}
+				continue
+			}
+			if !selfClosing && !htmlVoidTags[tag] {
+				current = node
+			}
+		default:
+			appendText("<")
+			i++
+		}
+	}
+	return root
+}
+
+// parseHTMLTag reads the start tag at the beginning of s and returns its
+// lowercase name, attributes, whether it is self-closing, and its length.
+func parseHTMLTag(s string) (string, map[string]string, bool, int) {
+	i := 1
+	for i < len(s) && isHTMLNameByte(s[i]) {
+		i++
+	}
+	tag := strings.ToLower(s[1:i])
+	attrs := map[string]string{}
+	for i < len(s) {
+		for i < len(s) && (s[i] == ' ' || s[i] == '\t' || s[i] == '\n' || s[i] == '\r') {
+			i++
+		}
+		if i >= len(s) {
+			break
+		}
+		if s[i] == '>' {
+			return tag, attrs, false, i + 1
+		}
+		if strings.HasPrefix(s[i:], "/>") {
+			return tag, attrs, true, i + 2
+		}
+		start := i
+		for i < len(s) && s[i] != '=' && s[i] != '>' && s[i] != ' ' && s[i] != '\t' && s[i] != '\n' && !strings.HasPrefix(s[i:], "/>") {
+			i++
+		}
+		name := strings.ToLower(s[start:i])
+		if i == start {
+			i++
+			continue
+		}
+		value := ""
+		if i < len(s) && s[i] == '=' {
+			i++
+			if i < len(s) && (s[i] == '"' || s[i] == '\'') {
+				quote := s[i]
+				end := strings.IndexByte(s[i+1:], quote)
+				if end < 0 {
+					end = len(s) - i - 1
+				}
+				value = s[i+1 : i+1+end]
+				i += end + 2
+			} else {
+				start := i
+				for i < len(s) && s[i] != '>' && s[i] != ' ' && s[i] != '\t' && s[i] != '\n' {
+					i++
+				}
+				value = s[start:i]
+			}
+		}
+		attrs[name] = html.UnescapeString(value)
+	}
+	return tag, attrs, false, len(s)
+}
+
+func isHTMLNameByte(b byte) bool {
+	return b >= 'a' && b <= 'z' || b >= 'A' && b <= 'Z' || b >= '0' && b <= '9' || b == '-'
+}
+
+// htmlImpliesEnd reports whether opening tag closes the open element.
+func htmlImpliesEnd(tag, open string) bool {
+	switch open {
+	case "p":
+		return htmlBlockTags[tag]
+	case "li":
+		return tag == "li"
+	case "dt", "dd":
+		return tag == "dt" || tag == "dd"
+	case "td", "th":
+		return tag == "td" || tag == "th" || tag == "tr"
+	case "tr":
+		return tag == "tr"
+	case "option":
+		return tag == "option"
+	}
+	return false
+}
+
+// find returns the first element with the given tag, depth first.
+func (n *htmlNode) find(tag string) *htmlNode {
+	for _, child := range n.children {
+		if child.tag == tag {
+			return child
+		}
+		if found := child.find(tag); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+// innerText returns the collapsed text of the node and its descendants.
+func (n *htmlNode) innerText() string {
+	var sb strings.Builder
+	n.walkText(func(text string) { sb.WriteString(text) })
+	return strings.Join(strings.Fields(sb.String()), " ")
+}
+
+// linkText returns the collapsed text inside the node's links.
+func (n *htmlNode) linkText() string {
+	var sb strings.Builder
+	var walk func(*htmlNode, bool)
+	walk = func(node *htmlNode, inLink bool) {
+		if node.tag == "" && inLink {
+			sb.WriteString(node.text)
+			sb.WriteByte(' ')
+		}
+		for _, child := range node.children {
+			walk(child, inLink || child.tag == "a")
+		}
+	}
+	walk(n, n.tag == "a")
+	return strings.Join(strings.Fields(sb.String()), " ")
+}
+
+func (n *htmlNode) walkText(fn func(string)) {
+	if n.tag == "" {
+		fn(n.text)
+		return
+	}
+	if webSkipTags[n.tag] {
+		return
+	}
+	for _, child := range n.children {
+		child.walkText(fn)
+		if htmlBlockTags[child.tag] || child.tag == "br" || child.tag == "td" || child.tag == "th" {
+			fn(" ")
+		}
+	}
+}
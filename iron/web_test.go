@@ -0,0 +1,120 @@
+package iron
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWebModule_Decode_MatchesMarkdownFixtures(t *testing.T) {
+	paths, err := filepath.Glob("testdata/web/*.html")
+	if err != nil || len(paths) == 0 {
+		t.Fatalf("Glob() = %v, %v", paths, err)
+	}
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("ReadFile() error = %v", err)
+		}
+		module := WebModule{Level: LevelBalanced}
+		if !module.Detect(string(data)) {
+			t.Fatalf("%s: Detect() = false", path)
+		}
+		ir, err := module.Encode(string(data))
+		if err != nil {
+			t.Fatalf("%s: Encode() error = %v", path, err)
+		}
+		got, err := module.Decode(ir)
+		if err != nil {
+			t.Fatalf("%s: Decode() error = %v", path, err)
+		}
+		golden := strings.TrimSuffix(path, ".html") + ".md"
+		if *updateGolden {
+			if err := os.WriteFile(golden, []byte(got+"\n"), 0o644); err != nil {
+				t.Fatalf("WriteFile() error = %v", err)
+			}
+			continue
+		}
+		want, err := os.ReadFile(golden)
+		if err != nil {
+			t.Fatalf("ReadFile() error = %v (run with -update)", err)
+		}
+		if got != strings.TrimSuffix(string(want), "\n") {
+			t.Fatalf("%s: Markdown mismatch\ngot:\n%s\nwant:\n%s", path, got, want)
+		}
+		if len(ir) >= len(data) {
+			t.Fatalf("%s: IR has %d bytes, HTML %d", path, len(ir), len(data))
+		}
+	}
+}
+
+func TestWebModule_EncodeWithLoss_ScoresOutBoilerplate(t *testing.T) {
+	data, err := os.ReadFile("testdata/web/blog.html")
+	if err != nil {
+		t.Fatalf("ReadFile() error = %v", err)
+	}
+	lossless, _, err := WebModule{}.EncodeWithLoss(string(data))
+	if err != nil {
+		t.Fatalf("EncodeWithLoss() error = %v", err)
+	}
+	balanced, losses, err := WebModule{Level: LevelBalanced}.EncodeWithLoss(string(data))
+	if err != nil {
+		t.Fatalf("EncodeWithLoss() error = %v", err)
+	}
+	for _, text := range []string{"dataLayer", "font-family", "Accept"} {
+		if strings.Contains(lossless, text) {
+			t.Fatalf("lossless IR keeps %q:\n%s", text, lossless)
+		}
+	}
+	for _, text := range []string{"Related posts", "Try Acme Cloud", "cookies", "Careers"} {
+		if !strings.Contains(lossless, text) {
+			t.Fatalf("lossless IR drops %q:\n%s", text, lossless)
+		}
+		if strings.Contains(balanced, text) {
+			t.Fatalf("balanced IR keeps %q:\n%s", text, balanced)
+		}
+	}
+	if !strings.Contains(balanced, "SKIP LOCKED") || !strings.Contains(balanced, "@1 https://www.postgresql.org/") {
+		t.Fatalf("balanced IR lost the article:\n%s", balanced)
+	}
+	kinds := map[string]int{}
+	for _, loss := range losses {
+		kinds[loss.Kind] = loss.Count
+	}
+	if kinds["script"] != 2 || kinds["style"] != 1 || kinds["boilerplate"] == 0 {
+		t.Fatalf("losses = %+v", losses)
+	}
+
+	aggressive, _, err := WebModule{Level: LevelAggressive}.EncodeWithLoss(string(data))
+	if err != nil {
+		t.Fatalf("EncodeWithLoss() error = %v", err)
+	}
+	if strings.Contains(aggressive, "https://") || !strings.Contains(aggressive, "Postgres documentation on locking clauses") {
+		t.Fatalf("aggressive IR = %s", aggressive)
+	}
+}
+
+func TestWebModule_Detect(t *testing.T) {
+	cases := map[string]bool{
+		"<html><body><p>hi</p></body></html>":  true,
+		"<div><h2>Title</h2><p>text</p></div>": true,
+		"<config><key>value</key></config>":    false,
+		"use <p> for paragraphs":               false,
+		`{"html": "<p>x</p>"}`:                 false,
+	}
+	for input, want := range cases {
+		if got := (WebModule{}).Detect(input); got != want {
+			t.Errorf("Detect(%q) = %t, want %t", input, got, want)
+		}
+	}
+}
+
+func TestWebModule_Decode_RejectsOtherIR(t *testing.T) {
+	if _, err := (WebModule{}).Decode("@LOG[lossless]\nline"); err == nil {
+		t.Fatal("Decode() error = nil, want ErrInvalidIR")
+	}
+	if _, err := (WebModule{}).Decode("@WEB\nX nope"); err == nil {
+		t.Fatal("Decode() error = nil for unknown block")
+	}
+}